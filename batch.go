@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	/generate-batch fills a template once per job, each with a context and output of
+	its own (mail merge). Jobs are filled generate_batch_workers at a time and one
+	failing doesn't stop the others, every job gets its result with the status a
+	/generate of it would have had. The request only fails when it's malformed.
+*/
+
+// How many jobs are filled at once, 0 means runtime.GOMAXPROCS(0)
+var generate_batch_workers = 0
+
+// Most jobs one request may have
+const batch_max_jobs = 1000
+
+// JSON body of /generate-batch
+type BatchRequest struct {
+	Jobs             []BatchJob `json:"jobs" binding:"required"`
+	Flatten          bool       `json:"flatten"`
+	StrictFormats    bool       `json:"strict_formats"`
+	TruncateToMaxLen bool       `json:"truncate_to_max_len"`
+	// How strictly the templates are checked, see setValidation
+	Validation string `json:"validation"`
+	RepairXRef bool   `json:"repair_xref"`
+}
+
+// One fill of a /generate-batch: context is what context_json_file is for /generate,
+// output a file path or an s3:// URL
+type BatchJob struct {
+	Template string      `json:"template"`
+	Context  interface{} `json:"context"`
+	Output   string      `json:"output"`
+}
+
+// What became of a BatchJob, status is the HTTP status a /generate of it would have had
+type BatchResult struct {
+	Job         int      `json:"job"`
+	Template    string   `json:"template"`
+	Output      string   `json:"output"`
+	Status      int      `json:"status"`
+	Error       string   `json:"error,omitempty"`
+	Code        string   `json:"code,omitempty"`
+	Filled      []string `json:"filled"`
+	Untouched   []string `json:"untouched"`
+	FieldErrors []string `json:"field_errors,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Missing     []string `json:"missing,omitempty"`
+}
+
+func generateBatchHandler(c *gin.Context) {
+	var req BatchRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if len(req.Jobs) == 0 || len(req.Jobs) > batch_max_jobs {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. jobs must have between 1 and %d jobs, got %d", batch_max_jobs, len(req.Jobs))}})
+		return
+	}
+	validation := gopdf.ValidationOptions{Mode: req.Validation, RepairXRef: req.RepairXRef}
+	if err := gopdf.CheckValidationMode(validation.Mode); err != nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %v", err)}})
+		return
+	}
+	requestLogger(c).Info("filling batch", "jobs", len(req.Jobs))
+
+	options := gopdf.GenerateOptions{Flatten: req.Flatten, StrictFormats: req.StrictFormats, TruncateToMaxLen: req.TruncateToMaxLen}
+	results := make([]BatchResult, len(req.Jobs))
+	workers := generate_batch_workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(req.Jobs) {
+		workers = len(req.Jobs)
+	}
+	// Streamed, every result is its own line as soon as the job is done
+	var stream *ndjsonWriter
+	if wantsNDJSON(c) {
+		stream = &ndjsonWriter{c: c}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = runBatchJob(c, idx, req.Jobs[idx], options, validation)
+				if stream != nil {
+					stream.line(results[idx])
+				}
+			}
+		}()
+	}
+	for idx := range req.Jobs {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	filled_jobs := 0
+	for _, result := range results {
+		if result.Status == http.StatusOK {
+			filled_jobs++
+		}
+	}
+	if filled_jobs < len(results) {
+		requestLogger(c).Warn("some batch jobs failed", "jobs", len(results), "failed", len(results)-filled_jobs)
+	}
+	message := fmt.Sprintf("Filled %d of %d jobs", filled_jobs, len(results))
+	if stream != nil {
+		stream.line(gin.H{"message": message, "done": true})
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{
+		"message": message,
+		"results": results,
+	}})
+}
+
+func runBatchJob(c *gin.Context, idx int, job BatchJob, options gopdf.GenerateOptions, validation gopdf.ValidationOptions) BatchResult {
+	pdf_jobs_in_flight.Inc()
+	defer pdf_jobs_in_flight.Dec()
+	result := BatchResult{Job: idx, Template: job.Template, Output: job.Output, Filled: make([]string, 0), Untouched: make([]string, 0)}
+	failed := func(status int, code string, message string) BatchResult {
+		result.Status = status
+		result.Code = code
+		result.Error = message
+		return result
+	}
+	if job.Template == "" {
+		return failed(http.StatusBadRequest, code_invalid_request, "Bad Request. template is required")
+	}
+	if job.Output == "" {
+		return failed(http.StatusBadRequest, code_invalid_request, "Bad Request. output is required")
+	}
+	out_path, err := resolveOutputPath(job.Output)
+	if err != nil {
+		return failed(http.StatusForbidden, code_output_forbidden, fmt.Sprintf("Forbidden. output %s %v", job.Output, err))
+	}
+	if c.Request.Context().Err() != nil {
+		return failed(http.StatusGatewayTimeout, code_timeout, "Gateway Timeout. the batch ran out of time before this job")
+	}
+
+	var context map[string]interface{}
+	switch v := job.Context.(type) {
+	case map[string]interface{}:
+		context = v
+	case string:
+		if v == "" {
+			return failed(http.StatusBadRequest, code_invalid_request, "Bad Request. context is required")
+		}
+		var err error
+		context, err = readContextJSON(c, v)
+		if err != nil {
+			return failed(http.StatusBadRequest, code_invalid_request, fmt.Sprintf("Bad Request. context is a string so it is read as the path or URL of a JSON file: %v", err))
+		}
+	default:
+		return failed(http.StatusBadRequest, code_invalid_request, fmt.Sprintf("Bad Request. context must be an object mapping field names to values or the path or URL of a JSON file holding one, got %s", jsonType(job.Context)))
+	}
+
+	var template_file gopdf.InputFile
+	if isURL(job.Template) {
+		var err error
+		template_file, err = fetchFile(c, job.Template, true)
+		var not_pdf *gopdf.NotPDFError
+		if errors.As(err, &not_pdf) {
+			return failed(http.StatusUnsupportedMediaType, code_unsupported_type, fmt.Sprintf("Unsupported Media Type. %s is %v", job.Template, err))
+		}
+		if errors.Is(err, errFetchForbidden) {
+			return failed(http.StatusForbidden, code_fetch_forbidden, fmt.Sprintf("Forbidden. fetching %s: %v", job.Template, err))
+		}
+		if err != nil {
+			return failed(http.StatusBadGateway, code_fetch_failed, fmt.Sprintf("Bad Gateway. fetching %s: %v", job.Template, err))
+		}
+	} else {
+		template_file = pathFile(c, job.Template)
+	}
+	input_files := []gopdf.InputFile{template_file}
+	input_files[0].Validation = validation
+	var generate_result gopdf.GenerateResult
+	var pdf_buf bytes.Buffer
+	if isS3URL(job.Output) {
+		if _, _, err := parseS3URL(job.Output); err != nil {
+			return failed(http.StatusBadRequest, code_invalid_request, fmt.Sprintf("Bad Request. %v", err))
+		}
+		generate_result, err = gopdf.Generate(c.Request.Context(), context, "", input_files, &pdf_buf, options)
+	} else {
+		generate_result, err = gopdf.Generate(c.Request.Context(), context, out_path, input_files, nil, options)
+	}
+	if filled, found := generate_result.Filled[job.Template]; found {
+		result.Filled = filled
+	}
+	if untouched, found := generate_result.Untouched[job.Template]; found {
+		result.Untouched = untouched
+	}
+	result.FieldErrors = generate_result.FieldErrors[job.Template]
+	result.Warnings = generate_result.Warnings[job.Template]
+	result.Missing = generate_result.Missing
+	if err != nil {
+		response := generateErrorResponse(generate_result, err)
+		// Named by the template, the "for idx: 0" of the FileError would be its index in a one file list
+		var file_error *gopdf.FileError
+		if errors.As(err, &file_error) {
+			return failed(response.Status, response.Code, fmt.Sprintf("%s. %s: %v", http.StatusText(response.Status), job.Template, file_error.Err))
+		}
+		return failed(response.Status, response.Code, response.Error[0])
+	}
+	if isS3URL(job.Output) {
+		if err := uploadS3(c.Request.Context(), job.Output, bytes.NewReader(pdf_buf.Bytes())); err != nil {
+			return failed(http.StatusBadGateway, code_upload_failed, fmt.Sprintf("Bad Gateway. uploading %s: %v", job.Output, err))
+		}
+	}
+	result.Status = http.StatusOK
+	return result
+}
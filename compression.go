@@ -0,0 +1,174 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	HTTP compression: request bodies sent with Content-Encoding gzip or deflate are
+	decompressed before anything reads them, and responses are gzipped (or deflated)
+	for clients whose Accept-Encoding takes it. PDFs, images and zips aren't, they are
+	compressed already. decompressBody runs after limitBody, so the limit holds for the
+	body as sent and again for the body it decompresses to.
+*/
+
+// Response types that don't get any smaller compressed
+var precompressed_types = []string{"application/pdf", "application/zip", "image/"}
+
+func decompressBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		var body io.ReadCloser
+		var err error
+		switch encoding {
+		case "", "identity":
+			c.Next()
+			return
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(c.Request.Body)
+		case "deflate":
+			// HTTP's deflate is zlib framed
+			body, err = zlib.NewReader(c.Request.Body)
+		default:
+			sendResponse(c, Response{Status: http.StatusUnsupportedMediaType, Error: []string{fmt.Sprintf("Unsupported Media Type. Content-Encoding must be gzip or deflate, got %q", encoding)}})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. the body isn't valid %s: %v", encoding, err)}})
+			c.Abort()
+			return
+		}
+		defer body.Close()
+		c.Request.Body = http.MaxBytesReader(c.Writer, body, limit)
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
+
+func compressResponse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		writer := &compressingWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = writer
+		defer func() {
+			writer.close()
+			c.Writer = writer.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+func acceptedEncoding(accept_encoding string) string {
+	// gzip or deflate when the Accept-Encoding header takes them, gzip first, "" for neither
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(accept_encoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		// q=0 says it must not be used
+		refused := false
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[len("q="):], 64)
+				refused = err == nil && q == 0
+			}
+		}
+		accepted[name] = !refused
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressingWriter compresses what the handler writes, once the Content-Type says it's worth it
+type compressingWriter struct {
+	gin.ResponseWriter
+	encoding string
+	decided  bool
+	// nil when the response goes out as it is
+	compressor interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+func (w *compressingWriter) decide() {
+	/*
+		Picks whether to compress when the headers are about to go out, by then the
+		handler has set the Content-Type. A handler's own Content-Encoding (promhttp
+		gzips /metrics itself) and bodyless statuses go out as they are.
+	*/
+	if w.decided {
+		return
+	}
+	w.decided = true
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	status := w.Status()
+	if header.Get("Content-Encoding") != "" || status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+	content_type := header.Get("Content-Type")
+	for _, t := range precompressed_types {
+		if strings.HasPrefix(content_type, t) {
+			return
+		}
+	}
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	if w.encoding == "gzip" {
+		w.compressor = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		w.compressor = zlib.NewWriter(w.ResponseWriter)
+	}
+}
+
+func (w *compressingWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.compressor.Write(data)
+}
+
+func (w *compressingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressingWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressingWriter) Flush() {
+	// Streamed responses (NDJSON) get every line through the compressor right away
+	w.decide()
+	if w.compressor != nil {
+		w.compressor.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressingWriter) close() {
+	if w.compressor != nil {
+		w.compressor.Close()
+	}
+}
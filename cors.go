@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	CORS for browser clients on other origins. Only the origins configured with
+	GOPDF_CORS_ORIGINS (or -cors-origins) get CORS headers, with none configured a
+	browser can only call the API from a page on its own origin. Preflights are
+	answered here, before the body is limited, decompressed or read by any handler.
+*/
+
+// The methods the API has routes for
+var cors_methods = []string{http.MethodGet, http.MethodPost}
+
+// What clients may send besides the headers CORS always allows
+var cors_request_headers = []string{"Content-Type", "Content-Encoding", idempotency_header, request_id_header}
+
+// What scripts may read of a response besides the headers CORS always shows
+var cors_exposed_headers = []string{"Content-Disposition", "Idempotent-Replayed", request_id_header}
+
+// How long, in seconds, browsers may reuse a preflight answer
+const cors_max_age = 600
+
+func parseCORSOrigins(value string) ([]string, error) {
+	/*
+		A comma separated list of origins, scheme://host[:port] like browsers send them in
+		Origin, or * for any origin. A path or anything else after the host is an error,
+		origins never have one and a list entry that does would never match.
+	*/
+	origins := make([]string, 0)
+	for _, part := range strings.Split(value, ",") {
+		origin := strings.TrimSpace(part)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			origins = append(origins, origin)
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("%q is not an origin, it has to be like https://app.example.com", origin)
+		}
+		if (parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+			return nil, fmt.Errorf("%q is not an origin, it can't have anything after the host and port", origin)
+		}
+		origins = append(origins, strings.ToLower(parsed.Scheme+"://"+parsed.Host))
+	}
+	return origins, nil
+}
+
+func corsPolicy(origins []string) gin.HandlerFunc {
+	/*
+		Adds the CORS headers for requests from the allowed origins and answers their
+		preflights (OPTIONS with Access-Control-Request-Method) with a 204 right away.
+		A preflight from any other origin is a 403. Other requests from other origins go
+		on without CORS headers, the browser keeps the response from their scripts, and
+		same origin requests carry an Origin header too.
+	*/
+	any_origin := containsString(origins, "*")
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if len(origins) == 0 || origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !any_origin && !containsString(origins, strings.ToLower(origin)) {
+			if preflight {
+				sendResponse(c, Response{Status: http.StatusForbidden, Error: []string{fmt.Sprintf("Forbidden. origin %s is not allowed", origin)}, Code: code_origin_forbidden})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		allow_origin := origin
+		if any_origin {
+			allow_origin = "*"
+		}
+		c.Header("Access-Control-Allow-Origin", allow_origin)
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", strings.Join(cors_methods, ", "))
+			c.Header("Access-Control-Allow-Headers", strings.Join(cors_request_headers, ", "))
+			c.Header("Access-Control-Max-Age", strconv.Itoa(cors_max_age))
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Header("Access-Control-Expose-Headers", strings.Join(cors_exposed_headers, ", "))
+		c.Next()
+	}
+}
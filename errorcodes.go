@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Machine readable error codes: every error response has one under "code" next to
+	the message under "error", for clients to branch on instead of the message text.
+	The codes are part of the API, they don't change once they're out. A Response
+	without a Code gets the one for its status (statusErrorCode).
+*/
+
+const (
+	// The request itself
+	code_invalid_json     = "INVALID_JSON"
+	code_invalid_request  = "INVALID_REQUEST"
+	code_body_too_large   = "BODY_TOO_LARGE"
+	code_unsupported_type = "UNSUPPORTED_MEDIA_TYPE"
+	code_output_forbidden = "OUTPUT_FORBIDDEN"
+	code_in_progress      = "REQUEST_IN_PROGRESS"
+	code_origin_forbidden = "ORIGIN_FORBIDDEN"
+	// The input files
+	code_file_not_found    = "FILE_NOT_FOUND"
+	code_invalid_pdf       = "INVALID_PDF"
+	code_validation_failed = "VALIDATION_FAILED"
+	code_password_required = "PASSWORD_REQUIRED"
+	code_wrong_password    = "WRONG_PASSWORD"
+	code_not_encrypted     = "NOT_ENCRYPTED"
+	code_already_encrypted = "ALREADY_ENCRYPTED"
+	code_xfa_form          = "XFA_FORM"
+	code_too_damaged       = "TOO_DAMAGED"
+	code_no_thumbnail      = "NO_THUMBNAIL"
+	// The form fields
+	code_field_not_found = "FIELD_NOT_FOUND"
+	// The attachments
+	code_attachment_not_found = "ATTACHMENT_NOT_FOUND"
+	code_attachment_exists    = "ATTACHMENT_EXISTS"
+	// Things the server depends on
+	code_timeout         = "TIMEOUT"
+	code_fetch_failed    = "FETCH_FAILED"
+	code_fetch_forbidden = "FETCH_FORBIDDEN"
+	code_upload_failed   = "UPLOAD_FAILED"
+	code_unhealthy       = "UNHEALTHY"
+	code_internal        = "INTERNAL_ERROR"
+)
+
+func statusErrorCode(status int) string {
+	// The code of an error response that doesn't say which one it is
+	switch status {
+	case http.StatusBadRequest:
+		return code_invalid_request
+	case http.StatusForbidden:
+		return code_output_forbidden
+	case http.StatusNotFound:
+		return code_file_not_found
+	case http.StatusConflict:
+		return code_in_progress
+	case http.StatusRequestEntityTooLarge:
+		return code_body_too_large
+	case http.StatusUnsupportedMediaType:
+		return code_unsupported_type
+	case http.StatusUnprocessableEntity:
+		return code_too_damaged
+	case http.StatusBadGateway:
+		return code_fetch_failed
+	case http.StatusServiceUnavailable:
+		return code_unhealthy
+	case http.StatusGatewayTimeout:
+		return code_timeout
+	}
+	return code_internal
+}
+
+func fileErrorCode(err error) string {
+	/*
+		The code for what went wrong with an input file, whatever's at the bottom of err:
+		missing, encrypted, not passing validation or not a PDF we can read at all
+	*/
+	var validation_error *gopdf.ValidationError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return code_file_not_found
+	case errors.Is(err, gopdf.ErrPasswordRequired):
+		return code_password_required
+	case errors.Is(err, gopdf.ErrWrongPassword):
+		return code_wrong_password
+	case errors.Is(err, gopdf.ErrNotEncrypted):
+		return code_not_encrypted
+	case errors.Is(err, gopdf.ErrAlreadyEncrypted):
+		return code_already_encrypted
+	case errors.Is(err, gopdf.ErrXFAForm):
+		return code_xfa_form
+	case errors.Is(err, gopdf.ErrFieldsNotFound):
+		return code_field_not_found
+	case errors.Is(err, gopdf.ErrAttachmentNotFound):
+		return code_attachment_not_found
+	case errors.Is(err, gopdf.ErrAttachmentExists):
+		return code_attachment_exists
+	case errors.Is(err, gopdf.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return code_timeout
+	case errors.As(err, &validation_error):
+		return code_validation_failed
+	}
+	return code_invalid_pdf
+}
+
+func decodeErrorCode(err error) string {
+	// The code of a body that couldn't be decoded, JSON that isn't or a value of the wrong type
+	var syntax_error *json.SyntaxError
+	if errors.As(err, &syntax_error) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return code_invalid_json
+	}
+	return code_invalid_request
+}
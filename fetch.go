@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Input PDFs by URL: an input file that's an http(s) URL is fetched while the request
+	is read, into memory, and from then on it's an input file like any other. A URL that
+	can't be fetched is a 502, so it's told apart from a PDF we can't process (a 400).
+	Fetches are retried like reading any input (see withRetry).
+	Only public addresses are fetched from: every connection, redirects included, is
+	checked once the host is resolved, so a URL can't reach the server's own network
+	unless fetch_allowed_networks lets it.
+*/
+
+var (
+	// How long fetching one URL may take and how big what it fetches may be
+	fetch_timeout         = 30 * time.Second
+	fetch_max_bytes int64 = 50 << 20
+)
+
+const (
+	fetch_max_redirects = 5
+	fetch_user_agent    = "gopdf-server"
+)
+
+// The networks fetches may connect to although they're loopback, private or link-local
+var fetch_allowed_networks []*net.IPNet
+
+// errFetchForbidden is a URL whose host is at an address fetches don't connect to
+var errFetchForbidden = errors.New("fetching from this address is not allowed")
+
+var fetch_client = &http.Client{
+	// No proxy: the addresses checked have to be the ones connected to
+	Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkFetchAddress}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= fetch_max_redirects {
+			return fmt.Errorf("stopped after %d redirects", fetch_max_redirects)
+		}
+		return nil
+	},
+}
+
+func checkFetchAddress(network string, address string, _ syscall.RawConn) error {
+	/*
+		The dialer's check of the address a fetch is about to connect to, host resolved:
+		loopback, private, link-local, multicast and unspecified addresses are an
+		errFetchForbidden, unless they're in fetch_allowed_networks
+	*/
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s is not an IP address", errFetchForbidden, host)
+	}
+	for _, allowed := range fetch_allowed_networks {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s is not a public address", errFetchForbidden, ip)
+	}
+	return nil
+}
+
+func parseNetworks(value string) ([]*net.IPNet, error) {
+	// A comma separated list of networks in CIDR notation (10.0.0.0/8) or single IP addresses
+	networks := make([]*net.IPNet, 0)
+	for _, part := range strings.Split(value, ",") {
+		network := strings.TrimSpace(part)
+		if network == "" {
+			continue
+		}
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or a network like 10.0.0.0/8", network)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ip_net, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or a network like 10.0.0.0/8", network)
+		}
+		networks = append(networks, ip_net)
+	}
+	return networks, nil
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func fetchURL(ctx context.Context, url string, limit int64) ([]byte, error) {
+	// Up to limit bytes of what GET url answers with, a status other than 200 is an *httpStatusError
+	if fetch_timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fetch_timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fetch_user_agent)
+	resp, err := fetch_client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+func fetchFile(c *gin.Context, url string, pdf bool) (gopdf.InputFile, error) {
+	/*
+		The input file at url, fetched now and read from memory after. A pdf is checked
+		for a PDF header like uploads are (see checkUploads) before anything else reads
+		it, one without is a *gopdf.NotPDFError.
+	*/
+	var content []byte
+	err := withRetry(c, url, func() error {
+		var err error
+		content, err = fetchURL(c.Request.Context(), url, fetch_max_bytes+1)
+		return err
+	})
+	if err != nil {
+		return gopdf.InputFile{}, err
+	}
+	if int64(len(content)) > fetch_max_bytes {
+		return gopdf.InputFile{}, fmt.Errorf("%s is bigger than %d bytes", url, fetch_max_bytes)
+	}
+	requestLogger(c).Debug("fetched input file", "url", url, "bytes", len(content))
+	in_file := gopdf.BytesFile(url, content)
+	if pdf {
+		if err = gopdf.CheckPDF(in_file); err != nil {
+			return gopdf.InputFile{}, err
+		}
+	}
+	return measuredFile(in_file), nil
+}
+
+func inputFile(c *gin.Context, location string) (gopdf.InputFile, bool) {
+	/*
+		The input PDF at location, a path on the server or an http(s) URL.
+		Sends a 502 when the URL can't be fetched, a 403 when its address isn't allowed
+		and a 415 when what it answers with isn't a PDF.
+	*/
+	return locationFile(c, location, true)
+}
+
+func attachmentFile(c *gin.Context, location string) (gopdf.InputFile, bool) {
+	// Same as inputFile for files that can be anything, not only PDFs
+	return locationFile(c, location, false)
+}
+
+func locationFile(c *gin.Context, location string, pdf bool) (gopdf.InputFile, bool) {
+	if !isURL(location) {
+		return pathFile(c, location), true
+	}
+	in_file, err := fetchFile(c, location, pdf)
+	var not_pdf *gopdf.NotPDFError
+	switch {
+	case errors.As(err, &not_pdf):
+		sendResponse(c, Response{Status: http.StatusUnsupportedMediaType, Error: []string{fmt.Sprintf("Unsupported Media Type. %s is %v", location, err)}})
+		return gopdf.InputFile{}, false
+	case errors.Is(err, errFetchForbidden):
+		sendResponse(c, Response{Status: http.StatusForbidden, Error: []string{fmt.Sprintf("Forbidden. fetching %s: %v", location, err)}, Code: code_fetch_forbidden})
+		return gopdf.InputFile{}, false
+	case err != nil:
+		sendResponse(c, Response{Status: http.StatusBadGateway, Error: []string{fmt.Sprintf("Bad Gateway. fetching %s: %v", location, err)}})
+		return gopdf.InputFile{}, false
+	}
+	return in_file, true
+}
+
+func inputFiles(c *gin.Context, locations []string) ([]gopdf.InputFile, bool) {
+	input_files := make([]gopdf.InputFile, len(locations))
+	for i, location := range locations {
+		var ok bool
+		input_files[i], ok = inputFile(c, location)
+		if !ok {
+			return nil, false
+		}
+	}
+	return input_files, true
+}
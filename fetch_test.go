@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckFetchAddress(t *testing.T) {
+	for address, allowed := range map[string]bool{
+		"93.184.216.34:443":     true,
+		"[2606:4700::1111]:443": true,
+		"127.0.0.1:80":          false,
+		"[::1]:80":              false,
+		"10.1.2.3:80":           false,
+		"172.16.0.1:80":         false,
+		"192.168.1.1:80":        false,
+		"169.254.169.254:80":    false,
+		"[fe80::1]:80":          false,
+		"[fd00::1]:80":          false,
+		"0.0.0.0:80":            false,
+		"[::]:80":               false,
+		"[::ffff:127.0.0.1]:80": false,
+		"224.0.0.1:80":          false,
+	} {
+		err := checkFetchAddress("tcp", address, nil)
+		if allowed && err != nil {
+			t.Errorf("%s: %v, want it allowed", address, err)
+		}
+		if !allowed && !errors.Is(err, errFetchForbidden) {
+			t.Errorf("%s: got %v, want errFetchForbidden", address, err)
+		}
+	}
+}
+
+func TestCheckFetchAddressAllowedNetworks(t *testing.T) {
+	saved := fetch_allowed_networks
+	defer func() { fetch_allowed_networks = saved }()
+	var err error
+	fetch_allowed_networks, err = parseNetworks("10.1.0.0/16, 127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for address, allowed := range map[string]bool{
+		"10.1.2.3:80":  true,
+		"10.2.0.1:80":  false,
+		"127.0.0.1:80": true,
+		"127.0.0.2:80": false,
+	} {
+		if err := checkFetchAddress("tcp", address, nil); (err == nil) != allowed {
+			t.Errorf("%s: got %v, want allowed %v", address, err, allowed)
+		}
+	}
+	if _, err := parseNetworks("10.1.0.0/33"); err == nil {
+		t.Error("10.1.0.0/33 parsed")
+	}
+	if _, err := parseNetworks("localhost"); err == nil {
+		t.Error("localhost parsed")
+	}
+}
+
+func TestFetchLoopbackForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.7"))
+	}))
+	defer server.Close()
+	// Redirects are dialed like any other connection
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusFound))
+	defer redirect.Close()
+
+	for _, url := range []string{server.URL, redirect.URL} {
+		if _, err := fetchURL(context.Background(), url, 1<<20); !errors.Is(err, errFetchForbidden) {
+			t.Errorf("%s: got %v, want errFetchForbidden", url, err)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/info", nil)
+	if _, ok := inputFile(c, server.URL); ok {
+		t.Fatal("loopback URL was fetched")
+	}
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want 403", recorder.Code)
+	}
+
+	saved := fetch_allowed_networks
+	defer func() { fetch_allowed_networks = saved }()
+	fetch_allowed_networks, _ = parseNetworks("127.0.0.0/8")
+	content, err := fetchURL(context.Background(), redirect.URL, 1<<20)
+	if err != nil || string(content) != "%PDF-1.7" {
+		t.Errorf("allowed loopback: got %q and %v", content, err)
+	}
+}
+
+func TestFetchNotPDF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG\r\n\x1a\n not a PDF"))
+	}))
+	defer server.Close()
+	saved := fetch_allowed_networks
+	defer func() { fetch_allowed_networks = saved }()
+	fetch_allowed_networks, _ = parseNetworks("127.0.0.0/8")
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/info", nil)
+	if _, ok := inputFile(c, server.URL); ok {
+		t.Fatal("a PNG was taken for an input PDF")
+	}
+	if recorder.Code != http.StatusUnsupportedMediaType || !strings.Contains(recorder.Body.String(), "a PNG image") {
+		t.Errorf("got %d %s, want a 415 naming a PNG image", recorder.Code, recorder.Body)
+	}
+
+	// Attachments can be anything
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/attach", nil)
+	if _, ok := attachmentFile(c, server.URL); !ok {
+		t.Error("a PNG attachment was refused")
+	}
+}
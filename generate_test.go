@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateStatus(t *testing.T) {
+	r := testRouter()
+	in_path := writeTestPDF(t, test_form)
+	out_dir := t.TempDir()
+	// A regular file where the output's directory should be, writing under it fails with ENOTDIR
+	not_a_dir := writeTestPDF(t, test_form)
+	for _, test := range []struct {
+		what   string
+		body   interface{}
+		status int
+		code   string
+	}{
+		{"filled", map[string]interface{}{"input_files": []string{in_path}, "context_json_file": map[string]interface{}{"name": "Jane"}, "output_file": filepath.Join(out_dir, "filled.pdf")}, http.StatusOK, ""},
+		{"broken JSON", `{"input_files": [`, http.StatusBadRequest, code_invalid_json},
+		{"no context", map[string]interface{}{"input_files": []string{in_path}, "output_file": filepath.Join(out_dir, "out.pdf")}, http.StatusBadRequest, ""},
+		{"unknown field", map[string]interface{}{"input_files": []string{in_path}, "context_json_file": map[string]interface{}{"surname": "Doe"}, "output_file": filepath.Join(out_dir, "out.pdf")}, http.StatusBadRequest, code_field_not_found},
+		{"broken PDF", map[string]interface{}{"input_files": []string{writeTestPDF(t, []byte("%PDF-1.7\nnot a PDF after all"))}, "context_json_file": map[string]interface{}{"name": "Jane"}, "output_file": filepath.Join(out_dir, "out.pdf")}, http.StatusBadRequest, code_invalid_pdf},
+		{"missing input", map[string]interface{}{"input_files": []string{filepath.Join(out_dir, "missing.pdf")}, "context_json_file": map[string]interface{}{"name": "Jane"}, "output_file": filepath.Join(out_dir, "out.pdf")}, http.StatusNotFound, code_file_not_found},
+		{"unwritable output", map[string]interface{}{"input_files": []string{in_path}, "context_json_file": map[string]interface{}{"name": "Jane"}, "output_file": filepath.Join(not_a_dir, "out.pdf")}, http.StatusInternalServerError, code_internal},
+	} {
+		recorder := serveJSON(t, r, "/generate", test.body)
+		body := checkStatus(t, test.what, recorder)
+		if recorder.Code != test.status {
+			t.Errorf("%s: got %d, want %d: %v", test.what, recorder.Code, test.status, body)
+		}
+		if test.code != "" && body["code"] != test.code {
+			t.Errorf("%s: got code %v, want %s", test.what, body["code"], test.code)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out_dir, "filled.pdf")); err != nil {
+		t.Errorf("filled file wasn't written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out_dir, "out.pdf")); err == nil {
+		t.Error("a failed generate wrote its output_file")
+	}
+}
+
+func TestGenerateResponseBody(t *testing.T) {
+	// Without an output_file the filled PDF is the response
+	recorder := serveJSON(t, testRouter(), "/generate", map[string]interface{}{"input_files": []string{writeTestPDF(t, test_form)}, "context_json_file": map[string]interface{}{"name": "Jane"}})
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("got %d %s, want a 200 PDF: %s", recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body)
+	}
+	if !bytes.HasPrefix(recorder.Body.Bytes(), []byte("%PDF-")) || !bytes.Contains(recorder.Body.Bytes(), []byte("Jane")) {
+		t.Error("response isn't the filled PDF")
+	}
+}
+
+func TestGenerateReproducible(t *testing.T) {
+	r := testRouter()
+	body := map[string]interface{}{"input_files": []string{writeTestPDF(t, test_form)}, "context_json_file": map[string]interface{}{"name": "Jane"}, "reproducible": true}
+	first := serveJSON(t, r, "/generate", body)
+	time.Sleep(1100 * time.Millisecond) // pdfcpu's own dates are to the second
+	second := serveJSON(t, r, "/generate", body)
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("got %d and %d, want 200s", first.Code, second.Code)
+	}
+	if sha256.Sum256(first.Body.Bytes()) != sha256.Sum256(second.Body.Bytes()) {
+		t.Error("two reproducible runs a second apart gave different bytes")
+	}
+}
+
+func TestGenerateFormBooleans(t *testing.T) {
+	// Multipart fields are strings, the booleans among them take true or false only
+	r := testRouter()
+	files := map[string][]byte{"form.pdf": test_form}
+	for _, test := range []struct {
+		fields map[string]string
+		status int
+	}{
+		{map[string]string{"flatten": "true"}, http.StatusOK},
+		{map[string]string{"dry_run": "false", "strict_formats": "true"}, http.StatusOK},
+		{map[string]string{"flatten": "yes"}, http.StatusBadRequest},
+		{map[string]string{"dry_run": "1"}, http.StatusBadRequest},
+		{map[string]string{"reproducible": "True"}, http.StatusBadRequest},
+		{map[string]string{"truncate_to_max_len": ""}, http.StatusBadRequest},
+	} {
+		test.fields["context_json_file"] = `{"name": "Jane"}`
+		recorder := serveMultipart(t, r, "/generate", "input_files", files, test.fields)
+		if recorder.Code != test.status {
+			t.Errorf("%v: got %d, want %d: %.200s", test.fields, recorder.Code, test.status, recorder.Body)
+		}
+		if test.status == http.StatusBadRequest {
+			checkStatus(t, fmt.Sprint(test.fields), recorder)
+		}
+	}
+}
+
+func TestGenerateResponseFilename(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(test_form)
+	}))
+	defer server.Close()
+	saved := fetch_allowed_networks
+	defer func() { fetch_allowed_networks = saved }()
+	fetch_allowed_networks, _ = parseNetworks("127.0.0.0/8")
+
+	r := testRouter()
+	for input, want := range map[string]string{
+		server.URL + "/forms/w9.pdf?token=secret": `attachment; filename="w9.pdf"`,
+		server.URL + "/":           `attachment; filename="input.pdf"`,
+		writeTestPDF(t, test_form): "",
+	} {
+		recorder := serveJSON(t, r, "/generate", map[string]interface{}{"input_files": []string{input}, "context_json_file": map[string]interface{}{"name": "Jane"}})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", input, recorder.Code, recorder.Body)
+		}
+		if want == "" {
+			want = fmt.Sprintf("attachment; filename=%q", filepath.Base(input))
+		}
+		if got := recorder.Header().Get("Content-Disposition"); got != want {
+			t.Errorf("%s: got Content-Disposition %s, want %s", input, got, want)
+		}
+	}
+}
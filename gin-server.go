@@ -1,20 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
-
-	_ "unsafe"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"pdfserver/pkg/gopdf"
 )
 
 /*
@@ -31,7 +46,6 @@ import (
 	- Structs are title case: FooBar
 	- Functions names are camel case: fooBar <--- this is bad, they won't get exported
 
-
 */
 
 //>> STRUCTS
@@ -39,12 +53,59 @@ type Response struct {
 	Status  int
 	Message []string
 	Error   []string
+	// The error code sent with Error (see errorcodes.go), "" is the one for Status
+	Code string
+	// /generate: filled, untouched, rejected and badly formatted fields per input file, context keys with no field
+	Filled      map[string][]string
+	Untouched   map[string][]string
+	FieldErrors map[string][]string
+	Warnings    map[string][]string
+	Missing     []string
+	// /generate: the values the transforms changed, by context key
+	Transformed map[string]gopdf.TransformedValue
+	// Whatever else the endpoint reports (/split's files, /pages' page_count, /merge's
+	// selector...), put in the body as it is, zero values included
+	Data gin.H
+	// Endpoints answering with a JSON body of their own (/scrape, /compare...), sent as is
+	Body interface{}
 }
 
+// JSON body of /generate, uploads come as multipart forms instead (see readRequest)
 type GenerateRequest struct {
-	Context    map[string]interface{} `json: "context_json_file"`
-	Output     string                 `json: "output_file"`
-	InputFiles []interface{}          `json: "input_files"`
+	// The field values, or the path or URL of a JSON file holding them (see loadContext)
+	Context interface{} `json:"context_json_file"`
+	// Or an FDF or XFDF file with them, a file part in multipart forms
+	FormDataFile string   `json:"form_data_file"`
+	Output       string   `json:"output_file"`
+	InputFiles   []string `json:"input_files" binding:"required"`
+	Flatten      bool     `json:"flatten"`
+	// Only report what would be filled, without writing anything
+	DryRun bool `json:"dry_run"`
+	// Reject values that don't match their field's date or number format, instead of warning
+	StrictFormats bool `json:"strict_formats"`
+	// Cut values longer than their field's MaxLen down to it, instead of rejecting them
+	TruncateToMaxLen bool `json:"truncate_to_max_len"`
+	// Write the same bytes for the same input files and context, dated reproducible_date (RFC 3339)
+	Reproducible     bool   `json:"reproducible"`
+	ReproducibleDate string `json:"reproducible_date"`
+	// Keep the tab order of the form ("preserve", the default) or put it in rows ("rows")
+	TabOrder string `json:"tab_order"`
+	// Transforms applied to the values before filling: context keys, or "*" for all of them,
+	// to a transform or a list of them (see gopdf.TransformNames)
+	Transforms interface{} `json:"transforms"`
+	// How strictly the input files are checked, see setValidation
+	Validation string `json:"validation"`
+	RepairXRef bool   `json:"repair_xref"`
+}
+
+// JSON body of /scrape
+type ScrapeRequest struct {
+	Files     []string          `json:"files" binding:"required"`
+	Password  string            `json:"password"`
+	Passwords map[string]string `json:"passwords"`
+	// How strictly the files are checked, see setValidation
+	Validation string `json:"validation"`
+	RepairXRef bool   `json:"repair_xref"`
 }
 
 type Object interface {
@@ -54,52 +115,450 @@ type Object interface {
 }
 
 func main() {
-	var port = ":6666"
+	// -addr wins over GOPDF_ADDR, which wins over the default port
+	var addr = os.Getenv("GOPDF_ADDR")
+	if addr == "" {
+		addr = ":6666"
+	}
+	flag.StringVar(&addr, "addr", addr, "host:port to listen on (env GOPDF_ADDR)")
+	// Same for how many files /scrape reads at once, 0 is one per CPU
+	var scrape_workers = 0
+	if env_workers := os.Getenv("GOPDF_SCRAPE_WORKERS"); env_workers != "" {
+		var err error
+		scrape_workers, err = strconv.Atoi(env_workers)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOPDF_SCRAPE_WORKERS %q: %v\n", env_workers, err)
+			os.Exit(2)
+		}
+	}
+	flag.IntVar(&scrape_workers, "scrape-workers", scrape_workers, "files /scrape reads concurrently, 0 is GOMAXPROCS (env GOPDF_SCRAPE_WORKERS)")
+	var log_level = os.Getenv("GOPDF_LOG_LEVEL")
+	flag.StringVar(&log_level, "log-level", log_level, "debug, info (default), warn or error (env GOPDF_LOG_LEVEL)")
+	// gin's mode, GIN_MODE wins over what GOPDF_ENV says and both default to release
+	var gin_mode = os.Getenv(gin.EnvGinMode)
+	if gin_mode == "" {
+		var err error
+		gin_mode, err = envGinMode(os.Getenv("GOPDF_ENV"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOPDF_ENV: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	flag.StringVar(&gin_mode, "gin-mode", gin_mode, "release (default), debug or test (env GIN_MODE, or GOPDF_ENV production or development)")
+	// How long in flight requests get to finish on SIGINT/SIGTERM
+	var shutdown_timeout = envDuration("GOPDF_SHUTDOWN_TIMEOUT", 30*time.Second)
+	flag.DurationVar(&shutdown_timeout, "shutdown-timeout", shutdown_timeout, "how long in flight requests get to finish on shutdown (env GOPDF_SHUTDOWN_TIMEOUT)")
+	// How long reading one file may take, and a whole /scrape or /generate request, 0 is no limit
+	var file_timeout = envDuration("GOPDF_FILE_TIMEOUT", gopdf.FileTimeout)
+	flag.DurationVar(&file_timeout, "file-timeout", file_timeout, "how long reading a single PDF may take before it's given up on, 0 is no limit (env GOPDF_FILE_TIMEOUT)")
+	var request_timeout = envDuration("GOPDF_REQUEST_TIMEOUT", 5*time.Minute)
+	flag.DurationVar(&request_timeout, "request-timeout", request_timeout, "how long a /scrape or /generate request may take before it's a 504, 0 is no limit (env GOPDF_REQUEST_TIMEOUT)")
+	// Biggest request body we read, JSON or uploads, in MB
+	var max_body_mb int64 = 50
+	if env_max := os.Getenv("GOPDF_MAX_BODY_MB"); env_max != "" {
+		var err error
+		max_body_mb, err = strconv.ParseInt(env_max, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOPDF_MAX_BODY_MB %q: %v\n", env_max, err)
+			os.Exit(2)
+		}
+	}
+	// How much of the templates read by path are kept in memory in MB, 0 turns the cache off
+	var template_cache_mb int64 = 0
+	if env_cache := os.Getenv("GOPDF_TEMPLATE_CACHE_MB"); env_cache != "" {
+		var err error
+		template_cache_mb, err = strconv.ParseInt(env_cache, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOPDF_TEMPLATE_CACHE_MB %q: %v\n", env_cache, err)
+			os.Exit(2)
+		}
+	}
+	flag.Int64Var(&template_cache_mb, "template-cache-mb", template_cache_mb, "MB of templates read by path kept in memory for the next /scrape or /generate, 0 is off (env GOPDF_TEMPLATE_CACHE_MB)")
+	// How long /generate responses are kept for retries with the same Idempotency-Key, 0 turns that off
+	var idempotency_ttl = envDuration("GOPDF_IDEMPOTENCY_TTL", 24*time.Hour)
+	flag.DurationVar(&idempotency_ttl, "idempotency-ttl", idempotency_ttl, "how long /generate responses are replayed to retries with the same Idempotency-Key, 0 is off (env GOPDF_IDEMPOTENCY_TTL)")
+	// The directory output_file and output_dir have to be inside, "" is anywhere
+	var output_root_dir = os.Getenv("GOPDF_OUTPUT_ROOT")
+	flag.StringVar(&output_root_dir, "output-root", output_root_dir, "directory every output_file and output_dir is relative to and must stay inside, default anywhere (env GOPDF_OUTPUT_ROOT)")
+	// Where temp files and spilled uploads go, a directory of ours gets made in there
+	var tmp_dir = os.Getenv("GOPDF_TMPDIR")
+	flag.StringVar(&tmp_dir, "tmpdir", tmp_dir, "directory for temp files and uploads, default os.TempDir() (env GOPDF_TMPDIR)")
+	// How many jobs of a /generate-batch are filled at once, 0 is one per CPU
+	var batch_workers = 0
+	if env_workers := os.Getenv("GOPDF_BATCH_WORKERS"); env_workers != "" {
+		var err error
+		batch_workers, err = strconv.Atoi(env_workers)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOPDF_BATCH_WORKERS %q: %v\n", env_workers, err)
+			os.Exit(2)
+		}
+	}
+	flag.IntVar(&batch_workers, "batch-workers", batch_workers, "jobs /generate-batch fills concurrently, 0 is GOMAXPROCS (env GOPDF_BATCH_WORKERS)")
+	// How often reading an input is tried when it fails in a way that may go away, and how long to wait at first
+	if env_attempts := os.Getenv("GOPDF_RETRY_ATTEMPTS"); env_attempts != "" {
+		var err error
+		retry_attempts, err = strconv.Atoi(env_attempts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOPDF_RETRY_ATTEMPTS %q: %v\n", env_attempts, err)
+			os.Exit(2)
+		}
+	}
+	flag.IntVar(&retry_attempts, "retry-attempts", retry_attempts, "times an input file or context URL is tried when it fails transiently, 1 is no retries (env GOPDF_RETRY_ATTEMPTS)")
+	retry_base_delay = envDuration("GOPDF_RETRY_DELAY", retry_base_delay)
+	flag.DurationVar(&retry_base_delay, "retry-delay", retry_base_delay, "wait before the first retry, doubling for every next one (env GOPDF_RETRY_DELAY)")
+	// Input files given as URLs: how long fetching one may take and how big it may be
+	fetch_timeout = envDuration("GOPDF_FETCH_TIMEOUT", fetch_timeout)
+	flag.DurationVar(&fetch_timeout, "fetch-timeout", fetch_timeout, "how long fetching an input file or context_json_file URL may take, 0 is no limit (env GOPDF_FETCH_TIMEOUT)")
+	var fetch_max_mb int64 = fetch_max_bytes >> 20
+	if env_max := os.Getenv("GOPDF_FETCH_MAX_MB"); env_max != "" {
+		var err error
+		fetch_max_mb, err = strconv.ParseInt(env_max, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid GOPDF_FETCH_MAX_MB %q: %v\n", env_max, err)
+			os.Exit(2)
+		}
+	}
+	flag.Int64Var(&fetch_max_mb, "fetch-max-mb", fetch_max_mb, "biggest input file fetched from a URL in MB (env GOPDF_FETCH_MAX_MB)")
+	// The internal networks input file URLs may be fetched from, none by default
+	var fetch_allow_networks = os.Getenv("GOPDF_FETCH_ALLOW_NETWORKS")
+	flag.StringVar(&fetch_allow_networks, "fetch-allow-networks", fetch_allow_networks, "comma separated loopback, private or link-local networks (10.1.0.0/16) or addresses URLs may be fetched from, default none (env GOPDF_FETCH_ALLOW_NETWORKS)")
+	flag.Int64Var(&max_body_mb, "max-body-mb", max_body_mb, "biggest request body accepted in MB, bigger ones are a 413 (env GOPDF_MAX_BODY_MB)")
+	// The origins browsers may call the API from besides its own, none by default
+	var cors_origins = os.Getenv("GOPDF_CORS_ORIGINS")
+	flag.StringVar(&cors_origins, "cors-origins", cors_origins, "comma separated origins browsers may call the API from (https://app.example.com), * for any, default none (env GOPDF_CORS_ORIGINS)")
+	flag.Parse()
+
+	if scrape_workers < 0 {
+		fmt.Fprintf(os.Stderr, "invalid scrape workers %d: must be 0 or more\n", scrape_workers)
+		os.Exit(2)
+	}
+	gopdf.ScrapeWorkers = scrape_workers
+	if batch_workers < 0 {
+		fmt.Fprintf(os.Stderr, "invalid batch workers %d: must be 0 or more\n", batch_workers)
+		os.Exit(2)
+	}
+	generate_batch_workers = batch_workers
+	if fetch_max_mb <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid fetch size %d: must be at least 1 MB\n", fetch_max_mb)
+		os.Exit(2)
+	}
+	fetch_max_bytes = fetch_max_mb << 20
+	if retry_attempts < 1 {
+		fmt.Fprintf(os.Stderr, "invalid retry attempts %d: must be at least 1\n", retry_attempts)
+		os.Exit(2)
+	}
+	if retry_base_delay < 0 {
+		fmt.Fprintf(os.Stderr, "invalid retry delay %v: must be 0 or more\n", retry_base_delay)
+		os.Exit(2)
+	}
+	gopdf.FileTimeout = file_timeout
+	if max_body_mb <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid max body size %d: must be at least 1 MB\n", max_body_mb)
+		os.Exit(2)
+	}
+	if template_cache_mb < 0 {
+		fmt.Fprintf(os.Stderr, "invalid template cache size %d: must be 0 or more\n", template_cache_mb)
+		os.Exit(2)
+	}
+	gopdf.SetTemplateCacheSize(template_cache_mb << 20)
+
+	err := validateAddr(addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid listen address %q: %v\n", addr, err)
+		os.Exit(2)
+	}
+	allowed_origins, err := parseCORSOrigins(cors_origins)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid CORS origins: %v\n", err)
+		os.Exit(2)
+	}
+	fetch_allowed_networks, err = parseNetworks(fetch_allow_networks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid fetch allow networks: %v\n", err)
+		os.Exit(2)
+	}
 
-	// Routes
-	r := gin.Default()
+	logger, err := newLogger(log_level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log level: %v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
 
-	r.GET("/healthcheck", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"Health": "Good!"})
-	})
+	if gin_mode != gin.ReleaseMode && gin_mode != gin.DebugMode && gin_mode != gin.TestMode {
+		fmt.Fprintf(os.Stderr, "invalid gin mode %q: must be release, debug or test\n", gin_mode)
+		os.Exit(2)
+	}
+	gin.SetMode(gin_mode)
+	// Everything gin prints goes through our logger, the debug banner goes nowhere
+	gin.DefaultWriter = io.Discard
+	gin.DefaultErrorWriter = io.Discard
+	gin.DebugPrintRouteFunc = func(method string, path string, handler string, handlers int) {
+		logger.Debug("route", "method", method, "path", path, "handler", handler, "handlers", handlers)
+	}
+
+	err = setupTempDir(tmp_dir, logger)
+	if err != nil {
+		logger.Error("setting up temp dir", "dir", tmp_dir, "error", err.Error())
+		os.Exit(1)
+	}
+	defer removeTempDir()
+
+	err = setupOutputRoot(output_root_dir)
+	if err != nil {
+		logger.Error("setting up output root", "dir", output_root_dir, "error", err.Error())
+		os.Exit(1)
+	}
+	if output_root == "" {
+		logger.Warn("no output root, requests can write anywhere this process can, set GOPDF_OUTPUT_ROOT to restrict them")
+	}
+	if containsString(allowed_origins, "*") {
+		logger.Warn("CORS allows any origin, scripts on every website can call the API from their visitors' browsers")
+	}
+
+	// Binding errors name the JSON field, not the Go one
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			return strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		})
+	}
+
+	checkPDFCPU(logger)
+
+	r := newRouter(logger, allowed_origins, max_body_mb<<20, request_timeout, idempotency_ttl)
+	err = serve(r, addr, shutdown_timeout, logger)
+	if err != nil {
+		logger.Error("server stopped", "error", err.Error())
+		removeTempDir()
+		os.Exit(1)
+	}
+}
+
+func newRouter(logger *slog.Logger, allowed_origins []string, max_body int64, request_timeout time.Duration, idempotency_ttl time.Duration) *gin.Engine {
+	// The routes and the middleware every request goes through, bodies up to max_body bytes
+	// gin.Default() would add gin's own logger and plain text recovery on top of ours
+	r := gin.New()
+	r.Use(requestMetrics(), requestLogging(logger), recoverPanics(), corsPolicy(allowed_origins), compressResponse(), limitBody(max_body), decompressBody(max_body), requestTimeout(request_timeout))
+
+	r.GET("/healthcheck", healthcheckHandler)
+
+	r.GET("/livez", livezHandler)
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	r.POST("/scrape", scrapeHandler)
 
-	r.POST("/generate", generateHandler)
+	// Keys are per endpoint, one store does for both
+	idempotency_store := newIdempotencyStore(idempotency_ttl)
+	r.POST("/generate", idempotent(idempotency_store), generateHandler)
+
+	r.POST("/generate-batch", idempotent(idempotency_store), generateBatchHandler)
+
+	r.POST("/merge", mergeHandler)
+
+	r.POST("/validate", validateHandler)
+
+	r.POST("/split", splitHandler)
+
+	r.POST("/extract-text", extractTextHandler)
+
+	r.POST("/rotate", rotateHandler)
+
+	r.POST("/encrypt", encryptHandler)
+
+	r.POST("/decrypt", decryptHandler)
+
+	r.POST("/stamp", stampHandler)
+
+	r.POST("/unstamp", unstampHandler)
+
+	r.POST("/optimize", optimizeHandler)
+
+	r.POST("/linearize", linearizeHandler)
+
+	r.POST("/info", infoHandler)
+
+	r.POST("/compare", compareHandler)
+
+	r.POST("/pages", pagesHandler)
+
+	r.POST("/pages/insert-blank", insertBlankHandler)
+
+	r.POST("/extract-images", extractImagesHandler)
+
+	r.POST("/export-form", exportFormHandler)
+
+	r.POST("/nup", nupHandler)
+
+	r.POST("/booklet", bookletHandler)
+
+	r.POST("/thumbnail", thumbnailHandler)
+
+	r.POST("/trim", trimHandler)
+
+	r.POST("/metadata", metadataHandler)
+
+	r.POST("/overlay", overlayHandler)
+
+	r.POST("/repair", repairHandler)
+
+	r.POST("/append", appendHandler)
+
+	r.POST("/rename-fields", renameFieldsHandler)
+
+	r.POST("/has-fields", hasFieldsHandler)
+
+	r.POST("/reset-form", resetFormHandler)
+
+	r.POST("/attachments", attachmentsHandler)
+
+	r.POST("/attach", attachHandler)
 
-	r.Run(port)
+	r.POST("/ocr-ready", ocrReadyHandler)
+
+	r.POST("/convert", convertHandler)
+
+	r.POST("/annotate", annotateHandler)
+
+	r.POST("/portfolio", portfolioHandler)
+
+	return r
 }
 
 //>> HANDLERS
 func generateHandler(c *gin.Context) {
-	fmt.Println("in generate")
-
-	//Using jsonDecoder is best practice since it reads the streaming json data (which means it can error out immediately)
-	var json_data map[string]interface{}
-
-	//Using jsonDecoder is best practice since it reads the streaming json data (which means it can error out immediately)
-	decoder := json.NewDecoder(c.Request.Body)
-	err := decoder.Decode(&json_data)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+	var req GenerateRequest
+	var input_files []gopdf.InputFile
+	var ok bool
+	defer cleanupRequest(c)
+	if c.ContentType() == "multipart/form-data" {
+		var json_data map[string]interface{}
+		var uploaded_files []gopdf.InputFile
+		json_data, uploaded_files, ok = readRequest(c, "input_files")
+		if !ok {
+			return
+		}
+		input_files = uploaded_files
+		req, ok = formGenerateRequest(c, json_data)
+		if !ok {
+			return
+		}
 	} else {
-		// Massage data for /generate fn
-		// Here we are converting from interface{} into an array of string interface{}
-		context, ok := json_data["context_json_file"].(map[string]interface{})
+		if !bindSchemaJSON(c, generate_schema, &req) {
+			return
+		}
+		input_files, ok = inputFiles(c, req.InputFiles)
+		if !ok {
+			return
+		}
+		if !useValidation(c, input_files, gopdf.ValidationOptions{Mode: req.Validation, RepairXRef: req.RepairXRef}) {
+			return
+		}
+		requestLogger(c).Info("read request", "files", req.InputFiles)
+	}
+	context, ok := generateContext(c, req)
+	if !ok {
+		return
+	}
+	if err := gopdf.CheckTabOrder(req.TabOrder); err != nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %v", err)}})
+		return
+	}
+	transforms, ok := transformsParam(c, req.Transforms)
+	if !ok {
+		return
+	}
+	options := gopdf.GenerateOptions{Flatten: req.Flatten, DryRun: req.DryRun, StrictFormats: req.StrictFormats, TruncateToMaxLen: req.TruncateToMaxLen, Reproducible: req.Reproducible, TabOrder: req.TabOrder, Transforms: transforms}
+	if req.ReproducibleDate != "" {
+		date, err := time.Parse(time.RFC3339, req.ReproducibleDate)
+		if err != nil || date.Year() < 1000 || date.Year() > 9999 {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. reproducible_date must be an RFC 3339 date like 2024-01-31T00:00:00Z, got %q", req.ReproducibleDate)}})
+			return
+		}
+		options.ReproducibleDate = date
+	}
+
+	// Without an output_file the filled PDF goes back to the caller in the response body
+	out_path := req.Output
+	if len(input_files) == 0 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. no input files provided"}})
+		return
+	}
+	if req.DryRun {
+		// Same report as a real run, output_file isn't looked at
+		result, err := gopdf.Generate(c.Request.Context(), context, "", input_files, nil, options)
+		if err != nil {
+			generateError(c, result, err)
+			return
+		}
+		filled_count := 0
+		for _, filled := range result.Filled {
+			filled_count += len(filled)
+		}
+		response := generateResponse(result)
+		response.Status = http.StatusOK
+		response.Message = []string{fmt.Sprintf("Dry run, %d fields would be filled", filled_count)}
+		if len(result.FieldErrors) > 0 {
+			response.Message = append(response.Message, "some values would be rejected, see field_errors")
+		}
+		if len(result.Warnings) > 0 {
+			response.Message = append(response.Message, "some values have warnings, see warnings")
+		}
+		sendResponse(c, response)
+		return
+	}
+	if out_path == "" && len(input_files) > 1 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. output_file is required when filling more than one input file"}})
+		return
+	}
+	if out_path != "" {
+		out_path, ok = outputPath(c, "output_file", out_path)
+		if !ok {
+			return
+		}
+	}
+	if out_path == "" {
+		// Buffer the whole PDF so a failure can still be reported as JSON
+		var pdf_buf bytes.Buffer
+		result, err := gopdf.Generate(c.Request.Context(), context, "", input_files, &pdf_buf, options)
+		if err != nil {
+			generateError(c, result, err)
+			return
+		}
+		// The name a file of the input gets everywhere else in /generate, a URL's without its query
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", gopdf.OutputNames(input_files)[0]))
+		c.Data(http.StatusOK, "application/pdf", pdf_buf.Bytes())
+		return
+	}
+	var result gopdf.GenerateResult
+	if isS3URL(out_path) {
+		result, ok = generateS3(c, context, out_path, input_files, options)
 		if !ok {
-			panic("inner map is not a map!")
+			return
 		}
-		// Here we are converting from interface{} into []interface{} into []string
-		files_interface := json_data["input_files"].([]interface{})
-		files_list := make([]string, len(files_interface))
-		for i, v := range files_interface {
-			files_list[i] = v.(string)
+	} else {
+		var err error
+		result, err = gopdf.Generate(c.Request.Context(), context, out_path, input_files, nil, options)
+		if err != nil {
+			generateError(c, result, err)
+			return
 		}
-		var out_path = fmt.Sprintf("%v", json_data["output_file"])
-		generate(context, out_path, files_list)
+	}
 
-		//c.JSON(http.StatusOK, data_struct)
+	filled_count := 0
+	for _, filled := range result.Filled {
+		filled_count += len(filled)
+	}
+	response := generateResponse(result)
+	response.Status = http.StatusOK
+	response.Message = []string{fmt.Sprintf("Filled %d fields into %s", filled_count, out_path)}
+	if len(result.FieldErrors) > 0 {
+		response.Message = append(response.Message, "some values were rejected, see field_errors")
 	}
+	if len(result.Warnings) > 0 {
+		response.Message = append(response.Message, "some values have warnings, see warnings")
+	}
+	sendResponse(c, response)
 
 }
 
@@ -108,471 +567,2679 @@ func scrapeHandler(c *gin.Context) {
 		This handler should only handle getting data from request
 		and sending it to scrape fn
 	*/
-	fmt.Println("in scrape")
+	var input_files []gopdf.InputFile
 
-	// parse request body
-	// map[keyType]valueType <---they're like dictionaries
-	var json_data map[string]interface{}
+	if c.ContentType() == "multipart/form-data" {
+		// Uploaded PDFs come as "files" file parts
+		form, err := multipartForm(c)
+		if err != nil {
+			errorHandler(0, err, c)
+			return
+		}
+		defer cleanupRequest(c)
+		json_data, err := formJSON(form)
+		if err != nil {
+			errorHandler(0, err, c)
+			return
+		}
+		input_files = measuredFiles(uploadedFiles(form, "files"))
+		if !checkUploads(c, input_files) {
+			return
+		}
+		if !setPasswords(c, json_data, input_files) || !setValidation(c, json_data, input_files) {
+			return
+		}
+	} else {
+		var req ScrapeRequest
+		if !bindSchemaJSON(c, scrape_schema, &req) {
+			return
+		}
+		var ok bool
+		input_files, ok = inputFiles(c, req.Files)
+		if !ok {
+			return
+		}
+		usePasswords(input_files, req.Password, req.Passwords)
+		if !useValidation(c, input_files, gopdf.ValidationOptions{Mode: req.Validation, RepairXRef: req.RepairXRef}) {
+			return
+		}
+	}
+	requestLogger(c).Info("scraping", "files", fileNames(input_files))
+	if wantsNDJSON(c) {
+		streamScrape(c, input_files)
+		return
+	}
 
-	//Using jsonDecoder is best practice since it reads the streaming json data (which means it can error out immediately)
-	decoder := json.NewDecoder(c.Request.Body)
-	err := decoder.Decode(&json_data)
+	acro_fields, err := gopdf.Scrape(c.Request.Context(), input_files)
+	if errors.Is(err, context.DeadlineExceeded) {
+		sendResponse(c, Response{Status: http.StatusGatewayTimeout, Error: []string{"Gateway Timeout. the files took too long to scrape"}})
+		return
+	}
+	var file_errors gopdf.FileErrors
+	if errors.As(err, &file_errors) && len(file_errors) == len(input_files) {
+		// Not a single file could be read, there's nothing partial to return
+		sendResponse(c, fileErrorsResponse(file_errors))
+		return
+	}
+	if err != nil && file_errors == nil {
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return
+	}
+
+	// ?detailed=true returns the full field objects, otherwise just the names
+	body := gin.H{"acro_form_fields": acro_fields}
+	if c.Query("detailed") != "true" {
+		field_names := make([]string, len(acro_fields))
+		for i, field := range acro_fields {
+			field_names[i] = field.Name
+		}
+		body["acro_form_fields"] = field_names
+	}
+	// The files that failed don't spoil the others, their errors and codes come along keyed by file name
+	if len(file_errors) > 0 {
+		failed := make(map[string]string, len(file_errors))
+		codes := make(map[string]string, len(file_errors))
+		for _, file_error := range file_errors {
+			if _, found := codes[file_error.Name]; !found {
+				codes[file_error.Name] = fileErrorCode(file_error)
+			}
+			if message, found := failed[file_error.Name]; found {
+				// The same name uploaded twice
+				failed[file_error.Name] = fmt.Sprintf("%s; %v (idx: %d)", message, file_error.Err, file_error.Idx)
+				continue
+			}
+			failed[file_error.Name] = fmt.Sprintf("%v (idx: %d)", file_error.Err, file_error.Idx)
+		}
+		body["errors"] = failed
+		body["error_codes"] = codes
+		requestLogger(c).Warn("some files could not be scraped", "errors", failed)
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: body})
+}
+
+func mergeHandler(c *gin.Context) {
+	/*
+		Merges input_files (in order) into output_file, form fields included.
+		With selectors ([{"file": ..., "pages": ...}]) only the pages they pick go in,
+		in their order, and a single input file is enough.
+		input_files are paths or URLs in JSON, or file parts in multipart/form-data.
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	_, has_selectors := json_data["selectors"]
+	if has_selectors && len(input_files) == 0 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. input_files must be a list of file paths"}})
+		return
+	}
+	if !has_selectors && len(input_files) < 2 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. input_files must be a list of at least two file paths, got %d", len(input_files))}})
+		return
+	}
+	if !setPasswords(c, json_data, input_files) {
+		return
+	}
+	var options gopdf.MergeOptions
+	if v, found := json_data["tab_order"]; found {
+		options.TabOrder, ok = v.(string)
+		if err := gopdf.CheckTabOrder(options.TabOrder); !ok || err != nil {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. tab_order must be %q or %q, got %v", gopdf.TabOrderPreserve, gopdf.TabOrderRows, v)}})
+			return
+		}
+	}
+	switch dedupe := json_data["dedupe"].(type) {
+	case nil:
+	case bool:
+		options.Dedupe = dedupe
+	case string:
+		// Multipart forms
+		options.Dedupe = dedupe == "true"
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. dedupe must be a boolean, got %s", jsonType(dedupe))}})
+		return
+	}
+	var selectors []gopdf.PageSelector
+	if has_selectors {
+		// A selector's file is one of the paths or upload names
+		selectors, ok = pageSelectorsParam(c, json_data["selectors"], fileNames(input_files))
+		if !ok {
+			return
+		}
+	}
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	if has_selectors {
+		requestLogger(c).Info("merging pages", "files", fileNames(input_files), "selectors", len(selectors), "output_file", out_path)
+		result, err := gopdf.MergePages(input_files, out_path, selectors, options)
+		var selector_error *gopdf.SelectorError
+		if errors.As(err, &selector_error) {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. " + selector_error.Error()}, Data: gin.H{"selector": selector_error.Idx}})
+			return
+		}
+		var file_errors gopdf.FileErrors
+		if errors.As(err, &file_errors) {
+			sendResponse(c, fileErrorsResponse(file_errors))
+			return
+		}
+		if err != nil {
+			fileResponse(c, err, "")
+			return
+		}
+		sendResponse(c, mergeResponse(result, fmt.Sprintf("Merged %d pages of %d files into %s", result.PageCount, len(input_files), out_path)))
+		return
+	}
+
+	requestLogger(c).Info("merging", "files", fileNames(input_files), "output_file", out_path)
+	result, err := gopdf.Merge(input_files, out_path, options)
+	var file_errors gopdf.FileErrors
+	if errors.As(err, &file_errors) {
+		sendResponse(c, fileErrorsResponse(file_errors))
+		return
+	}
 	if err != nil {
-		errorHandler(0, err, c)
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return
 	}
+	sendResponse(c, mergeResponse(result, fmt.Sprintf("Merged %d files into %s", len(input_files), out_path)))
+}
 
-	acro_fields := scrape(json_data, c)
-	if acro_fields != nil {
-		c.JSON(http.StatusOK, gin.H{"acro_form_fields": acro_fields})
-	} else {
-		c.JSON(http.StatusInternalServerError, "There was a problem reading/writing one or more of the specified PDF files.")
+func mergeResponse(result gopdf.MergeResult, message string) Response {
+	// The 200 of /merge, with what dedupe saved when it was asked for
+	response := Response{Status: http.StatusOK, Message: []string{message}}
+	if result.Dedupe != nil {
+		response.Data = gin.H{"dedupe": result.Dedupe}
+		response.Message = append(response.Message, fmt.Sprintf("%d duplicate streams collapsed, %d bytes saved", result.Dedupe.Streams, result.Dedupe.BytesSaved))
+	}
+	return response
+}
+
+func appendHandler(c *gin.Context) {
+	/*
+		Concatenates input_files (in order) into output_file without merging their forms:
+		forms "drop" (the default) flattens them into the pages, "rename" keeps every
+		file's fields under file1., file2. and so on so they can't collide.
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	if len(input_files) < 2 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. input_files must be a list of at least two file paths"}})
+		return
+	}
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	forms := gopdf.AppendDropForms
+	if v, found := json_data["forms"]; found {
+		forms, ok = v.(string)
+		if !ok || (forms != gopdf.AppendDropForms && forms != gopdf.AppendRenameForms) {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. forms must be %q or %q, got %v", gopdf.AppendDropForms, gopdf.AppendRenameForms, v)}})
+			return
+		}
+	}
+
+	requestLogger(c).Info("appending", "files", fileNames(input_files), "output_file", out_path, "forms", forms)
+	err := gopdf.Append(input_files, out_path, forms)
+	var file_errors gopdf.FileErrors
+	if errors.As(err, &file_errors) {
+		sendResponse(c, fileErrorsResponse(file_errors))
+		return
+	}
+	fileResponse(c, err, fmt.Sprintf("Appended %d files into %s", len(input_files), out_path))
+}
+
+func renameFieldsHandler(c *gin.Context) {
+	/*
+		Renames the fields of input_file into output_file, renames maps fully qualified
+		old names to new ones: {"name": "applicant.name", "address.zip": "address.postcode"}.
+		Names that aren't fields or would collide are a 400 listing all of them.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	renames_object, ok := json_data["renames"].(map[string]interface{})
+	if !ok {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. renames must be an object mapping old field names to new ones, got %s", jsonType(json_data["renames"]))}})
+		return
+	}
+	if len(renames_object) == 0 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. renames must not be empty"}})
+		return
+	}
+	renames := make(map[string]string, len(renames_object))
+	for old_name, v := range renames_object {
+		new_name, ok := v.(string)
+		if !ok {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. the new name of %q must be a string, got %s", old_name, jsonType(v))}})
+			return
+		}
+		renames[old_name] = new_name
+	}
+
+	err := gopdf.RenameFields(in_file, out_path, renames)
+	var rename_error *gopdf.RenameError
+	if errors.As(err, &rename_error) {
+		messages := make([]string, len(rename_error.Problems))
+		for i, problem := range rename_error.Problems {
+			messages[i] = "Bad Request. " + problem
+		}
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: messages})
+		return
+	}
+	fileResponse(c, err, fmt.Sprintf("Renamed %d fields of %s into %s", len(renames), in_file.Name, out_path))
+}
+
+func hasFieldsHandler(c *gin.Context) {
+	/*
+		Says for every name in fields whether input_file has a field by that fully qualified
+		name and its type, without reading the rest of the form like /scrape does.
+		Names are matched case sensitively, like PDF readers match them.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	if json_data["fields"] == nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. fields is required"}})
+		return
+	}
+	names, ok := fieldNamesParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	results, err := gopdf.HasFields(in_file, names)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	all_exist := true
+	for _, result := range results {
+		all_exist = all_exist && result.Exists
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"fields": results, "all_exist": all_exist}})
+}
+
+func resetFormHandler(c *gin.Context) {
+	/*
+		Clears the fields of input_file back to their defaults (or empty, or Off) into
+		output_file, only the ones named in fields when it's given
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var names []string
+	if json_data["fields"] != nil {
+		if names, ok = fieldNamesParam(c, json_data); !ok {
+			return
+		}
+	}
+
+	reset, err := gopdf.ResetForm(in_file, out_path, names)
+	if errors.Is(err, gopdf.ErrFieldsNotFound) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request %v", err.Error())}, Code: code_field_not_found})
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"message": fmt.Sprintf("Reset %d fields of %s into %s", len(reset), in_file.Name, out_path), "reset": reset}})
+}
+
+func attachmentsHandler(c *gin.Context) {
+	/*
+		Lists the files embedded in input_file (operation list, the default) or extracts
+		them (operation extract): into output_dir, only the one called name when it's given,
+		or without an output_dir the one called name comes back as the response body
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	operation, _ := json_data["operation"].(string)
+	if operation == "" {
+		operation = "list"
+	}
+	if operation != "list" && operation != "extract" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. operation must be list or extract, got %v", json_data["operation"])}})
+		return
+	}
+	name, is_string := json_data["name"].(string)
+	if json_data["name"] != nil && (!is_string || name == "") {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. name must be the file name of an attachment, got %s", jsonType(json_data["name"]))}})
+		return
+	}
+	not_found := func(err error) {
+		sendResponse(c, Response{Status: http.StatusNotFound, Error: []string{fmt.Sprintf("Not Found. %v in %s", err, in_file.Name)}, Code: code_attachment_not_found})
+	}
+
+	if operation == "extract" && json_data["output_dir"] == nil {
+		if name == "" {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. extract needs an output_dir, or a name to return that attachment"}})
+			return
+		}
+		// Buffered so a failure can still be reported as JSON
+		var attachment_buf bytes.Buffer
+		attachment, err := gopdf.ReadAttachment(in_file, name, &attachment_buf)
+		if errors.Is(err, gopdf.ErrAttachmentNotFound) {
+			not_found(err)
+			return
+		}
+		if err != nil {
+			fileResponse(c, err, "")
+			return
+		}
+		content_type := attachment.MimeType
+		if content_type == "" {
+			content_type = "application/octet-stream"
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(attachment.Name)))
+		c.Data(http.StatusOK, content_type, attachment_buf.Bytes())
+		return
+	}
+
+	var attachments []gopdf.Attachment
+	var err error
+	if operation == "list" {
+		attachments, err = gopdf.ListAttachments(in_file)
+	} else {
+		out_dir, ok := outputDirParam(c, json_data)
+		if !ok {
+			return
+		}
+		var names []string
+		if name != "" {
+			names = []string{name}
+		}
+		attachments, err = gopdf.ExtractAttachments(in_file, out_dir, names)
+	}
+	if errors.Is(err, gopdf.ErrAttachmentNotFound) {
+		not_found(err)
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	var message string
+	switch {
+	case len(attachments) == 0:
+		message = fmt.Sprintf("%s has no attachments", in_file.Name)
+	case operation == "list":
+		message = fmt.Sprintf("%s has %d attachments", in_file.Name, len(attachments))
+	default:
+		message = fmt.Sprintf("Extracted %d attachments of %s", len(attachments), in_file.Name)
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"message": message, "attachments": attachments}})
+}
+
+func attachHandler(c *gin.Context) {
+	/*
+		Embeds attachment (a file part in multipart/form-data, or a server side path or
+		URL in JSON) in input_file as name (the attachment's own file name by default)
+		and writes it to output_file. A name that's taken is a 409 unless overwrite is set.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var attachment gopdf.InputFile
+	if c.Request.MultipartForm != nil {
+		if attachments := uploadedFiles(c.Request.MultipartForm, "attachment"); len(attachments) == 1 {
+			attachment = attachments[0]
+		}
+	} else if location, _ := json_data["attachment"].(string); location != "" {
+		if attachment, ok = attachmentFile(c, location); !ok {
+			return
+		}
+	}
+	if attachment.Open == nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. attachment is required, a file part or a path"}})
+		return
+	}
+	var options gopdf.AttachOptions
+	for key, value := range map[string]*string{"name": &options.Name, "mime_type": &options.MimeType, "description": &options.Description} {
+		v := json_data[key]
+		if v == nil {
+			continue
+		}
+		text, is_string := v.(string)
+		if !is_string {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s must be a string, got %s", key, jsonType(v))}})
+			return
+		}
+		*value = text
+	}
+	if strings.ContainsAny(options.Name, "/\\") {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. name must be a file name without directories, got %q", options.Name)}})
+		return
+	}
+	if options.MimeType != "" {
+		if _, _, err := mime.ParseMediaType(options.MimeType); err != nil {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. mime_type must be a MIME type like application/pdf, got %q", options.MimeType)}})
+			return
+		}
+	}
+	switch overwrite := json_data["overwrite"].(type) {
+	case nil:
+	case bool:
+		options.Overwrite = overwrite
+	case string:
+		// Multipart forms
+		options.Overwrite = overwrite == "true"
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. overwrite must be a boolean, got %s", jsonType(overwrite))}})
+		return
+	}
+
+	attached, err := gopdf.AttachFile(in_file, out_path, attachment, options)
+	if errors.Is(err, gopdf.ErrAttachmentExists) {
+		sendResponse(c, Response{Status: http.StatusConflict, Error: []string{fmt.Sprintf("Conflict. %v in %s, set overwrite to replace it", err, in_file.Name)}, Code: code_attachment_exists})
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"message": fmt.Sprintf("Attached %s to %s into %s", attached.Name, in_file.Name, out_path), "attachment": attached}})
+}
+
+func validateHandler(c *gin.Context) {
+	/*
+		Runs pdfcpu's validation on every input file and reports the result per file.
+		A file failing validation is a normal result here, so this is a 200 either way.
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	mode, _ := json_data["mode"].(string)
+	conf := pdfcpu.NewDefaultConfiguration()
+	switch mode {
+	case "strict":
+		conf.ValidationMode = pdfcpu.ValidationStrict
+	case "relaxed", "":
+		conf.ValidationMode = pdfcpu.ValidationRelaxed
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. mode must be strict or relaxed, got %v", json_data["mode"])}})
+		return
+	}
+
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"results": gopdf.Validate(input_files, conf)}})
+}
+
+func splitHandler(c *gin.Context) {
+	/*
+		Splits input_file into files of span pages each (default 1) inside output_dir
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_dir, ok := outputDirParam(c, json_data)
+	if !ok {
+		return
+	}
+	span, err := intParam(json_data, "span", 1)
+	if err != nil || span < 1 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. span must be a whole number of pages, 1 or more, got %v", json_data["span"])}})
+		return
+	}
+
+	out_paths, err := gopdf.Split(in_file, out_dir, span)
+	var file_error *gopdf.FileError
+	if errors.As(err, &file_error) {
+		sendResponse(c, fileErrorsResponse(gopdf.FileErrors{file_error}))
+		return
+	}
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{fmt.Sprintf("Split %s into %d files", in_file.Name, len(out_paths))}, Data: gin.H{"files": out_paths}})
+}
+
+func extractTextHandler(c *gin.Context) {
+	/*
+		Returns the text of every page of input_files, or only of the pages selection ("1-3,5")
+			{"text": {"foo.pdf": ["page 1 text", "page 2 text"]}}
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	pages, ok := pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	texts, err := gopdf.ExtractText(input_files, pages)
+	var file_errors gopdf.FileErrors
+	if errors.As(err, &file_errors) {
+		sendResponse(c, fileErrorsResponse(file_errors))
+		return
+	}
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"text": texts}})
+}
+
+func annotateHandler(c *gin.Context) {
+	/*
+		Adds a text note, a highlight or a link to page (1 by default) of input_file
+		and writes it to output_file. rect is [llx, lly, urx, ury] in PDF points, inside
+		the page's MediaBox. A note has contents, a highlight may, a link needs a url.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var annotation gopdf.Annotation
+	annotation.Type, _ = json_data["type"].(string)
+	if !containsString(gopdf.AnnotationTypes, annotation.Type) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. type must be one of %s, got %v", strings.Join(gopdf.AnnotationTypes, ", "), json_data["type"])}})
+		return
+	}
+	var err error
+	annotation.Page, err = intParam(json_data, "page", 1)
+	if err != nil || annotation.Page < 1 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. page must be a page number, 1 or more, got %v", json_data["page"])}})
+		return
+	}
+	rect, err := floatListParam(json_data, "rect")
+	if err != nil || len(rect) != 4 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. rect must be a list of 4 numbers, [llx, lly, urx, ury], got %v", json_data["rect"])}})
+		return
+	}
+	copy(annotation.Rect[:], rect)
+	annotation.Contents, _ = json_data["contents"].(string)
+	annotation.URL, _ = json_data["url"].(string)
+	switch annotation.Type {
+	case "text":
+		if annotation.Contents == "" {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. contents is required for a text note"}})
+			return
+		}
+	case "link":
+		parsed, err := url.Parse(annotation.URL)
+		if annotation.URL == "" || err != nil || parsed.Scheme == "" {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. url must be an absolute URL for a link, got %q", annotation.URL)}})
+			return
+		}
+	}
+
+	err = gopdf.Annotate(in_file, out_path, annotation)
+	if errors.Is(err, gopdf.ErrInvalidAnnotation) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s: %v", in_file.Name, err)}})
+		return
+	}
+	fileResponse(c, err, fmt.Sprintf("Added a %s annotation to page %d of %s into %s", annotation.Type, annotation.Page, in_file.Name, out_path))
+}
+
+func portfolioHandler(c *gin.Context) {
+	/*
+		Embeds input_files in a new PDF portfolio written to output_file. fields are the
+		columns viewers list the files with (filename, size and modified by default) and
+		view how they show them, details (the default), tile or hidden.
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	if len(input_files) == 0 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. input_files must be a list of at least one file path"}})
+		return
+	}
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var options gopdf.PortfolioOptions
+	switch fields := json_data["fields"].(type) {
+	case nil:
+	case []interface{}:
+		// Multipart forms have one fields part per field, formJSON lists them
+		for _, field := range fields {
+			name, _ := field.(string)
+			options.Fields = append(options.Fields, name)
+		}
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. fields must be a list of field names, got %s", jsonType(fields))}})
+		return
+	}
+	for _, field := range options.Fields {
+		if !containsString(gopdf.PortfolioFields, field) {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. fields must be some of %s, got %v", strings.Join(gopdf.PortfolioFields, ", "), json_data["fields"])}})
+			return
+		}
+	}
+	if v, found := json_data["view"]; found {
+		options.View, _ = v.(string)
+		if !containsString(gopdf.PortfolioViews, options.View) {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. view must be one of %s, got %v", strings.Join(gopdf.PortfolioViews, ", "), v)}})
+			return
+		}
+	}
+
+	requestLogger(c).Info("making portfolio", "files", fileNames(input_files), "output_file", out_path)
+	attachments, err := gopdf.Portfolio(input_files, out_path, options)
+	var file_errors gopdf.FileErrors
+	if errors.As(err, &file_errors) {
+		sendResponse(c, fileErrorsResponse(file_errors))
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"message": fmt.Sprintf("Made a portfolio of %d files into %s", len(attachments), out_path), "files": attachments}})
+}
+
+func convertHandler(c *gin.Context) {
+	/*
+		Rewrites input_file as a PDF of version ("1.4", 1.4 works too) into output_file.
+		Newer features are taken out where they can be, the response says which ones
+		were and which ones couldn't be.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var version string
+	switch v := json_data["version"].(type) {
+	case string:
+		version = v
+	case float64:
+		version = strconv.FormatFloat(v, 'f', 1, 64)
+	case nil:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. version is required, one of %s", strings.Join(gopdf.ConvertVersions, ", "))}})
+		return
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. version must be a string, got %s", jsonType(v))}})
+		return
+	}
+
+	result, err := gopdf.Convert(in_file, out_path, version)
+	switch {
+	case errors.Is(err, gopdf.ErrUnsupportedVersion):
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %v, version must be one of %s", err, strings.Join(gopdf.ConvertVersions, ", "))}})
+		return
+	case errors.Is(err, gopdf.ErrConvertEncrypted):
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s: %v", in_file.Name, err)}})
+		return
+	case err != nil:
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{
+		"message":      fmt.Sprintf("Converted %s from PDF %s to %s into %s", in_file.Name, result.FromVersion, result.Version, out_path),
+		"from_version": result.FromVersion,
+		"version":      result.Version,
+		"removed":      result.Removed,
+		"unconverted":  result.Unconverted,
+	}})
+}
+
+func ocrReadyHandler(c *gin.Context) {
+	/*
+		Says per page of input_files (or only of the pages selection) whether it has
+		extractable text and how much of it images cover. Pages without text that images
+		cover min_coverage (0.5 by default) or more of are OCR candidates.
+			{"pages": {"foo.pdf": [{"page": 1, "has_text": false, "image_coverage": 0.97, "ocr_candidate": true}]},
+			 "ocr_candidates": {"foo.pdf": [1]}}
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	pages, ok := pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+	min_coverage, err := floatParam(json_data, "min_coverage")
+	if err != nil || (min_coverage != nil && (*min_coverage < 0 || *min_coverage > 1)) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. min_coverage must be a number between 0 and 1, got %v", json_data["min_coverage"])}})
+		return
+	}
+	if min_coverage == nil {
+		default_coverage := gopdf.DefaultOCRCoverage
+		min_coverage = &default_coverage
+	}
+
+	reports, err := gopdf.OCRReady(input_files, pages, *min_coverage)
+	var file_errors gopdf.FileErrors
+	if errors.As(err, &file_errors) {
+		sendResponse(c, fileErrorsResponse(file_errors))
+		return
+	}
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return
+	}
+	candidates := make(map[string][]int)
+	for file_name, report := range reports {
+		candidates[file_name] = make([]int, 0)
+		for _, page := range report {
+			if page.OCRCandidate {
+				candidates[file_name] = append(candidates[file_name], page.Page)
+			}
+		}
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"pages": reports, "ocr_candidates": candidates}})
+}
+
+func rotateHandler(c *gin.Context) {
+	/*
+		Rotates the pages selection of input_file (every page by default) by rotation
+		degrees clockwise and writes it to output_file
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	rotation, err := intParam(json_data, "rotation", 0)
+	if err != nil || !gopdf.ValidRotation(rotation) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. rotation must be 90, 180, 270 or -90, got %v", json_data["rotation"])}})
+		return
+	}
+	pages, ok := pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	err = gopdf.Rotate(in_file, out_path, rotation, pages)
+	fileResponse(c, err, fmt.Sprintf("Rotated %s by %d degrees into %s", in_file.Name, rotation, out_path))
+}
+
+func encryptHandler(c *gin.Context) {
+	/*
+		Writes a password protected copy of input_file to output_file.
+		owner_password defaults to user_password, permissions is "none" (default), "all" or the P bits
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var options gopdf.EncryptOptions
+	options.UserPassword, _ = json_data["user_password"].(string)
+	if options.UserPassword == "" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. user_password is required"}})
+		return
+	}
+	options.OwnerPassword, _ = json_data["owner_password"].(string)
+	switch permissions := json_data["permissions"].(type) {
+	case nil:
+	case string:
+		// Multipart forms send numbers as strings too
+		switch permissions {
+		case "none":
+			options.Permissions = pdfcpu.PermissionsNone
+		case "all":
+			options.Permissions = pdfcpu.PermissionsAll
+		default:
+			bits, err := strconv.ParseInt(permissions, 10, 16)
+			if err != nil {
+				sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. permissions must be \"none\", \"all\" or the permission bits, got %q", permissions)}})
+				return
+			}
+			options.Permissions = int16(bits)
+		}
+	default:
+		bits, err := intParam(json_data, "permissions", 0)
+		if err != nil || bits < -32768 || bits > 32767 {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. permissions must be \"none\", \"all\" or the permission bits, got %v", json_data["permissions"])}})
+			return
+		}
+		options.Permissions = int16(bits)
+	}
+
+	err := gopdf.Encrypt(in_file, out_path, options)
+	if errors.Is(err, gopdf.ErrAlreadyEncrypted) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s is already encrypted", in_file.Name)}, Code: code_already_encrypted})
+		return
+	}
+	fileResponse(c, err, fmt.Sprintf("Encrypted %s into %s", in_file.Name, out_path))
+}
+
+func decryptHandler(c *gin.Context) {
+	/*
+		Writes a decrypted copy of input_file to output_file, password is the user or owner password
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	password, _ := json_data["password"].(string)
+	if password == "" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. password is required"}})
+		return
+	}
+
+	err := gopdf.Decrypt(in_file, out_path, password)
+	if errors.Is(err, gopdf.ErrWrongPassword) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. wrong password for %s", in_file.Name)}})
+		return
+	}
+	if errors.Is(err, gopdf.ErrNotEncrypted) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s is not encrypted", in_file.Name)}, Code: code_not_encrypted})
+		return
+	}
+	fileResponse(c, err, fmt.Sprintf("Decrypted %s into %s", in_file.Name, out_path))
+}
+
+func stampHandler(c *gin.Context) {
+	/*
+		Stamps the pages selection of input_file (every page by default) with a text, image or pdf stamp.
+		The image or PDF is stamp_file, a server side path in JSON or a file part in multipart/form-data.
+		Without an output_file the stamped PDF is returned in the response body.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	var options gopdf.StampOptions
+	options.Type, _ = json_data["type"].(string)
+	if !containsString(gopdf.StampTypes, options.Type) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. type must be one of %s, got %v", strings.Join(gopdf.StampTypes, ", "), json_data["type"])}})
+		return
+	}
+	switch options.Type {
+	case "text":
+		options.Text, _ = json_data["text"].(string)
+		if options.Text == "" {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. text is required for a text stamp"}})
+			return
+		}
+	default:
+		if c.Request.MultipartForm != nil {
+			stamp_files := uploadedFiles(c.Request.MultipartForm, "stamp_file")
+			if len(stamp_files) == 1 {
+				options.File = stamp_files[0]
+			}
+			if options.Type == "pdf" && len(stamp_files) == 1 && !checkUploads(c, stamp_files) {
+				return
+			}
+		} else if path, ok := json_data["stamp_file"].(string); ok && path != "" {
+			options.File = retryingFile(c, gopdf.PathFile(path))
+		}
+		if options.File.Open == nil {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. stamp_file is required for a %s stamp", options.Type)}})
+			return
+		}
+	}
+
+	var err error
+	options.Opacity, err = floatParam(json_data, "opacity")
+	if err != nil || (options.Opacity != nil && (*options.Opacity < 0 || *options.Opacity > 1)) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. opacity must be a number between 0 and 1, got %v", json_data["opacity"])}})
+		return
+	}
+	options.Rotation, err = floatParam(json_data, "rotation")
+	if err != nil || (options.Rotation != nil && (*options.Rotation < -180 || *options.Rotation > 180)) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. rotation must be a number of degrees between -180 and 180, got %v", json_data["rotation"])}})
+		return
+	}
+	options.Scale, err = floatParam(json_data, "scale")
+	if err != nil || (options.Scale != nil && (*options.Scale <= 0 || *options.Scale > 1)) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. scale must be a number above 0 and up to 1, got %v", json_data["scale"])}})
+		return
+	}
+	switch watermark := json_data["watermark"].(type) {
+	case nil:
+	case bool:
+		options.Watermark = watermark
+	case string:
+		options.Watermark = watermark == "true"
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. watermark must be a boolean, got %s", jsonType(watermark))}})
+		return
+	}
+	options.Pages, ok = pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+	if options.ID, ok = stampIDParam(c, json_data); !ok {
+		return
+	}
+
+	out_path, _ := json_data["output_file"].(string)
+	if out_path != "" {
+		out_path, ok = outputPath(c, "output_file", out_path)
+		if !ok {
+			return
+		}
+	}
+	if out_path == "" {
+		// Buffer the whole PDF so a failure can still be reported as JSON
+		var pdf_buf bytes.Buffer
+		err = gopdf.Stamp(in_file, "", &pdf_buf, options)
+		if err != nil {
+			fileResponse(c, err, "")
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(in_file.Name)))
+		c.Data(http.StatusOK, "application/pdf", pdf_buf.Bytes())
+		return
+	}
+	err = gopdf.Stamp(in_file, out_path, nil, options)
+	fileResponse(c, err, fmt.Sprintf("Stamped %s into %s", in_file.Name, out_path))
+}
+
+func stampIDParam(c *gin.Context, json_data map[string]interface{}) (string, bool) {
+	// The optional id a stamp is tagged with, so /unstamp can take off just that one
+	id, ok := json_data["id"].(string)
+	if v, found := json_data["id"]; found && v != nil && !ok {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. id must be a string, got %s", jsonType(v))}})
+		return "", false
+	}
+	return id, true
+}
+
+func unstampHandler(c *gin.Context) {
+	/*
+		Takes the stamps and watermarks pdfcpu put on the pages selection of input_file
+		(every page by default) off again, only the ones /stamp tagged with id when there
+		is an id, and writes the result to output_file. Answers with the count per page:
+			{"message": "...", "removed": 3, "pages": [{"page": 1, "removed": 2}, ...]}
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	pages, ok := pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+	id, ok := stampIDParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	result, err := gopdf.Unstamp(in_file, out_path, id, pages)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	message := fmt.Sprintf("Removed %d stamps from %s into %s", result.Removed, in_file.Name, out_path)
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"message": message, "removed": result.Removed, "pages": result.Pages}})
+}
+
+func optimizeHandler(c *gin.Context) {
+	/*
+		Optimizes input_file into output_file and reports both sizes, even when the
+		optimized file came out bigger so the caller can decide which one to keep
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	result, err := gopdf.Optimize(in_file, out_path)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{fmt.Sprintf("Optimized %s into %s", in_file.Name, out_path)}, Data: gin.H{"original_size": result.OriginalSize, "optimized_size": result.OptimizedSize}})
+}
+
+func linearizeHandler(c *gin.Context) {
+	/*
+		Writes a linearized version of input_file to output_file. pdfcpu can't write
+		those, so the original goes through unchanged and a file that wasn't linearized
+		already comes with a warning, output_file is still written either way
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	result, err := gopdf.Linearize(in_file, out_path)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	body := gin.H{"message": fmt.Sprintf("Wrote %s into %s", in_file.Name, out_path), "linearized": result.Linearized, "size": result.Size}
+	if !result.Linearized {
+		body["warnings"] = []string{fmt.Sprintf("linearization isn't supported, %s was written unchanged and isn't linearized", in_file.Name)}
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: body})
+}
+
+func infoHandler(c *gin.Context) {
+	/*
+		Reports page count, version, encryption, form and metadata of every input file,
+		a file that can't be read is reported with its error so this is a 200 either way
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	if !setPasswords(c, json_data, input_files) {
+		return
+	}
+
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"results": gopdf.Info(input_files)}})
+}
+
+func compareHandler(c *gin.Context) {
+	/*
+		Diffs the form fields of two versions of a PDF, input_files is [old, new]
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	if len(input_files) != 2 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. input_files must be the old and the new file, got %d files", len(input_files))}})
+		return
+	}
+	if !setPasswords(c, json_data, input_files) {
+		return
+	}
+
+	diff, err := gopdf.Compare(input_files[0], input_files[1])
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: diff})
+}
+
+func pagesHandler(c *gin.Context) {
+	/*
+		Page level edits of input_file written to output_file: operation "delete"
+		removes the pages selection, "reorder" puts the pages in the order of order
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	var page_count int
+	var err error
+	switch operation, _ := json_data["operation"].(string); operation {
+	case "delete":
+		pages, ok := pagesParam(c, json_data)
+		if !ok {
+			return
+		}
+		if pages == "" {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. pages is required to delete pages"}})
+			return
+		}
+		page_count, err = gopdf.DeletePages(in_file, out_path, pages)
+	case "reorder":
+		order, order_err := intListParam(json_data, "order")
+		if order_err != nil || len(order) == 0 {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. order must be a list of page numbers, got %v", json_data["order"])}})
+			return
+		}
+		page_count, err = gopdf.ReorderPages(in_file, out_path, order)
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. operation must be delete or reorder, got %v", json_data["operation"])}})
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{fmt.Sprintf("Wrote %d pages of %s into %s", page_count, in_file.Name, out_path)}, Data: gin.H{"page_count": page_count}})
+}
+
+func insertBlankHandler(c *gin.Context) {
+	/*
+		Adds count blank pages at every one of positions (after page n, 0 is before the
+		first page) of input_file and writes it to output_file. They're the size of the
+		page before them unless size is a paper size (A4, Letter...) or [width, height]
+		in points.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	positions, err := intListParam(json_data, "positions")
+	if err != nil || len(positions) == 0 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. positions must be a list of page numbers to add blank pages after, 0 for the start, got %v", json_data["positions"])}})
+		return
+	}
+	count, err := intParam(json_data, "count", 1)
+	if err != nil || count < 1 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. count must be a number of pages from 1, got %v", json_data["count"])}})
+		return
+	}
+	blank := gopdf.BlankPages{Positions: positions, Count: count}
+	switch size := json_data["size"].(type) {
+	case nil:
+	case string:
+		if dims, err := floatListParam(json_data, "size"); err == nil && len(dims) == 2 {
+			// Multipart forms send [width, height] as "width,height"
+			blank.Width, blank.Height = dims[0], dims[1]
+		} else {
+			blank.Size = size
+		}
+	case []interface{}:
+		dims, err := floatListParam(json_data, "size")
+		if err != nil || len(dims) != 2 {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. size must be a paper size or [width, height] in points, got %v", size)}})
+			return
+		}
+		blank.Width, blank.Height = dims[0], dims[1]
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. size must be a paper size or [width, height] in points, got %s", jsonType(size))}})
+		return
+	}
+
+	page_count, err := gopdf.InsertBlankPages(in_file, out_path, blank)
+	if errors.Is(err, gopdf.ErrInvalidBlankPages) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s: %v", in_file.Name, err)}})
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{fmt.Sprintf("Added %d blank pages to %s into %s, it has %d pages", count*len(positions), in_file.Name, out_path, page_count)}, Data: gin.H{"page_count": page_count}})
+}
+
+func trimHandler(c *gin.Context) {
+	/*
+		Writes just the pages selection of input_file to output_file, in the order selected
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	pages, ok := pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+	if pages == "" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. pages is required, the pages to keep like \"1-3,5\""}})
+		return
+	}
+
+	page_count, err := gopdf.TrimPages(in_file, out_path, pages)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{fmt.Sprintf("Wrote %d pages of %s into %s", page_count, in_file.Name, out_path)}, Data: gin.H{"page_count": page_count}})
+}
+
+func metadataHandler(c *gin.Context) {
+	/*
+		Sets the Info dict entries (title, author, subject, keywords) and XMP packet (xmp)
+		of input_file given in the request and writes it to output_file, "" removes one.
+		clear removes all the metadata first. Answers with the metadata before and after:
+			{"before": {"title": "Draft"}, "after": {"title": "Final", "producer": "pdfcpu ..."}}
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var update gopdf.MetadataUpdate
+	for key, entry := range map[string]**string{"title": &update.Title, "author": &update.Author, "subject": &update.Subject, "keywords": &update.Keywords, "xmp": &update.XMP} {
+		v, found := json_data[key]
+		if !found {
+			continue
+		}
+		text, ok := v.(string)
+		if !ok {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s must be a string, got %s", key, jsonType(v))}})
+			return
+		}
+		*entry = &text
+	}
+	switch clear := json_data["clear"].(type) {
+	case nil:
+	case bool:
+		update.Clear = clear
+	case string:
+		// Multipart forms
+		update.Clear = clear == "true"
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. clear must be a boolean, got %s", jsonType(clear))}})
+		return
+	}
+	if !update.Clear && update.Title == nil && update.Author == nil && update.Subject == nil && update.Keywords == nil && update.XMP == nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. nothing to change, give title, author, subject, keywords, xmp or clear"}})
+		return
+	}
+
+	before, after, err := gopdf.SetMetadata(in_file, out_path, update)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{
+		"message": fmt.Sprintf("Wrote %s with its metadata updated into %s", in_file.Name, out_path),
+		"before":  before,
+		"after":   after,
+	}})
+}
+
+func overlayHandler(c *gin.Context) {
+	/*
+		Draws page N of overlay_file on top of page N of base_file and writes it to output_file.
+		Both are server side paths in JSON or file parts in multipart/form-data. Extra pages
+		of a longer overlay are dropped unless append_extra_pages is true.
+	*/
+	json_data, base_file, ok := readFileRequest(c, "base_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	var overlay_file gopdf.InputFile
+	if c.Request.MultipartForm != nil {
+		overlay_files := uploadedFiles(c.Request.MultipartForm, "overlay_file")
+		if len(overlay_files) != 1 {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. expected one overlay_file file part, got %d", len(overlay_files))}})
+			return
+		}
+		if !checkUploads(c, overlay_files) {
+			return
+		}
+		overlay_file = measuredFile(overlay_files[0])
+	} else if path, ok := json_data["overlay_file"].(string); ok && path != "" {
+		if overlay_file, ok = inputFile(c, path); !ok {
+			return
+		}
+	} else {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. overlay_file must be a file path, got %s", jsonType(json_data["overlay_file"]))}})
+		return
+	}
+	// The overlay is checked like the base file
+	overlay_file.Validation = base_file.Validation
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	var append_extra bool
+	switch v := json_data["append_extra_pages"].(type) {
+	case nil:
+	case bool:
+		append_extra = v
+	case string:
+		// Multipart forms
+		append_extra = v == "true"
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. append_extra_pages must be a boolean, got %s", jsonType(v))}})
+		return
+	}
+
+	result, err := gopdf.Overlay(base_file, overlay_file, out_path, append_extra)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	message := fmt.Sprintf("Overlaid %d pages of %s with %s into %s", result.Overlaid, base_file.Name, overlay_file.Name, out_path)
+	if result.Appended > 0 {
+		message += fmt.Sprintf(", %d extra overlay pages appended", result.Appended)
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{message}, Data: gin.H{"page_count": result.PageCount}})
+}
+
+func repairHandler(c *gin.Context) {
+	/*
+		Writes a clean copy of a PDF that fails validation to output_file, rebuilding its
+		xref when pdfcpu can't read it as it is. Answers with what was wrong and fixed:
+			{"problem": "...", "fixes": ["rebuilt the cross-reference table ..."], "valid": true}
+		A file that's still unreadable is a 422, unlike the 400 of files that are merely invalid.
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	result, err := gopdf.Repair(in_file, out_path)
+	if errors.Is(err, gopdf.ErrTooDamaged) {
+		sendResponse(c, Response{Status: http.StatusUnprocessableEntity, Error: []string{fmt.Sprintf("Unprocessable Entity. %v", err)}})
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	message := fmt.Sprintf("Wrote a repaired %s into %s", in_file.Name, out_path)
+	if result.Problem == "" {
+		message = fmt.Sprintf("%s was valid already, wrote a clean copy into %s", in_file.Name, out_path)
+	} else if !result.Valid {
+		message += ", it still doesn't pass validation"
+	}
+	body := gin.H{"message": message, "fixes": result.Fixes, "valid": result.Valid}
+	if result.Problem != "" {
+		body["problem"] = result.Problem
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: body})
+}
+
+func extractImagesHandler(c *gin.Context) {
+	/*
+		Writes the images embedded in input_files (or only in the pages selection) to output_dir
+			{"images": {"foo.pdf": [{"page": 1, "images": ["out/foo_1_Im0.jpg"]}]}}
+	*/
+	json_data, input_files, ok := readRequest(c, "input_files")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_dir, ok := outputDirParam(c, json_data)
+	if !ok {
+		return
+	}
+	pages, ok := pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	images, err := gopdf.ExtractImages(input_files, out_dir, pages)
+	var file_errors gopdf.FileErrors
+	if errors.As(err, &file_errors) {
+		sendResponse(c, fileErrorsResponse(file_errors))
+		return
+	}
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"images": images}})
+}
+
+func exportFormHandler(c *gin.Context) {
+	/*
+		Returns the field values of input_file as an FDF (default) or XFDF document
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	format, _ := json_data["format"].(string)
+	if format == "" {
+		format = gopdf.FormatFDF
+	}
+	if !gopdf.ValidExportFormat(format) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. format must be %s or %s, got %v", gopdf.FormatFDF, gopdf.FormatXFDF, json_data["format"])}})
+		return
+	}
+
+	var export_buf bytes.Buffer
+	err := gopdf.ExportForm(in_file, format, &export_buf)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	content_type := "application/vnd.fdf"
+	if format == gopdf.FormatXFDF {
+		content_type = "application/vnd.adobe.xfdf"
+	}
+	file_name := strings.TrimSuffix(filepath.Base(in_file.Name), filepath.Ext(in_file.Name)) + "." + format
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file_name))
+	c.Data(http.StatusOK, content_type, export_buf.Bytes())
+}
+
+func nupHandler(c *gin.Context) {
+	/*
+		Puts the pages of input_file onto sheets of several pages each, grid is
+		a preset like 4 or rows x cols like "2x3", and writes it to output_file
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	// A preset can come as a JSON number
+	grid_param := json_data["grid"]
+	if n, is_number := grid_param.(float64); is_number {
+		grid_param = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	grid_text, _ := grid_param.(string)
+	if grid_text == "" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. grid is required, a preset like 4 or rows x cols like \"2x3\""}})
+		return
+	}
+	grid, err := gopdf.ParseNUpGrid(grid_text)
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %v", err)}})
+		return
+	}
+	pages, ok := pagesParam(c, json_data)
+	if !ok {
+		return
+	}
+
+	err = gopdf.NUp(in_file, out_path, grid, pages)
+	fileResponse(c, err, fmt.Sprintf("Arranged %s %s per sheet into %s", in_file.Name, grid_text, out_path))
+}
+
+func bookletHandler(c *gin.Context) {
+	/*
+		Reimposes input_file into booklet order on sheet_size sheets (A4 by default)
+		bound at the left (default) or top edge, and writes it to output_file
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	out_path, ok := outputFileParam(c, json_data)
+	if !ok {
+		return
+	}
+	sheet_size, _ := json_data["sheet_size"].(string)
+	if sheet_size == "" {
+		sheet_size = "A4"
+	}
+	if _, ok := gopdf.SheetSize(sheet_size); !ok {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. unknown sheet_size %v, expected a paper size like A4, A3 or Letter", json_data["sheet_size"])}})
+		return
+	}
+	binding, _ := json_data["binding"].(string)
+	if binding == "" {
+		binding = gopdf.BindingLeft
+	}
+	if binding != gopdf.BindingLeft && binding != gopdf.BindingTop {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. binding must be %s or %s, got %v", gopdf.BindingLeft, gopdf.BindingTop, json_data["binding"])}})
+		return
+	}
+
+	result, err := gopdf.Booklet(in_file, out_path, sheet_size, binding)
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{fmt.Sprintf("Made a booklet of %s into %s", in_file.Name, out_path)}, Data: gin.H{"page_count": result.PageCount, "blank_pages": result.BlankPages}})
+}
+
+func thumbnailHandler(c *gin.Context) {
+	/*
+		PNG of page (1 by default) of input_file, width pixels wide. We have no renderer,
+		so that's the page's embedded thumbnail, a page without one is a 501 with its media_box
+	*/
+	json_data, in_file, ok := readFileRequest(c, "input_file")
+	if !ok {
+		return
+	}
+	defer cleanupRequest(c)
+
+	page, err := intParam(json_data, "page", 1)
+	if err != nil || page < 1 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. page must be a page number, 1 or more, got %v", json_data["page"])}})
+		return
+	}
+	width, err := intParam(json_data, "width", 0)
+	if err != nil || width < 0 || width > gopdf.MaxThumbnailWidth {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. width must be between 1 and %d pixels, got %v", gopdf.MaxThumbnailWidth, json_data["width"])}})
+		return
+	}
+
+	// Buffered so a failure can still be reported as JSON
+	var png_buf bytes.Buffer
+	media_box, err := gopdf.Thumbnail(in_file, page, width, &png_buf)
+	if errors.Is(err, gopdf.ErrNoThumbnail) {
+		sendResponse(c, Response{Status: http.StatusNotImplemented, Error: []string{fmt.Sprintf("Not Implemented. %v (page %d of %s)", err, page, in_file.Name)}, Code: code_no_thumbnail, Data: gin.H{"media_box": media_box}})
+		return
+	}
+	if err != nil {
+		fileResponse(c, err, "")
+		return
+	}
+	c.Data(http.StatusOK, "image/png", png_buf.Bytes())
+}
+
+/*
+	Passing by value in Go may be significantly cheaper than passing by pointer.
+ 	This happens because Go uses escape analysis to determine if variable can be safely allocated on function’s stack frame
+*/
+func errorHandler(idx int, err error, c *gin.Context) {
+	var unmarshalErr *json.UnmarshalTypeError
+	var max_bytes_err *http.MaxBytesError
+	if err != nil {
+		if errors.As(err, &max_bytes_err) {
+			sendResponse(c, Response{Status: http.StatusRequestEntityTooLarge, Error: []string{fmt.Sprintf("Request Entity Too Large. the body can't be over %d bytes", max_bytes_err.Limit)}})
+		} else if errors.As(err, &unmarshalErr) {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. Wrong Type provided for field %v for idx: %d", unmarshalErr.Field, idx)}, Code: code_invalid_request})
+		} else {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request %v for idx: %d", err.Error(), idx)}, Code: decodeErrorCode(err)})
+		}
+		return
+	}
+}
+
+/*
+	Fills input_files and uploads them to the s3:// URL out_url, the filled PDF is
+	buffered with a single input file. With several they're written to a temp dir
+	first and out_url is the prefix their names go under (see gopdf.OutputNames).
+	A failed upload is a 502, it's S3 failing not the PDF
+*/
+func generateS3(c *gin.Context, context map[string]interface{}, out_url string, input_files []gopdf.InputFile, options gopdf.GenerateOptions) (gopdf.GenerateResult, bool) {
+	if _, _, err := parseS3URL(out_url); err != nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %v", err)}})
+		return gopdf.GenerateResult{}, false
+	}
+
+	if len(input_files) == 1 {
+		var pdf_buf bytes.Buffer
+		result, err := gopdf.Generate(c.Request.Context(), context, "", input_files, &pdf_buf, options)
+		if err != nil {
+			generateError(c, result, err)
+			return result, false
+		}
+		err = uploadS3(c.Request.Context(), out_url, bytes.NewReader(pdf_buf.Bytes()))
+		if err != nil {
+			sendResponse(c, Response{Status: http.StatusBadGateway, Error: []string{fmt.Sprintf("Bad Gateway. uploading %s: %v", out_url, err)}, Code: code_upload_failed})
+			return result, false
+		}
+		return result, true
+	}
+
+	tmp_dir, remove_tmp_dir, err := requestTempDir(c, "s3")
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return gopdf.GenerateResult{}, false
+	}
+	defer remove_tmp_dir()
+	result, err := gopdf.Generate(c.Request.Context(), context, tmp_dir, input_files, nil, options)
+	if err != nil {
+		generateError(c, result, err)
+		return result, false
+	}
+	for _, name := range gopdf.OutputNames(input_files) {
+		key_url := s3Key(out_url, name)
+		f, err := os.Open(filepath.Join(tmp_dir, name))
+		if err != nil {
+			sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+			return result, false
+		}
+		err = uploadS3(c.Request.Context(), key_url, f)
+		f.Close()
+		if err != nil {
+			sendResponse(c, Response{Status: http.StatusBadGateway, Error: []string{fmt.Sprintf("Bad Gateway. uploading %s: %v", key_url, err)}, Code: code_upload_failed})
+			return result, false
+		}
+	}
+	return result, true
+}
+
+/*
+	Reports a failed generate along with whatever field matching it got through,
+	so a client with a typo in their context can see which keys were missing.
+	An input file that doesn't exist is a 404, one that is no good or context keys
+	matching no field a 400, anything else (like failing to write the output) a 500
+*/
+func generateError(c *gin.Context, result gopdf.GenerateResult, err error) {
+	sendResponse(c, generateErrorResponse(result, err))
+}
+
+func generateErrorResponse(result gopdf.GenerateResult, err error) Response {
+	// The status and error of a failed gopdf.Generate
+	response := generateResponse(result)
+	var file_error *gopdf.FileError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		response.Status = http.StatusGatewayTimeout
+		response.Error = []string{"Gateway Timeout. the files took too long to fill"}
+		response.Code = code_timeout
+	case errors.As(err, &file_error) && errors.Is(file_error.Err, fs.ErrNotExist):
+		response.Status = http.StatusNotFound
+		response.Error = []string{fmt.Sprintf("Not Found %v for idx: %d", file_error.Err, file_error.Idx)}
+		response.Code = code_file_not_found
+	case errors.As(err, &file_error):
+		response.Status = http.StatusBadRequest
+		response.Error = fileErrorMessages(gopdf.FileErrors{file_error})
+		response.Code = fileErrorCode(file_error)
+	case errors.Is(err, gopdf.ErrFieldsNotFound):
+		response.Status = http.StatusBadRequest
+		response.Error = []string{fmt.Sprintf("Bad Request %v", err.Error())}
+		response.Code = code_field_not_found
+	case errors.Is(err, gopdf.ErrTransform):
+		response.Status = http.StatusBadRequest
+		response.Error = []string{fmt.Sprintf("Bad Request. %v", err)}
+		response.Code = code_invalid_request
+	case errors.Is(err, gopdf.ErrReproducibleEncrypted):
+		response.Status = http.StatusBadRequest
+		response.Error = []string{fmt.Sprintf("Bad Request. %v, leave reproducible off", err)}
+		response.Code = code_invalid_request
+	default:
+		response.Status = http.StatusInternalServerError
+		response.Error = []string{err.Error()}
+		response.Code = code_internal
+	}
+	return response
+}
+
+func generateResponse(result gopdf.GenerateResult) Response {
+	return Response{Filled: result.Filled, Untouched: result.Untouched, FieldErrors: result.FieldErrors, Warnings: result.Warnings, Missing: result.Missing, Transformed: result.Transformed}
+}
+
+/*
+	One "Bad Request" line per input file that failed
+*/
+func fileErrorMessages(file_errors gopdf.FileErrors) []string {
+	messages := make([]string, len(file_errors))
+	for i, file_error := range file_errors {
+		messages[i] = fmt.Sprintf("Bad Request %v for idx: %d", file_error.Err, file_error.Idx)
+	}
+	return messages
+}
+
+func fileErrorsResponse(file_errors gopdf.FileErrors) Response {
+	/*
+		The 400 for input files that are no good, with the code of the first one.
+		When none of them is there at all it's a 404, like for a single missing file.
+	*/
+	for _, file_error := range file_errors {
+		if !errors.Is(file_error.Err, fs.ErrNotExist) {
+			return Response{Status: http.StatusBadRequest, Error: fileErrorMessages(file_errors), Code: fileErrorCode(file_errors[0])}
+		}
+	}
+	messages := make([]string, len(file_errors))
+	for i, file_error := range file_errors {
+		messages[i] = fmt.Sprintf("Not Found %v for idx: %d", file_error.Err, file_error.Idx)
+	}
+	return Response{Status: http.StatusNotFound, Error: messages, Code: code_file_not_found}
+}
+
+/*
+	Response for the endpoints working on a single file: a FileError is the
+	caller's file being no good (400), so is an error fileErrorCode knows (a missing
+	file is a 404), anything else went wrong on our side
+*/
+func fileResponse(c *gin.Context, err error, message string) {
+	var file_error *gopdf.FileError
+	if errors.As(err, &file_error) {
+		sendResponse(c, fileErrorsResponse(gopdf.FileErrors{file_error}))
+		return
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		sendResponse(c, Response{Status: http.StatusNotFound, Error: []string{fmt.Sprintf("Not Found %v", err)}, Code: code_file_not_found})
+		return
+	}
+	if code := fileErrorCode(err); err != nil && code != code_invalid_pdf {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request %v", err)}, Code: code})
+		return
+	}
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{err.Error()}})
+		return
 	}
+	sendResponse(c, Response{Status: http.StatusOK, Message: []string{message}})
 }
 
 /*
-	Passing by value in Go may be significantly cheaper than passing by pointer.
- 	This happens because Go uses escape analysis to determine if variable can be safely allocated on function’s stack frame
+	Name of the JSON type a decoded value came from, for error messages
 */
-func errorHandler(idx int, err error, c *gin.Context) {
-	var unmarshalErr *json.UnmarshalTypeError
-	if err != nil {
-		if errors.As(err, &unmarshalErr) {
-			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. Wrong Type provided for field %v for idx: %d", unmarshalErr.Field, idx)}})
-		} else {
-			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request %v for idx: %d", err.Error(), idx)}})
-		}
-		return
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
 	}
+	return fmt.Sprintf("%T", v)
 }
 
 /*
 	Generic response handler
 */
 func sendResponse(c *gin.Context, response Response) {
+	/*
+		Writes response with response.Status, every JSON response goes through here.
+		A Response without a Status is an OK unless it has errors.
+	*/
+	if response.Status == 0 {
+		response.Status = http.StatusOK
+		if len(response.Error) > 0 {
+			response.Status = http.StatusInternalServerError
+		}
+	}
+	if len(response.Error) > 0 {
+		level := slog.LevelWarn
+		if response.Status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		requestLogger(c).Log(c.Request.Context(), level, "request failed", "status", response.Status, "errors", response.Error)
+	}
+	if response.Body != nil {
+		c.JSON(response.Status, response.Body)
+		return
+	}
+	body := make(map[string]interface{}, len(response.Data)+2)
+	for key, value := range response.Data {
+		body[key] = value
+	}
+	// Message and Error go side by side, an error response with a message still has its error and code
 	if len(response.Message) > 0 {
-		c.JSON(response.Status, map[string]interface{}{"message": strings.Join(response.Message, "; ")})
-	} else if len(response.Error) > 0 {
-		c.JSON(response.Status, map[string]interface{}{"error": strings.Join(response.Error, "; ")})
+		body["message"] = strings.Join(response.Message, "; ")
+	}
+	if len(response.Error) > 0 {
+		body["error"] = strings.Join(response.Error, "; ")
+		body["code"] = response.Code
+		if response.Code == "" {
+			body["code"] = statusErrorCode(response.Status)
+		}
+	}
+	if response.Filled != nil {
+		body["filled"] = response.Filled
+	}
+	if response.Untouched != nil {
+		body["untouched"] = response.Untouched
+	}
+	if response.FieldErrors != nil {
+		body["field_errors"] = response.FieldErrors
+	}
+	if len(response.Warnings) > 0 {
+		body["warnings"] = response.Warnings
 	}
+	if response.Missing != nil {
+		body["missing"] = response.Missing
+	}
+	if len(response.Transformed) > 0 {
+		body["transformed"] = response.Transformed
+	}
+	c.JSON(response.Status, body)
 }
 
-//>> FUNCTIONS
+//>>HELPERS
 
-func scrape(file_dict map[string]interface{}, c *gin.Context) []string {
+func requestTimeout(timeout time.Duration) gin.HandlerFunc {
 	/*
-		TODO: I don't like the error handling here, redoit all so that we don't use the *gin.Context here at all
-		(should only be used in the handler)
-
-		Gets AcroForm data from files and returns a list of fields
-			["foo_bar","bar_mitzvah"]
+		Puts a deadline on the request context, /scrape and /generate give up
+		with a 504 once it passes (see gopdf.Scrape and gopdf.Generate)
 	*/
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
 
-	/*
-		parse the json to be a an array of strings (each string a file path)
-		the types inside the slice are not string, they're also interface{}.
-		One has to iterate the collection then do a type assertion on each item like so:
-	*/
-	files_interface := file_dict["files"].([]interface{})
-	files_list := make([]string, len(files_interface))
-	for i, v := range files_interface {
-		files_list[i] = v.(string)
+func envDuration(name string, default_value time.Duration) time.Duration {
+	// A duration flag's default from the environment, exits when it's no duration
+	env_value := os.Getenv(name)
+	if env_value == "" {
+		return default_value
+	}
+	duration, err := time.ParseDuration(env_value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s %q: %v\n", name, env_value, err)
+		os.Exit(2)
 	}
+	return duration
+}
 
-	// TODO make this a batch process
+func limitBody(limit int64) gin.HandlerFunc {
 	/*
-		This command checks inFile for compliance with the specification PDF 32000-1:2008 (PDF 1.7).
-		 Any PDF file you would like to process needs to pass validation.
+		Caps how much of a request body gets read, before any handler or pdfcpu touches it.
+		A Content-Length over the limit is turned away right here, a body that turns out
+		bigger while reading fails the decode with an *http.MaxBytesError (see errorHandler).
 	*/
-
-	// This is how you create an array of variable length
-	acro_fields := make([]string, 0)
-	for idx, f := range files_list {
-		// Print the file and idx
-		//fmt.Println(idx, f)
-
-		//this uses an io.ReadSeeker
-		f, err := os.Open(f)
-
-		if err != nil {
-			errorHandler(idx, err, c)
-		} else {
-			//Validate, for all pdfcpu api calls requiring configuration, we can use default
-			err = api.Validate(f, nil)
-			if err != nil {
-				errorHandler(idx, err, c)
-			} else {
-				// Get AcroForm fields
-				f.Seek(0, io.SeekStart)
-				res := getAcro(idx, f, &acro_fields)
-				if res == 0 {
-					continue
-				}
-				//Close the file this ain't python!
-				defer f.Close()
-
-			}
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			sendResponse(c, Response{Status: http.StatusRequestEntityTooLarge, Error: []string{fmt.Sprintf("Request Entity Too Large. the body can't be over %d bytes", limit)}})
+			c.Abort()
+			return
 		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+func uploadedFiles(form *multipart.Form, key string) []gopdf.InputFile {
+	// The multipart.File is handed straight to pdfcpu, no need to copy it anywhere first
+	file_headers := form.File[key]
+	input_files := make([]gopdf.InputFile, len(file_headers))
+	for i, fh := range file_headers {
+		fh := fh
+		input_files[i] = gopdf.InputFile{Name: fh.Filename, Open: func() (gopdf.File, error) {
+			return fh.Open()
+		}}
 	}
-	return acro_fields
+	return input_files
 }
 
-func generate(context map[string]interface{}, out_dir string, input_files []string) {
+func checkUploads(c *gin.Context, input_files []gopdf.InputFile) bool {
 	/*
-		Fills a PDF's forms (acro form) with user information.
+		Uploads that aren't PDFs at all are a 415 saying what they look like instead,
+		before pdfcpu gets to fail on them with a parse error
 	*/
-	// fmt.Printf("Context: %v", context)
-	// fmt.Printf("Output: %v", out_dir)
-	// fmt.Printf("Input: %v", input_files)
-
+	messages := make([]string, 0)
+	for idx, in_file := range input_files {
+		var not_pdf *gopdf.NotPDFError
+		if err := gopdf.CheckPDF(in_file); errors.As(err, &not_pdf) {
+			messages = append(messages, fmt.Sprintf("Unsupported Media Type. %s is %v for idx: %d", in_file.Name, err, idx))
+		}
+	}
+	if len(messages) > 0 {
+		sendResponse(c, Response{Status: http.StatusUnsupportedMediaType, Error: messages})
+		return false
+	}
+	return true
 }
 
-//>>HELPERS
-
-func getAcro(idx int, source io.ReadSeeker, acro_fields *[]string) int {
-	ctx, err := api.ReadContext(source, nil)
-	if err != nil {
-		log.Println(idx, err)
-		return 0
+func readRequest(c *gin.Context, files_key string) (map[string]interface{}, []gopdf.InputFile, bool) {
+	/*
+		Reads the request body, either JSON with server side paths or URLs under files_key,
+		or multipart/form-data with the PDFs uploaded as files_key file parts.
+		Sends a 400 and returns false when the body is no good, a 502 when a URL can't be fetched.
+		Call cleanupRequest once done with the files.
+	*/
+	if c.ContentType() == "multipart/form-data" {
+		form, err := multipartForm(c)
+		if err != nil {
+			errorHandler(0, err, c)
+			return nil, nil, false
+		}
+		json_data, err := formJSON(form)
+		if err != nil {
+			cleanupRequest(c)
+			errorHandler(0, err, c)
+			return nil, nil, false
+		}
+		input_files := measuredFiles(uploadedFiles(form, files_key))
+		requestLogger(c).Info("read request", "files", fileNames(input_files), "uploaded", true)
+		if !checkUploads(c, input_files) || !setValidation(c, json_data, input_files) {
+			cleanupRequest(c)
+			return nil, nil, false
+		}
+		return json_data, input_files, true
 	}
 
-	cat, err := ctx.Catalog()
+	var json_data map[string]interface{}
+
+	//Using jsonDecoder is best practice since it reads the streaming json data (which means it can error out immediately)
+	decoder := json.NewDecoder(c.Request.Body)
+	err := decoder.Decode(&json_data)
 	if err != nil {
-		log.Println(idx, err)
-		return 0
+		errorHandler(0, err, c)
+		return nil, nil, false
 	}
 
-	acroform, ok := cat.Find("AcroForm")
+	// Here we are converting from interface{} into []interface{} into []gopdf.InputFile
+	files_interface, ok := json_data[files_key].([]interface{})
 	if !ok {
-		log.Printf("No forms for %v with idx: %d", source, idx)
-		return 0
-	}
-
-	adict, err := ctx.DereferenceDict(acroform)
-	if err != nil {
-		log.Println(idx, err)
-		return 0
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s must be a list of file paths, got %s", files_key, jsonType(json_data[files_key]))}})
+		return nil, nil, false
 	}
-
-	fields := adict.ArrayEntry("Fields")
-
-	for i, o := range fields {
-		ir := o.(pdfcpu.IndirectRef)
-		e, ok := ctx.FindTableEntryForIndRef(&ir)
+	input_files := make([]gopdf.InputFile, len(files_interface))
+	for i, v := range files_interface {
+		path, ok := v.(string)
 		if !ok {
-			log.Printf("No XrefTableEntry for %v with idx: %d", ir, idx)
-			return 0
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s must be a list of file paths, got %s for idx: %d", files_key, jsonType(v), i)}})
+			return nil, nil, false
 		}
-		//fmt.Printf("E TYPE: %T", e)
-		d, ok := e.Object.(pdfcpu.Dict)
+		input_files[i], ok = inputFile(c, path)
 		if !ok {
-			log.Printf("Object %v is not a Dict with idx: %d", ir, idx)
-			return 0
-		}
-		//fmt.Printf("INSIDE: %v", d)
-		v := d.StringEntry("T")
-		if v == nil {
-			log.Printf("No field name for field %v with idx: %d", i, idx)
-			return 0
+			return nil, nil, false
 		}
-
-		field_name := *v
-		*acro_fields = append(*acro_fields, field_name)
-		// create object
-		//var test Object
-		d.Update("V", pdfcpu.String("STUFF!"))
-		//d.Update("V", )
-		//fmt.Printf("NEW VALUE: %v", d)
-		//fmt.Printf("TYPE: %T", d.StringEntry("V"))
-		//mergeAcroForms(ctx, ctx)
-		//api.WriteContextFile(ctx, "TESTINGFILE.pdf")
-
 	}
-	ctx.Write.DirName = "."
-	ctx.Write.FileName = "tezzting.pdf"
-	pdfcpu.Write(ctx)
-	return 1
+	if !setValidation(c, json_data, input_files) {
+		return nil, nil, false
+	}
+	requestLogger(c).Info("read request", "files", fileNames(input_files))
+	return json_data, input_files, true
 }
 
-//go:linkname contains pdfcpu.mergeAcroForms
-func mergeAcroForms(ctxSource, ctxDest *pdfcpu.Context) error {
-	rootDictDest, err := ctxDest.Catalog()
-	if err != nil {
-		return err
+func readFileRequest(c *gin.Context, file_key string) (map[string]interface{}, gopdf.InputFile, bool) {
+	/*
+		Same as readRequest for endpoints that take a single file: a server side path
+		or URL under file_key in JSON, or exactly one file_key file part in multipart/form-data.
+	*/
+	if c.ContentType() == "multipart/form-data" {
+		json_data, input_files, ok := readRequest(c, file_key)
+		if !ok {
+			return nil, gopdf.InputFile{}, false
+		}
+		if len(input_files) != 1 {
+			cleanupRequest(c)
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. expected one %s file part, got %d", file_key, len(input_files))}})
+			return nil, gopdf.InputFile{}, false
+		}
+		return json_data, input_files[0], true
 	}
 
-	rootDictSource, err := ctxSource.Catalog()
+	var json_data map[string]interface{}
+
+	decoder := json.NewDecoder(c.Request.Body)
+	err := decoder.Decode(&json_data)
 	if err != nil {
-		return err
+		errorHandler(0, err, c)
+		return nil, gopdf.InputFile{}, false
 	}
-
-	o, found := rootDictSource.Find("AcroForm")
-	if !found {
-		return nil
+	path, ok := json_data[file_key].(string)
+	if !ok || path == "" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s must be a file path, got %s", file_key, jsonType(json_data[file_key]))}})
+		return nil, gopdf.InputFile{}, false
 	}
-
-	dSrc, err := ctxSource.DereferenceDict(o)
-	if err != nil || len(dSrc) == 0 {
-		return err
+	in_file, ok := inputFile(c, path)
+	if !ok {
+		return nil, gopdf.InputFile{}, false
+	}
+	input_files := []gopdf.InputFile{in_file}
+	if !setValidation(c, json_data, input_files) {
+		return nil, gopdf.InputFile{}, false
 	}
+	requestLogger(c).Info("read request", "files", []string{path})
+	return json_data, input_files[0], true
+}
 
-	// Retrieve ctxSrc AcroForm Fields
-	o, found = dSrc.Find("Fields")
-	if !found {
-		return nil
+func fieldNamesParam(c *gin.Context, json_data map[string]interface{}) ([]string, bool) {
+	// The non-empty list of field names under fields, sends a 400 when it's anything else
+	fields_interface, ok := json_data["fields"].([]interface{})
+	if !ok {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. fields must be a list of field names, got %s", jsonType(json_data["fields"]))}})
+		return nil, false
 	}
-	arrFieldsSrc, err := ctxDest.DereferenceArray(o)
-	if err != nil {
-		return err
+	if len(fields_interface) == 0 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. fields must not be empty"}})
+		return nil, false
 	}
-	if len(arrFieldsSrc) == 0 {
-		return nil
+	names := make([]string, len(fields_interface))
+	for i, v := range fields_interface {
+		name, ok := v.(string)
+		if !ok {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. fields[%d] must be a string, got %s", i, jsonType(v))}})
+			return nil, false
+		}
+		if name == "" {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. fields[%d] must not be empty", i)}})
+			return nil, false
+		}
+		names[i] = name
 	}
+	return names, true
+}
 
-	// We have a ctxSrc.Acroform with fields.
-
-	o, found = rootDictDest.Find("AcroForm")
-	if !found {
-		rootDictDest["AcroForm"] = dSrc
-		return nil
+func intParam(json_data map[string]interface{}, key string, default_value int) (int, error) {
+	// Numbers come as float64 from JSON and as strings from multipart forms
+	switch v := json_data[key].(type) {
+	case nil:
+		return default_value, nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("%s must be a whole number, got %v", key, v)
+		}
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
 	}
+	return 0, fmt.Errorf("%s must be a number, got %s", key, jsonType(json_data[key]))
+}
 
-	dDest, err := ctxDest.DereferenceDict(o)
-	if err != nil {
-		return err
+func intListParam(json_data map[string]interface{}, key string) ([]int, error) {
+	// A JSON list of numbers, or a comma separated string from multipart forms
+	var values []interface{}
+	switch v := json_data[key].(type) {
+	case []interface{}:
+		values = v
+	case string:
+		for _, part := range strings.Split(v, ",") {
+			values = append(values, strings.TrimSpace(part))
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a list of numbers, got %s", key, jsonType(json_data[key]))
 	}
+	list := make([]int, len(values))
+	for i, value := range values {
+		n, err := intParam(map[string]interface{}{key: value}, key, 0)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = n
+	}
+	return list, nil
+}
 
-	if len(dDest) == 0 {
-		rootDictDest["AcroForm"] = dSrc
-		return nil
+func floatListParam(json_data map[string]interface{}, key string) ([]float64, error) {
+	// Same as intListParam for fractional numbers
+	var values []interface{}
+	switch v := json_data[key].(type) {
+	case []interface{}:
+		values = v
+	case string:
+		for _, part := range strings.Split(v, ",") {
+			values = append(values, strings.TrimSpace(part))
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a list of numbers, got %s", key, jsonType(json_data[key]))
+	}
+	list := make([]float64, len(values))
+	for i, value := range values {
+		n, err := floatParam(map[string]interface{}{key: value}, key)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			return nil, fmt.Errorf("%s must be a list of numbers, got null in it", key)
+		}
+		list[i] = *n
 	}
+	return list, nil
+}
 
-	// Retrieve ctxDest AcroForm Fields
-	o, found = dDest.Find("Fields")
-	if !found {
-		rootDictDest["AcroForm"] = dSrc
-		return nil
+func outputDirParam(c *gin.Context, json_data map[string]interface{}) (string, bool) {
+	out_dir, ok := json_data["output_dir"].(string)
+	if !ok || out_dir == "" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. output_dir is required"}})
+		return "", false
 	}
-	arrFieldsDest, err := ctxDest.DereferenceArray(o)
-	if err != nil {
-		return err
+	out_dir, ok = outputPath(c, "output_dir", out_dir)
+	if !ok {
+		return "", false
 	}
-	if len(arrFieldsDest) == 0 {
-		rootDictDest["AcroForm"] = dSrc
-		return nil
+	if info, err := os.Stat(out_dir); err != nil || !info.IsDir() {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. output_dir %s must be an existing directory", out_dir)}})
+		return "", false
 	}
+	return out_dir, true
+}
 
-	// Merge Dsrc into dDest.
-
-	// Fields: add all indrefs
+func outputFileParam(c *gin.Context, json_data map[string]interface{}) (string, bool) {
+	out_path, ok := json_data["output_file"].(string)
+	if !ok || out_path == "" {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. output_file is required"}})
+		return "", false
+	}
+	return outputPath(c, "output_file", out_path)
+}
 
-	// Merge all fields from ctxSrc into ctxDest
-	arrFieldsDest = append(arrFieldsDest, arrFieldsSrc...)
-	dDest["Fields"] = arrFieldsDest
+func pageSelectorsParam(c *gin.Context, v interface{}, paths []string) ([]gopdf.PageSelector, bool) {
+	/*
+		The selectors of /merge: a list of {"file": ..., "pages": ...}, file being one of
+		the input_files paths or its index from 0, pages a page selection (every page when
+		it's left out). A selector that's no good is a 400 saying which one.
+	*/
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. selectors must be a non-empty list of {\"file\", \"pages\"} objects, got %s", jsonType(v))}})
+		return nil, false
+	}
+	selectors := make([]gopdf.PageSelector, len(list))
+	for i, item := range list {
+		invalid := func(problem string) ([]gopdf.PageSelector, bool) {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. selector %d: %s", i, problem)}, Data: gin.H{"selector": i}})
+			return nil, false
+		}
+		selector, ok := item.(map[string]interface{})
+		if !ok {
+			return invalid(fmt.Sprintf("must be a {\"file\", \"pages\"} object, got %s", jsonType(item)))
+		}
+		switch file := selector["file"].(type) {
+		case string:
+			selectors[i].File = -1
+			for idx, path := range paths {
+				if path == file {
+					selectors[i].File = idx
+					break
+				}
+			}
+			if selectors[i].File < 0 {
+				return invalid(fmt.Sprintf("file %q is not one of input_files", file))
+			}
+		case float64:
+			if file != float64(int(file)) || file < 0 || int(file) >= len(paths) {
+				return invalid(fmt.Sprintf("file %v is not an index of input_files, there are %d", file, len(paths)))
+			}
+			selectors[i].File = int(file)
+		default:
+			return invalid(fmt.Sprintf("file must be one of input_files or its index, got %s", jsonType(selector["file"])))
+		}
+		if pages, found := selector["pages"]; found {
+			selectors[i].Pages, ok = pages.(string)
+			if !ok {
+				return invalid(fmt.Sprintf("pages must be a page selection like \"1-3,5\", got %s", jsonType(pages)))
+			}
+			if _, err := api.ParsePageSelection(selectors[i].Pages); selectors[i].Pages != "" && err != nil {
+				return invalid(fmt.Sprintf("invalid pages %q: %s", selectors[i].Pages, strings.TrimSpace(err.Error())))
+			}
+		}
+	}
+	return selectors, true
+}
 
-	return handleFormAttributes(ctxSource, ctxDest, dSrc, dDest, arrFieldsSrc)
+func pagesParam(c *gin.Context, json_data map[string]interface{}) (string, bool) {
+	/*
+		Optional pdfcpu page selection ("1-3,5") under pages, its syntax is checked
+		here so a bad one is a 400 before any file gets touched
+	*/
+	if _, found := json_data["pages"]; found {
+		if _, ok := json_data["pages"].(string); !ok {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. pages must be a page selection like \"1-3,5\", got %s", jsonType(json_data["pages"]))}})
+			return "", false
+		}
+	}
+	pages, _ := json_data["pages"].(string)
+	if pages != "" {
+		if _, err := api.ParsePageSelection(pages); err != nil {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. invalid pages %q: %s", pages, strings.TrimSpace(err.Error()))}})
+			return "", false
+		}
+	}
+	return pages, true
 }
 
-func handleNeedAppearances(ctxSource *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
-	o, found := dSrc.Find("NeedAppearances")
-	if !found || o == nil {
-		return nil
+func setPasswords(c *gin.Context, json_data map[string]interface{}, input_files []gopdf.InputFile) bool {
+	/*
+		Encrypted files are opened with password, or with their own entry in
+		passwords (file path or upload name -> password) when they have one
+	*/
+	password, ok := json_data["password"].(string)
+	if _, found := json_data["password"]; found && !ok {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. password must be a string, got %s", jsonType(json_data["password"]))}})
+		return false
 	}
-	b, err := ctxSource.DereferenceBoolean(o, pdfcpu.V10)
-	if err != nil {
-		return err
+	passwords, ok := json_data["passwords"].(map[string]interface{})
+	if _, found := json_data["passwords"]; found && !ok {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. passwords must be an object mapping files to passwords, got %s", jsonType(json_data["passwords"]))}})
+		return false
 	}
-	if b != nil && *b {
-		dDest["NeedAppearances"] = pdfcpu.Boolean(true)
+	file_passwords := make(map[string]string, len(passwords))
+	for name, v := range passwords {
+		file_password, ok := v.(string)
+		if !ok {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. password for %s must be a string, got %s", name, jsonType(v))}})
+			return false
+		}
+		file_passwords[name] = file_password
 	}
-	return nil
+	usePasswords(input_files, password, file_passwords)
+	return true
 }
 
-func handleSigFields(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
-	o, found := dSrc.Find("SigFields")
-	if !found {
-		return nil
+func setValidation(c *gin.Context, json_data map[string]interface{}, input_files []gopdf.InputFile) bool {
+	/*
+		The validation options of the request for all input_files: validation is "relaxed"
+		(the default), "strict" or "none", repair_xref rebuilds broken xref tables
+	*/
+	mode, ok := json_data["validation"].(string)
+	if v, found := json_data["validation"]; found && v != nil && !ok {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. validation must be a string, got %s", jsonType(v))}})
+		return false
 	}
-	iSrc, err := ctxSource.DereferenceInteger(o)
-	if err != nil {
-		return err
+	var repair_xref bool
+	switch v := json_data["repair_xref"].(type) {
+	case nil:
+	case bool:
+		repair_xref = v
+	case string:
+		// Multipart forms
+		repair_xref = v == "true"
+	default:
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. repair_xref must be a boolean, got %s", jsonType(v))}})
+		return false
 	}
-	if iSrc == nil {
-		return nil
+	return useValidation(c, input_files, gopdf.ValidationOptions{Mode: mode, RepairXRef: repair_xref})
+}
+
+func useValidation(c *gin.Context, input_files []gopdf.InputFile, options gopdf.ValidationOptions) bool {
+	if err := gopdf.CheckValidationMode(options.Mode); err != nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %v", err)}})
+		return false
 	}
-	// Merge SigFields into dDest.
-	o, found = dDest.Find("SigFlags")
-	if !found {
-		dDest["SigFields"] = pdfcpu.Integer(*iSrc)
-		return nil
+	for i := range input_files {
+		input_files[i].Validation = options
 	}
-	iDest, err := ctxDest.DereferenceInteger(o)
-	if err != nil {
-		return err
+	return true
+}
+
+func usePasswords(input_files []gopdf.InputFile, password string, passwords map[string]string) {
+	for i := range input_files {
+		input_files[i].Password = password
+		if file_password, found := passwords[input_files[i].Name]; found {
+			input_files[i].Password = file_password
+		}
 	}
-	if iDest == nil {
-		dDest["SigFields"] = pdfcpu.Integer(*iSrc)
-		return nil
+}
+
+func bindJSON(c *gin.Context, req interface{}) bool {
+	/*
+		Decodes a JSON body into req and checks its binding tags,
+		sends a 400 naming the JSON field that's wrong or missing when it's no good
+	*/
+	err := c.ShouldBindJSON(req)
+	var validation_errors validator.ValidationErrors
+	if errors.As(err, &validation_errors) {
+		messages := make([]string, len(validation_errors))
+		for i, field_error := range validation_errors {
+			messages[i] = fmt.Sprintf("Bad Request. %s is %s", field_error.Field(), field_error.Tag())
+		}
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: messages})
+		return false
 	}
-	// SignaturesExist
-	if *iSrc&1 > 0 {
-		*iDest |= 1
+	if err != nil {
+		errorHandler(0, err, c)
+		return false
 	}
-	// AppendOnly
-	if *iSrc&2 > 0 {
-		*iDest |= 2
+	return true
+}
+
+func formGenerateRequest(c *gin.Context, json_data map[string]interface{}) (GenerateRequest, bool) {
+	/*
+		The GenerateRequest of a multipart form, whose fields are all strings but context_json_file.
+		Its booleans have to be "true" or "false", anything else is a 400 like a JSON body's non-booleans.
+	*/
+	flags := make(map[string]bool)
+	for _, key := range []string{"flatten", "dry_run", "strict_formats", "truncate_to_max_len", "reproducible"} {
+		switch v := json_data[key].(type) {
+		case nil:
+		case string:
+			if v != "true" && v != "false" {
+				sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. %s must be true or false, got %q", key, v)}})
+				return GenerateRequest{}, false
+			}
+			flags[key] = v == "true"
+		}
 	}
-	return nil
+	out_path, _ := json_data["output_file"].(string)
+	reproducible_date, _ := json_data["reproducible_date"].(string)
+	tab_order, _ := json_data["tab_order"].(string)
+	form_data_path, _ := json_data["form_data_file"].(string)
+	return GenerateRequest{Context: json_data["context_json_file"], FormDataFile: form_data_path, Output: out_path, Flatten: flags["flatten"], DryRun: flags["dry_run"], StrictFormats: flags["strict_formats"], TruncateToMaxLen: flags["truncate_to_max_len"], Reproducible: flags["reproducible"], ReproducibleDate: reproducible_date, TabOrder: tab_order, Transforms: json_data["transforms"]}, true
 }
 
-func handleCO(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
-	o, found := dSrc.Find("CO")
-	if !found {
-		return nil
+func transformsParam(c *gin.Context, v interface{}) (map[string][]string, bool) {
+	/*
+		The transforms of a /generate request: an object of context keys, or "*", to a
+		transform name or a list of them. Sends a 400 when it's not that or a name isn't
+		one of gopdf.TransformNames.
+	*/
+	if v == nil {
+		return nil, true
 	}
-	arrSrc, err := ctxSource.DereferenceArray(o)
-	if err != nil {
-		return err
+	bad_request := func(message string) (map[string][]string, bool) {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. " + message}})
+		return nil, false
 	}
-	o, found = dDest.Find("CO")
-	if !found {
-		dDest["CO"] = arrSrc
-		return nil
+	object, ok := v.(map[string]interface{})
+	if !ok {
+		return bad_request(fmt.Sprintf("transforms must be an object mapping context keys to transforms, got %s", jsonType(v)))
 	}
-	arrDest, err := ctxDest.DereferenceArray(o)
-	if err != nil {
-		return err
+	transforms := make(map[string][]string, len(object))
+	for key, value := range object {
+		switch value := value.(type) {
+		case string:
+			transforms[key] = []string{value}
+		case []interface{}:
+			names := make([]string, len(value))
+			for i, item := range value {
+				name, ok := item.(string)
+				if !ok {
+					return bad_request(fmt.Sprintf("transforms of %q must be strings, got %s", key, jsonType(item)))
+				}
+				names[i] = name
+			}
+			transforms[key] = names
+		default:
+			return bad_request(fmt.Sprintf("transforms of %q must be a transform or a list of them, got %s", key, jsonType(value)))
+		}
 	}
-	if len(arrDest) == 0 {
-		dDest["CO"] = arrSrc
-	} else {
-		arrDest = append(arrDest, arrSrc...)
-		dDest["CO"] = arrDest
+	if err := gopdf.CheckTransforms(transforms); err != nil {
+		return bad_request(err.Error())
 	}
-	return nil
+	return transforms, true
 }
 
-func handleDR(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
-	o, found := dSrc.Find("DR")
-	if !found {
-		return nil
+func generateContext(c *gin.Context, req GenerateRequest) (map[string]interface{}, bool) {
+	/*
+		The field values come from either context_json_file (see loadContext) or form_data_file,
+		an FDF or XFDF file as a server side path or a file part in multipart forms.
+		Its fields that aren't in any input file end up in missing like context keys do.
+	*/
+	var form_data *gopdf.InputFile
+	if c.Request.MultipartForm != nil {
+		if form_data_files := uploadedFiles(c.Request.MultipartForm, "form_data_file"); len(form_data_files) == 1 {
+			form_data = &form_data_files[0]
+		}
 	}
-	dSrc, err := ctxSource.DereferenceDict(o)
-	if err != nil {
-		return err
+	if form_data == nil && req.FormDataFile != "" {
+		path_file := retryingFile(c, gopdf.PathFile(req.FormDataFile))
+		form_data = &path_file
 	}
-	if len(dSrc) == 0 {
-		return nil
+	has_context := req.Context != nil && req.Context != ""
+	if form_data != nil && has_context {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. context_json_file and form_data_file can't both be given"}})
+		return nil, false
 	}
-	o, found = dDest.Find("DR")
-	if !found {
-		dDest["DR"] = dSrc
-	} else {
-		dDest, err := ctxDest.DereferenceDict(o)
-		if err != nil {
-			return err
-		}
-		if len(dDest) == 0 {
-			dDest["DR"] = dSrc
+	if form_data == nil {
+		if !has_context {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. context_json_file or form_data_file is required"}})
+			return nil, false
 		}
+		return loadContext(c, req.Context)
 	}
-	return nil
+
+	context, err := gopdf.ReadFormData(*form_data)
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. form_data_file %s: %v", form_data.Name, err)}})
+		return nil, false
+	}
+	return context, true
 }
 
-func handleDA(ctxSource *pdfcpu.Context, dSrc, dDest pdfcpu.Dict, arrFieldsSrc pdfcpu.Array) error {
-	// (for each with field type  /FT /Tx w/o DA, set DA to default DA)
-	// TODO Walk field tree and inspect terminal fields.
+// How much of a context_json_file we read from disk or a URL
+const context_max_bytes = 10 << 20
 
-	sSrc := dSrc.StringEntry("DA")
-	if sSrc == nil || len(*sSrc) == 0 {
-		return nil
-	}
-	sDest := dDest.StringEntry("DA")
-	if sDest == nil {
-		dDest["DA"] = pdfcpu.StringLiteral(*sSrc)
-		return nil
-	}
-	// Push sSrc down to all top level fields of dSource
-	for _, o := range arrFieldsSrc {
-		d, err := ctxSource.DereferenceDict(o)
-		if err != nil {
-			return err
+func loadContext(c *gin.Context, value interface{}) (map[string]interface{}, bool) {
+	/*
+		context_json_file is either the field values inline (an object, used as is),
+		or a string, which is the path of a JSON file of field values on the server
+		or an http(s) URL to fetch it from. A loaded file has to be a flat object of
+		field name -> string, number or boolean, or a list of those for multi select
+		list boxes. Sends a 400 when it's neither or no good.
+	*/
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case string:
+		if v == "" {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{"Bad Request. context_json_file is required"}})
+			return nil, false
 		}
-		n := d.NameEntry("FT")
-		if n != nil && *n == "Tx" {
-			_, found := d.Find("DA")
-			if !found {
-				d["DA"] = pdfcpu.StringLiteral(*sSrc)
-			}
+		context, err := readContextJSON(c, v)
+		if err != nil {
+			sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. context_json_file is a string so it is read as the path or URL of a JSON file: %v", err)}})
+			return nil, false
 		}
+		return context, true
 	}
-	return nil
+	sendResponse(c, Response{Status: http.StatusBadRequest, Error: []string{fmt.Sprintf("Bad Request. context_json_file must be an object mapping field names to values or the path or URL of a JSON file holding one, got %s", jsonType(value))}})
+	return nil, false
 }
 
-func handleQ(ctxSource *pdfcpu.Context, dSrc, dDest pdfcpu.Dict, arrFieldsSrc pdfcpu.Array) error {
-	// (for each with field type /FT /Tx w/o Q, set Q to default Q)
-	// TODO Walk field tree and inspect terminal fields.
-
-	iSrc := dSrc.IntEntry("Q")
-	if iSrc == nil {
-		return nil
+func readContextJSON(c *gin.Context, location string) (map[string]interface{}, error) {
+	// Fetching or reading it is retried as a whole on transient errors
+	var data []byte
+	err := withRetry(c, location, func() error {
+		var err error
+		data, err = readLocation(c.Request.Context(), location, context_max_bytes+1)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	iDest := dDest.IntEntry("Q")
-	if iDest == nil {
-		dDest["Q"] = pdfcpu.Integer(*iSrc)
-		return nil
+	if len(data) > context_max_bytes {
+		return nil, fmt.Errorf("%s is bigger than %d bytes", location, context_max_bytes)
 	}
-	// Push iSrc down to all top level fields of dSource
-	for _, o := range arrFieldsSrc {
-		d, err := ctxSource.DereferenceDict(o)
-		if err != nil {
-			return err
+	var loaded interface{}
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("%s is not valid JSON: %v", location, err)
+	}
+	context, ok := loaded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must hold an object mapping field names to values, got %s", location, jsonType(loaded))
+	}
+	for field_name, field_value := range context {
+		values, is_list := field_value.([]interface{})
+		if !is_list {
+			values = []interface{}{field_value}
 		}
-		n := d.NameEntry("FT")
-		if n != nil && *n == "Tx" {
-			_, found := d.Find("Q")
-			if !found {
-				d["Q"] = pdfcpu.Integer(*iSrc)
+		for _, value := range values {
+			switch value.(type) {
+			case string, float64, bool:
+			default:
+				return nil, fmt.Errorf("%s: value of %q must be a string, number or boolean or a list of them, got %s", location, field_name, jsonType(field_value))
 			}
 		}
 	}
-	return nil
+	return context, nil
 }
 
-func handleFormAttributes(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict, arrFieldsSrc pdfcpu.Array) error {
-
-	// NeedAppearances: try: set to true only
-	if err := handleNeedAppearances(ctxSource, dSrc, dDest); err != nil {
-		return err
+func readLocation(ctx context.Context, location string, limit int64) ([]byte, error) {
+	// Up to limit bytes of the http(s) URL or path location
+	if isURL(location) {
+		return fetchURL(ctx, location, limit)
+	}
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, limit))
+}
 
-	// SigFlags: set bit 1 to true only (SignaturesExist)
-	//           set bit 2 to true only (AppendOnly)
-	if err := handleSigFields(ctxSource, ctxDest, dSrc, dDest); err != nil {
-		return err
+func pathFile(c *gin.Context, path string) gopdf.InputFile {
+	// An input PDF on the server, opening it is measured and retried
+	return measuredFile(retryingFile(c, gopdf.PathFile(path)))
+}
+
+func floatParam(json_data map[string]interface{}, key string) (*float64, error) {
+	// Same as intParam for optional fractional numbers, nil when the key isn't there
+	switch v := json_data[key].(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return &v, nil
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &n, nil
 	}
+	return nil, fmt.Errorf("%s must be a number, got %s", key, jsonType(json_data[key]))
+}
 
-	// CO: add all indrefs
-	if err := handleCO(ctxSource, ctxDest, dSrc, dDest); err != nil {
-		return err
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
 	}
+	return false
+}
 
-	// DR: default resource dict
-	if err := handleDR(ctxSource, ctxDest, dSrc, dDest); err != nil {
-		return err
+func cleanupRequest(c *gin.Context) {
+	// Removes the temp files the multipart parser may have spilled uploads to
+	if c.Request.MultipartForm != nil {
+		open_forms.Lock()
+		delete(open_forms.forms, c.Request.MultipartForm)
+		open_forms.Unlock()
+		c.Request.MultipartForm.RemoveAll()
 	}
+}
 
-	// DA: default appearance streams for variable text fields
-	if err := handleDA(ctxSource, dSrc, dDest, arrFieldsSrc); err != nil {
-		return err
+func formJSON(form *multipart.Form) (map[string]interface{}, error) {
+	/*
+		Turns the regular fields of a multipart form into the same map a JSON body decodes to.
+		context_json_file, passwords, renames, transforms and selectors are sent as JSON strings so they get decoded, everything else stays a string.
+		A context_json_file that isn't an object is taken as the path or URL of one and left as is.
+		fields is sent as one part per field name and becomes the list of them.
+	*/
+	json_data := make(map[string]interface{})
+	for key, values := range form.Value {
+		if len(values) == 0 {
+			continue
+		}
+		if key == "context_json_file" && !strings.HasPrefix(strings.TrimSpace(values[0]), "{") {
+			// Not an object so it's the path or URL of one
+			json_data[key] = values[0]
+			continue
+		}
+		if key == "context_json_file" || key == "passwords" || key == "renames" || key == "transforms" || key == "selectors" {
+			var context interface{}
+			err := json.Unmarshal([]byte(values[0]), &context)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not valid JSON: %v", key, err)
+			}
+			json_data[key] = context
+			continue
+		}
+		if key == "fields" {
+			fields := make([]interface{}, len(values))
+			for i, value := range values {
+				fields[i] = value
+			}
+			json_data[key] = fields
+			continue
+		}
+		json_data[key] = values[0]
 	}
+	return json_data, nil
+}
 
-	// Q: left, center, right for variable text fields
-	if err := handleQ(ctxSource, dSrc, dDest, arrFieldsSrc); err != nil {
+func validateAddr(addr string) error {
+	// Same shape net.Listen wants: "host:port", host can be empty to listen on every interface
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
 		return err
 	}
-
-	// XFA: ignore
-	delete(dDest, "XFA")
-
+	port_number, err := strconv.Atoi(port)
+	if err != nil || port_number < 0 || port_number > 65535 {
+		return fmt.Errorf("port %q must be a number between 0 and 65535", port)
+	}
 	return nil
 }
+
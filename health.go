@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	/healthcheck is a readiness check: the temp directory takes files, pdfcpu can write
+	and read a PDF and merge two forms (found out once at startup) and, when the environment is set up for S3 output, the AWS credentials
+	resolve. Any of those failing is a 503 listing the failed checks. /livez only says
+	the process is up, for liveness probes that shouldn't restart us over S3 being down.
+*/
+
+// How long the healthcheck waits for the AWS credentials
+const health_s3_timeout = 5 * time.Second
+
+// The pdfcpu checks test the code we're built with, which can't change while we run,
+// so they run once (at startup, see checkPDFCPU) and every healthcheck reports that result
+var (
+	pdfcpu_check       = sync.OnceValue(gopdf.SelfCheck)
+	pdfcpu_merge_check = sync.OnceValue(gopdf.MergeSelfCheck)
+)
+
+func healthcheckHandler(c *gin.Context) {
+	checks := map[string]func() error{
+		"temp_dir": checkTempDir,
+		"pdfcpu":   pdfcpu_check,
+		// Catches a pdfcpu upgrade breaking /merge, which relies on its internals
+		"pdfcpu_merge": pdfcpu_merge_check,
+	}
+	if s3Configured() {
+		checks["s3_credentials"] = func() error {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), health_s3_timeout)
+			defer cancel()
+			return checkS3Credentials(ctx)
+		}
+	}
+
+	failed := make(map[string]string)
+	for name, check := range checks {
+		if err := check(); err != nil {
+			failed[name] = err.Error()
+		}
+	}
+	if len(failed) > 0 {
+		messages := make([]string, 0, len(failed))
+		for name, message := range failed {
+			messages = append(messages, fmt.Sprintf("%s: %s", name, message))
+		}
+		sort.Strings(messages)
+		sendResponse(c, Response{Status: http.StatusServiceUnavailable, Error: messages, Body: gin.H{"Health": "Bad", "failed_checks": failed, "code": code_unhealthy}})
+		return
+	}
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"Health": "Good!"}})
+}
+
+func checkPDFCPU(logger *slog.Logger) {
+	// Runs the pdfcpu checks before the first healthcheck needs them, logging what fails
+	for name, check := range map[string]func() error{"pdfcpu": pdfcpu_check, "pdfcpu_merge": pdfcpu_merge_check} {
+		if err := check(); err != nil {
+			logger.Error("pdfcpu self check failed, /healthcheck will report unhealthy", "check", name, "error", err.Error())
+		}
+	}
+}
+
+func livezHandler(c *gin.Context) {
+	sendResponse(c, Response{Status: http.StatusOK, Body: gin.H{"Health": "Good!"}})
+}
+
+func checkTempDir() error {
+	f, err := os.CreateTemp(temp_dir, "healthcheck-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte("ok"))
+	f.Close()
+	os.Remove(f.Name())
+	return err
+}
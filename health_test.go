@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthcheck(t *testing.T) {
+	r := testRouter()
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthcheck", nil))
+		if body := responseBody(t, recorder); recorder.Code != http.StatusOK || body["Health"] != "Good!" {
+			t.Errorf("healthcheck %d: got %d %v", i+1, recorder.Code, body)
+		}
+	}
+}
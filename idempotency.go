@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	Idempotency keys: a client that retries a request sends the same Idempotency-Key
+	header each time and gets the response of the first attempt back instead of a rerun.
+	A retry that comes in while the first attempt is still running is a 409. Responses
+	are kept in memory for ttl after they were sent. 5xx responses aren't kept, nothing
+	got written before those (outputs are written atomically) so the retry just runs again.
+	The key is all we match on, a different request sent with the same key gets the
+	response of the first one.
+*/
+
+const idempotency_header = "Idempotency-Key"
+
+// Responses bigger than this (a filled PDF in the body) aren't kept, their retries run again
+const idempotency_max_body = 10 << 20
+
+type idempotentResponse struct {
+	done    bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+type idempotencyStore struct {
+	sync.Mutex
+	ttl       time.Duration
+	responses map[string]*idempotentResponse
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, responses: make(map[string]*idempotentResponse)}
+}
+
+// recordingWriter keeps a copy of what the handler writes
+type recordingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	too_long bool
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recordingWriter) record(data []byte) {
+	if w.too_long || w.body.Len()+len(data) > idempotency_max_body {
+		w.too_long = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+func idempotent(store *idempotencyStore) gin.HandlerFunc {
+	/*
+		Replays the response of an earlier request with the same Idempotency-Key,
+		requests without one, or with a ttl of 0, go straight through.
+	*/
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotency_header)
+		if key == "" || store.ttl <= 0 {
+			c.Next()
+			return
+		}
+		// Keys are per endpoint
+		key = c.FullPath() + " " + key
+
+		store.Lock()
+		now := time.Now()
+		for k, response := range store.responses {
+			if response.done && now.After(response.expires) {
+				delete(store.responses, k)
+			}
+		}
+		response, found := store.responses[key]
+		if found && !response.done {
+			store.Unlock()
+			sendResponse(c, Response{Status: http.StatusConflict, Error: []string{"Conflict. a request with this Idempotency-Key is still running"}})
+			c.Abort()
+			return
+		}
+		if found {
+			store.Unlock()
+			requestLogger(c).Info("replaying idempotent response", "status", response.status)
+			for name, values := range response.header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(response.status, response.header.Get("Content-Type"), response.body)
+			c.Abort()
+			return
+		}
+		response = &idempotentResponse{}
+		store.responses[key] = response
+		store.Unlock()
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		finished := false
+		// Deferred so a panicking handler doesn't leave the key running forever
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			store.Lock()
+			defer store.Unlock()
+			if !finished || !writer.Written() || writer.Status() >= 500 || writer.too_long {
+				delete(store.responses, key)
+				return
+			}
+			response.done = true
+			response.status = writer.Status()
+			response.header = http.Header{}
+			for _, name := range []string{"Content-Type", "Content-Disposition"} {
+				if value := writer.Header().Get(name); value != "" {
+					response.header.Set(name, value)
+				}
+			}
+			response.body = writer.body.Bytes()
+			response.expires = time.Now().Add(store.ttl)
+		}()
+		c.Next()
+		finished = true
+	}
+}
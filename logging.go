@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Structured logging: one JSON line per event on stderr, every line logged
+	while handling a request carries its request_id so a whole /generate call
+	can be followed through the logs. Panics are logged here too, with their
+	stack, and answered with a JSON 500 like every other error.
+*/
+
+const request_id_header = "X-Request-ID"
+
+func newLogger(level string) (*slog.Logger, error) {
+	var log_level slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		log_level = slog.LevelDebug
+	case "", "info":
+		log_level = slog.LevelInfo
+	case "warn":
+		log_level = slog.LevelWarn
+	case "error":
+		log_level = slog.LevelError
+	default:
+		return nil, fmt.Errorf("log level must be debug, info, warn or error, got %q", level)
+	}
+	return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: log_level})), nil
+}
+
+func requestLogging(logger *slog.Logger) gin.HandlerFunc {
+	/*
+		Gives every request an ID (the caller's X-Request-ID when it sends one),
+		a logger carrying it for the handlers, and an access log line once it's done.
+		The healthchecks only log at debug, orchestrators call them all the time.
+	*/
+	return func(c *gin.Context) {
+		start := time.Now()
+		request_id := c.GetHeader(request_id_header)
+		if request_id == "" {
+			request_id = newRequestID()
+		}
+		c.Header(request_id_header, request_id)
+
+		request_logger := logger.With("request_id", request_id, "endpoint", c.FullPath())
+		c.Set("logger", request_logger)
+		c.Set("request_id", request_id)
+
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.FullPath() == "/healthcheck" || c.FullPath() == "/livez" {
+			level = slog.LevelDebug
+		}
+		request_logger.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+func envGinMode(env string) (string, error) {
+	// The gin mode for a GOPDF_ENV, release unless it's a development environment
+	switch strings.ToLower(env) {
+	case "", "production", "prod":
+		return gin.ReleaseMode, nil
+	case "development", "dev":
+		return gin.DebugMode, nil
+	case "test":
+		return gin.TestMode, nil
+	}
+	return "", fmt.Errorf("must be production, development or test, got %q", env)
+}
+
+func recoverPanics() gin.HandlerFunc {
+	/*
+		Turns a panic in a handler into a 500 through sendResponse, logged with the request's
+		logger and its stack. The panic value stays in the log, the response only has the
+		request ID to look it up by. When the response was already being written there's
+		nothing left to answer with, a client that went away panics with http.ErrAbortHandler.
+	*/
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			requestLogger(c).Error("panic", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			if c.Writer.Written() || recovered == http.ErrAbortHandler {
+				c.Abort()
+				return
+			}
+			sendResponse(c, Response{Status: http.StatusInternalServerError, Error: []string{fmt.Sprintf("Internal Server Error. request %s failed unexpectedly", requestID(c))}})
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+func requestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+func requestLogger(c *gin.Context) *slog.Logger {
+	// The logger requestLogging set up for this request
+	if logger, ok := c.Get("logger"); ok {
+		return logger.(*slog.Logger)
+	}
+	return slog.Default()
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+func fileNames(input_files []gopdf.InputFile) []string {
+	names := make([]string, len(input_files))
+	for i, in_file := range input_files {
+		names[i] = in_file.Name
+	}
+	return names
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	base, err := os.MkdirTemp("", "gopdf-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err = setupTempDir(base, slog.Default()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	removeTempDir()
+	os.RemoveAll(base)
+	os.Exit(code)
+}
+
+func testRouter() *gin.Engine {
+	return newRouter(slog.Default(), nil, 50<<20, 0, 0)
+}
+
+func testPDF(objs ...string) []byte {
+	/*
+		A one page PDF: the catalog 1, pages 2 and page 3 come before objs, which start at 4.
+		A catalog with an AcroForm can be given as the first of objs, it replaces ours.
+		pdfcpu won't read files under 512 bytes, a comment after the header pads them.
+	*/
+	all := []string{"<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [3 0 R] /Count 1 >>", "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>"}
+	if len(objs) > 0 && strings.Contains(objs[0], "/Type /Catalog") {
+		all[0], objs = objs[0], objs[1:]
+	}
+	all = append(all, objs...)
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n%" + strings.Repeat(" ", 512) + "\n")
+	offsets := make([]int, len(all))
+	for i, obj := range all {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(all)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(all)+1, xref)
+	return out.Bytes()
+}
+
+// A form with the text field name
+var test_form = testPDF(
+	"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R] /DA (/Helv 0 Tf 0 g) >> >>",
+	"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>",
+)
+
+func writeTestPDF(t *testing.T, content []byte) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "*.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func serveJSON(t *testing.T, r http.Handler, path string, body interface{}) *httptest.ResponseRecorder {
+	// POSTs body to path, a string as it is and anything else as JSON
+	t.Helper()
+	var data []byte
+	if s, ok := body.(string); ok {
+		data = []byte(s)
+	} else {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func serveMultipart(t *testing.T, r http.Handler, path string, files_key string, files map[string][]byte, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, content := range files {
+		part, err := writer.CreateFormFile(files_key, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func responseBody(t *testing.T, recorder *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%d response isn't a JSON object: %v: %s", recorder.Code, err, recorder.Body)
+	}
+	return body
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func mergedPageCount(t *testing.T, out_path string) int {
+	t.Helper()
+	ctx, err := api.ReadContextFile(out_path)
+	if err != nil {
+		t.Fatalf("reading the merged file: %v", err)
+	}
+	return ctx.PageCount
+}
+
+func TestMergeUploads(t *testing.T) {
+	r := testRouter()
+	out_dir := t.TempDir()
+	files := map[string][]byte{"first.pdf": test_form, "second.pdf": testPDF()}
+
+	out_path := filepath.Join(out_dir, "merged.pdf")
+	recorder := serveMultipart(t, r, "/merge", "input_files", files, map[string]string{"output_file": out_path, "dedupe": "true"})
+	if body := checkStatus(t, "uploads", recorder); recorder.Code != http.StatusOK || body["dedupe"] == nil {
+		t.Fatalf("uploads: got %d %v, want a 200 with dedupe", recorder.Code, body)
+	}
+	if pages := mergedPageCount(t, out_path); pages != 2 {
+		t.Errorf("uploads: merged %d pages, want 2", pages)
+	}
+
+	// Selectors name the uploads by their file names
+	out_path = filepath.Join(out_dir, "selected.pdf")
+	recorder = serveMultipart(t, r, "/merge", "input_files", map[string][]byte{"first.pdf": test_form}, map[string]string{
+		"output_file": out_path,
+		"selectors":   `[{"file": "first.pdf"}, {"file": 0, "pages": "1"}]`,
+	})
+	if body := checkStatus(t, "selectors", recorder); recorder.Code != http.StatusOK {
+		t.Fatalf("selectors: got %d %v, want 200", recorder.Code, body)
+	}
+	if pages := mergedPageCount(t, out_path); pages != 2 {
+		t.Errorf("selectors: merged %d pages, want 2", pages)
+	}
+}
+
+func TestMergeRequestChecks(t *testing.T) {
+	// What readRequest checks for the other endpoints /merge gets too
+	r := testRouter()
+	out_path := filepath.Join(t.TempDir(), "merged.pdf")
+	in_path := writeTestPDF(t, test_form)
+	for _, test := range []struct {
+		what   string
+		serve  func() int
+		status int
+	}{
+		{"an upload that isn't a PDF", func() int {
+			return serveMultipart(t, r, "/merge", "input_files", map[string][]byte{"a.pdf": test_form, "b.pdf": []byte("just some text")}, map[string]string{"output_file": out_path}).Code
+		}, http.StatusUnsupportedMediaType},
+		{"one upload", func() int {
+			return serveMultipart(t, r, "/merge", "input_files", map[string][]byte{"a.pdf": test_form}, map[string]string{"output_file": out_path}).Code
+		}, http.StatusBadRequest},
+		{"broken selectors", func() int {
+			return serveMultipart(t, r, "/merge", "input_files", map[string][]byte{"a.pdf": test_form}, map[string]string{"output_file": out_path, "selectors": "[{"}).Code
+		}, http.StatusBadRequest},
+		{"an unknown validation", func() int {
+			return serveJSON(t, r, "/merge", map[string]interface{}{"input_files": []string{in_path, in_path}, "output_file": out_path, "validation": "sloppy"}).Code
+		}, http.StatusBadRequest},
+		{"input_files that aren't paths", func() int {
+			return serveJSON(t, r, "/merge", map[string]interface{}{"input_files": []interface{}{in_path, 1}, "output_file": out_path}).Code
+		}, http.StatusBadRequest},
+		{"strict validation", func() int {
+			return serveJSON(t, r, "/merge", map[string]interface{}{"input_files": []string{in_path, in_path}, "output_file": out_path, "validation": "strict"}).Code
+		}, http.StatusOK},
+	} {
+		if status := test.serve(); status != test.status {
+			t.Errorf("%s: got %d, want %d", test.what, status, test.status)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Prometheus metrics, served on /metrics: requests and their durations per endpoint,
+	the sizes of the PDFs we read, how many PDF jobs are running right now and how
+	the template cache does.
+	A job is one request to a PDF endpoint, or one job of a /generate-batch.
+*/
+
+var (
+	http_requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gopdf_http_requests_total",
+		Help: "Requests handled, by endpoint, method and status code.",
+	}, []string{"endpoint", "method", "status"})
+
+	http_request_duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gopdf_http_request_duration_seconds",
+		Help:    "How long requests took, by endpoint.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"endpoint"})
+
+	pdf_size = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "gopdf_pdf_size_bytes",
+		Help: "Sizes of the input PDFs read, each file counted once per request.",
+		// 1KiB to 256MiB
+		Buckets: prometheus.ExponentialBuckets(1<<10, 4, 10),
+	})
+
+	pdf_jobs_in_flight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gopdf_pdf_jobs_in_flight",
+		Help: "PDF jobs being processed right now.",
+	})
+
+	_ = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "gopdf_template_cache_hits_total",
+		Help: "Templates read by path that were found in the template cache.",
+	}, func() float64 {
+		hits, _, _ := gopdf.TemplateCacheStats()
+		return float64(hits)
+	})
+
+	_ = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "gopdf_template_cache_misses_total",
+		Help: "Templates read by path that had to be read from disk with the template cache on.",
+	}, func() float64 {
+		_, misses, _ := gopdf.TemplateCacheStats()
+		return float64(misses)
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "gopdf_template_cache_bytes",
+		Help: "Bytes of templates held in the template cache.",
+	}, func() float64 {
+		_, _, size := gopdf.TemplateCacheStats()
+		return float64(size)
+	})
+)
+
+// Endpoints that don't do any PDF work, they aren't jobs
+var non_pdf_endpoints = map[string]bool{
+	"/healthcheck": true,
+	"/livez":       true,
+	"/metrics":     true,
+	// Counts each of its jobs instead, see runBatchJob
+	"/generate-batch": true,
+}
+
+func requestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			// No route, the path itself would give us a label per typo
+			endpoint = "unmatched"
+		} else if !non_pdf_endpoints[endpoint] {
+			pdf_jobs_in_flight.Inc()
+			defer pdf_jobs_in_flight.Dec()
+		}
+
+		c.Next()
+
+		http_requests.WithLabelValues(endpoint, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		http_request_duration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}
+}
+
+func measuredFile(in_file gopdf.InputFile) gopdf.InputFile {
+	/*
+		in_file with its size going into gopdf_pdf_size_bytes the first time it's opened,
+		whichever of the per-file steps that is. Files are opened more than once on the way.
+	*/
+	open := in_file.Open
+	var once sync.Once
+	in_file.Open = func() (gopdf.File, error) {
+		f, err := open()
+		if err != nil {
+			return f, err
+		}
+		once.Do(func() {
+			size, err := f.Seek(0, io.SeekEnd)
+			if err == nil {
+				pdf_size.Observe(float64(size))
+			}
+			f.Seek(0, io.SeekStart)
+		})
+		return f, nil
+	}
+	return in_file
+}
+
+func measuredFiles(input_files []gopdf.InputFile) []gopdf.InputFile {
+	for i, in_file := range input_files {
+		input_files[i] = measuredFile(in_file)
+	}
+	return input_files
+}
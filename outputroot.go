@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	Output root: with GOPDF_OUTPUT_ROOT set, every output_file and output_dir of a request
+	is a path inside that directory and anything that would end up outside it is a 403.
+	Without it requests write wherever they say, like they always have.
+*/
+
+// The directory outputs have to stay inside, "" lets requests write anywhere
+var output_root string
+
+var errOutsideRoot = errors.New("is outside the output root")
+
+func setupOutputRoot(root string) error {
+	if root == "" {
+		output_root = ""
+		return nil
+	}
+	abs_root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	// Symlinks resolved, so the paths it's compared with can be resolved too
+	output_root, err = filepath.EvalSymlinks(abs_root)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(output_root); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", root)
+	}
+	return nil
+}
+
+func resolveOutputPath(path string) (string, error) {
+	/*
+		path as it's written to: unchanged without an output root, otherwise joined to it.
+		An absolute path has to be inside the root already. Any ".." is errOutsideRoot,
+		and so is a path that gets out through a symlink in the directories on the way.
+	*/
+	if output_root == "" || isS3URL(path) {
+		return path, nil
+	}
+	for _, element := range strings.Split(filepath.ToSlash(path), "/") {
+		if element == ".." {
+			return "", errOutsideRoot
+		}
+	}
+	resolved := filepath.Clean(path)
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(output_root, resolved)
+	}
+	if !insideRoot(resolved) {
+		return "", errOutsideRoot
+	}
+	// The deepest directory on the way that exists, with its symlinks followed
+	existing := resolved
+	for {
+		if real_path, err := filepath.EvalSymlinks(existing); err == nil {
+			if !insideRoot(real_path) {
+				return "", errOutsideRoot
+			}
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	return resolved, nil
+}
+
+func insideRoot(path string) bool {
+	relative, err := filepath.Rel(output_root, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+func outputPath(c *gin.Context, key string, path string) (string, bool) {
+	// resolveOutputPath for the key param of a request, a path outside the root is a 403
+	resolved, err := resolveOutputPath(path)
+	if err != nil {
+		sendResponse(c, Response{Status: http.StatusForbidden, Error: []string{fmt.Sprintf("Forbidden. %s %s %v", key, path, err)}})
+		return "", false
+	}
+	return resolved, true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testOutputRoot(t *testing.T, root string) {
+	// Sets the output root to root for the rest of t
+	t.Helper()
+	if err := setupOutputRoot(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { setupOutputRoot("") })
+}
+
+func TestResolveOutputPath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	for _, dir := range []string{root, filepath.Join(root, "sub"), filepath.Join(base, "root-other"), filepath.Join(base, "outside")} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	testOutputRoot(t, root)
+
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{"out.pdf", filepath.Join(root, "out.pdf")},
+		{"sub/out.pdf", filepath.Join(root, "sub", "out.pdf")},
+		{"./sub//out.pdf", filepath.Join(root, "sub", "out.pdf")},
+		{"new/dir/out.pdf", filepath.Join(root, "new", "dir", "out.pdf")},
+		{filepath.Join(root, "sub", "out.pdf"), filepath.Join(root, "sub", "out.pdf")},
+		{"s3://bucket/../out.pdf", "s3://bucket/../out.pdf"},
+	} {
+		got, err := resolveOutputPath(test.path)
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %q (%v), want %q", test.path, got, err, test.want)
+		}
+	}
+
+	for _, path := range []string{
+		"../../etc/passwd",
+		"../out.pdf",
+		"sub/../../out.pdf",
+		"sub/../out.pdf",
+		"/etc/passwd",
+		filepath.Join(base, "root-other", "out.pdf"),
+		"link/out.pdf",
+		"link/new/out.pdf",
+	} {
+		if got, err := resolveOutputPath(path); !errors.Is(err, errOutsideRoot) {
+			t.Errorf("%s: got %q (%v), want errOutsideRoot", path, got, err)
+		}
+	}
+}
+
+func TestResolveOutputPathWithoutRoot(t *testing.T) {
+	testOutputRoot(t, "")
+	if got, err := resolveOutputPath("../../etc/passwd"); err != nil || got != "../../etc/passwd" {
+		t.Errorf("got %q (%v), want the path unchanged", got, err)
+	}
+}
+
+func TestOutputRootTraversal(t *testing.T) {
+	// Two levels down, so ../../ lands in a directory of the test and not somewhere real
+	base := t.TempDir()
+	root := filepath.Join(base, "a", "root")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testOutputRoot(t, root)
+	r := testRouter()
+	in_path := writeTestPDF(t, test_form)
+	for path, body := range map[string]map[string]interface{}{
+		"/generate": {"input_files": []string{in_path}, "context_json_file": map[string]interface{}{"name": "Jane"}, "output_file": "../../escaped.pdf"},
+		"/optimize": {"input_file": in_path, "output_file": "../../escaped.pdf"},
+		"/merge":    {"input_files": []string{in_path, in_path}, "output_file": filepath.Join(base, "escaped.pdf")},
+	} {
+		recorder := serveJSON(t, r, path, body)
+		if body := checkStatus(t, path, recorder); recorder.Code != http.StatusForbidden || body["code"] != code_output_forbidden {
+			t.Errorf("%s: got %d %v, want a 403 OUTPUT_FORBIDDEN", path, recorder.Code, body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(base, "escaped.pdf")); err == nil {
+		t.Error("a request wrote outside the output root")
+	}
+}
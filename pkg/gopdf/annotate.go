@@ -0,0 +1,150 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ErrInvalidAnnotation is what Annotate returns, wrapped with what's wrong, for an annotation
+// that doesn't fit the file: a page it doesn't have or a rect off the page
+var ErrInvalidAnnotation = errors.New("invalid annotation")
+
+// AnnotationTypes are the kinds of annotation Annotate adds
+var AnnotationTypes = []string{"text", "highlight", "link"}
+
+// Annotation is one annotation to add to a page
+type Annotation struct {
+	// One of AnnotationTypes: a note (Text), a Highlight over Rect or a Link to URL
+	Type string
+	// The page number, from 1
+	Page int
+	// llx, lly, urx, ury in default user space, inside the page's MediaBox.
+	// A note only uses the lower left corner, viewers draw its icon there.
+	Rect [4]float64
+	// The text of a note, or the comment of a highlight
+	Contents string
+	// Where a link goes
+	URL string
+}
+
+// The color notes and highlights get, yellow as viewers make them
+var annotation_color = pdfcpu.Array{pdfcpu.Float(1), pdfcpu.Float(1), pdfcpu.Float(0)}
+
+// Annotate adds annotation to its page of in_file and writes the result to out_path.
+// A page the file doesn't have or a rect not inside the page's MediaBox is ErrInvalidAnnotation.
+func Annotate(in_file InputFile, out_path string, annotation Annotation) error {
+	if !containsString(AnnotationTypes, annotation.Type) {
+		return fmt.Errorf("%w: type must be one of %v, got %q", ErrInvalidAnnotation, AnnotationTypes, annotation.Type)
+	}
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	file_error := func(err error) error {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	if err = ctx.EnsurePageCount(); err != nil {
+		return file_error(err)
+	}
+	if annotation.Page < 1 || annotation.Page > ctx.PageCount {
+		return fmt.Errorf("%w: page %d is out of range, the file has %d pages", ErrInvalidAnnotation, annotation.Page, ctx.PageCount)
+	}
+	page_dict, page_ref, inherited, err := ctx.PageDict(annotation.Page, false)
+	if err != nil {
+		return file_error(err)
+	}
+	if page_dict == nil || page_ref == nil || inherited == nil || inherited.MediaBox == nil {
+		return file_error(fmt.Errorf("page %d has no MediaBox", annotation.Page))
+	}
+	rect := annotation.Rect
+	box := inherited.MediaBox
+	if rect[0] >= rect[2] || rect[1] >= rect[3] {
+		return fmt.Errorf("%w: rect %v must be [llx lly urx ury] with llx < urx and lly < ury", ErrInvalidAnnotation, rect)
+	}
+	if rect[0] < box.LL.X || rect[1] < box.LL.Y || rect[2] > box.UR.X || rect[3] > box.UR.Y {
+		return fmt.Errorf("%w: rect %v is not inside the MediaBox [%g %g %g %g] of page %d", ErrInvalidAnnotation, rect, box.LL.X, box.LL.Y, box.UR.X, box.UR.Y, annotation.Page)
+	}
+
+	annot, err := annotationDict(ctx, annotation, *page_ref)
+	if err != nil {
+		return file_error(err)
+	}
+	annot_ref, err := ctx.IndRefForNewObject(annot)
+	if err != nil {
+		return file_error(err)
+	}
+	annots, err := ctx.DereferenceArray(page_dict["Annots"])
+	if err != nil {
+		return file_error(err)
+	}
+	page_dict["Annots"] = append(annots, *annot_ref)
+	return writeContextFile(ctx, out_path)
+}
+
+func annotationDict(ctx *pdfcpu.Context, annotation Annotation, page_ref pdfcpu.IndirectRef) (pdfcpu.Dict, error) {
+	rect := annotation.Rect
+	d := pdfcpu.Dict{
+		"Type": pdfcpu.Name("Annot"),
+		"Rect": pdfcpu.Array{pdfcpu.Float(rect[0]), pdfcpu.Float(rect[1]), pdfcpu.Float(rect[2]), pdfcpu.Float(rect[3])},
+		"P":    page_ref,
+		"M":    pdfcpu.StringLiteral(pdfcpu.DateString(time.Now())),
+		// Print
+		"F": pdfcpu.Integer(4),
+	}
+	if annotation.Contents != "" {
+		d["Contents"] = textObject(annotation.Contents)
+	}
+	switch annotation.Type {
+	case "text":
+		// Viewers draw the icon at the lower left corner at a fixed size, NoZoom (8) and NoRotate (16)
+		d["Subtype"] = pdfcpu.Name("Text")
+		d["Name"] = pdfcpu.Name("Note")
+		d["C"] = annotation_color
+		d["F"] = pdfcpu.Integer(4 | 8 | 16)
+	case "highlight":
+		// QuadPoints go upper left, upper right, lower left, lower right
+		d["Subtype"] = pdfcpu.Name("Highlight")
+		d["C"] = annotation_color
+		d["QuadPoints"] = pdfcpu.Array{
+			pdfcpu.Float(rect[0]), pdfcpu.Float(rect[3]), pdfcpu.Float(rect[2]), pdfcpu.Float(rect[3]),
+			pdfcpu.Float(rect[0]), pdfcpu.Float(rect[1]), pdfcpu.Float(rect[2]), pdfcpu.Float(rect[1]),
+		}
+		ap, err := highlightAppearance(ctx, rect)
+		if err != nil {
+			return nil, err
+		}
+		d["AP"] = pdfcpu.Dict{"N": *ap}
+	case "link":
+		// No border, the page content already shows what's linked
+		d["Subtype"] = pdfcpu.Name("Link")
+		d["Border"] = pdfcpu.Array{pdfcpu.Integer(0), pdfcpu.Integer(0), pdfcpu.Integer(0)}
+		d["A"] = pdfcpu.Dict{"S": pdfcpu.Name("URI"), "URI": pdfcpu.StringLiteral(escapeLiteral(annotation.URL))}
+	}
+	return d, nil
+}
+
+func highlightAppearance(ctx *pdfcpu.Context, rect [4]float64) (*pdfcpu.IndirectRef, error) {
+	/*
+		Viewers don't all draw a highlight without an appearance stream, so it gets one:
+		the rect filled in the highlight color, multiplied so the text under it stays readable
+	*/
+	width, height := rect[2]-rect[0], rect[3]-rect[1]
+	sd, err := ctx.NewStreamDictForBuf([]byte(fmt.Sprintf("q /GopdfHighlight gs 1 1 0 rg 0 0 %.2f %.2f re f Q", width, height)))
+	if err != nil {
+		return nil, err
+	}
+	sd.Dict["Type"] = pdfcpu.Name("XObject")
+	sd.Dict["Subtype"] = pdfcpu.Name("Form")
+	sd.Dict["BBox"] = pdfcpu.Array{pdfcpu.Float(0), pdfcpu.Float(0), pdfcpu.Float(width), pdfcpu.Float(height)}
+	sd.Dict["Matrix"] = pdfcpu.Array{pdfcpu.Integer(1), pdfcpu.Integer(0), pdfcpu.Integer(0), pdfcpu.Integer(1), pdfcpu.Float(0), pdfcpu.Float(0)}
+	sd.Dict["Resources"] = pdfcpu.Dict{
+		"ExtGState": pdfcpu.Dict{"GopdfHighlight": pdfcpu.Dict{"Type": pdfcpu.Name("ExtGState"), "BM": pdfcpu.Name("Multiply")}},
+	}
+	if err = sd.Encode(); err != nil {
+		return nil, err
+	}
+	return ctx.IndRefForNewObject(*sd)
+}
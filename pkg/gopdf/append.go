@@ -0,0 +1,79 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// What Append does with the forms of the input files
+const (
+	// The fields are drawn into their pages as they are filled and the forms removed
+	AppendDropForms = "drop"
+	// Every file's fields go under a parent field named file1, file2... so none collide
+	AppendRenameForms = "rename"
+)
+
+// Append concatenates the pages of input_files, in order, into a single PDF written to out_path.
+// Unlike Merge the forms aren't combined as they are: forms is AppendDropForms or
+// AppendRenameForms. Annotations other than widgets are left on their pages either way.
+// Input files that can't be read are reported as FileErrors.
+func Append(input_files []InputFile, out_path string, forms string) error {
+	if len(input_files) < 2 {
+		return errors.New("appending needs at least two input files")
+	}
+	if forms != AppendDropForms && forms != AppendRenameForms {
+		return fmt.Errorf("forms must be %q or %q, got %q", AppendDropForms, AppendRenameForms, forms)
+	}
+	contexts, err := readContexts(input_files)
+	if err != nil {
+		return err
+	}
+	for idx, ctx := range contexts {
+		if forms == AppendDropForms {
+			err = flattenForm(ctx)
+		} else {
+			err = nestFields(ctx, fmt.Sprintf("file%d", idx+1))
+		}
+		if err != nil {
+			return &FileError{Idx: idx, Name: input_files[idx].Name, Err: err}
+		}
+	}
+	return mergeContexts(input_files, contexts, out_path)
+}
+
+func nestFields(ctx *pdfcpu.Context, parent_name string) error {
+	/*
+		Puts all the top level fields of ctx's AcroForm under a new field parent_name,
+		so "address.zip" becomes "file2.address.zip". The new field has no attributes
+		of its own, nothing the fields inherit changes.
+	*/
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil || adict == nil {
+		return err
+	}
+	fields, err := ctx.DereferenceArray(adict["Fields"])
+	if err != nil || len(fields) == 0 {
+		return err
+	}
+	parent_ref, err := ctx.IndRefForNewObject(pdfcpu.Dict{"T": pdfcpu.StringLiteral(parent_name), "Kids": fields})
+	if err != nil {
+		return err
+	}
+	for _, o := range fields {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			d["Parent"] = *parent_ref
+		}
+	}
+	adict["Fields"] = pdfcpu.Array{*parent_ref}
+	return nil
+}
@@ -0,0 +1,232 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ErrAttachmentExists is what AttachFile returns, wrapped with the name, when the name is taken and it may not overwrite
+var ErrAttachmentExists = errors.New("there already is an attachment by that name")
+
+// AttachOptions says how AttachFile embeds a file
+type AttachOptions struct {
+	// The file name to embed it under, the base name of the file when it's empty
+	Name string
+	// The MIME type, guessed from the extension of Name when it's empty
+	MimeType    string
+	Description string
+	// Replace the attachment that already has Name instead of failing with ErrAttachmentExists
+	Overwrite bool
+}
+
+// AttachFile embeds attachment in in_file, adding it to the EmbeddedFiles name tree
+// (created along with the Names dictionary when the document has none), and writes
+// the result to out_path. Returns the attachment as ListAttachments would.
+func AttachFile(in_file InputFile, out_path string, attachment InputFile, options AttachOptions) (Attachment, error) {
+	name := options.Name
+	if name == "" {
+		name = filepath.Base(attachment.Name)
+	}
+	mime_type := options.MimeType
+	if mime_type == "" {
+		mime_type = mime.TypeByExtension(filepath.Ext(name))
+	}
+	content, err := readAttachmentFile(attachment)
+	if err != nil {
+		return Attachment{}, &FileError{Idx: 0, Name: attachment.Name, Err: err}
+	}
+
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return Attachment{}, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	file_error := func(err error) error {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	files, err := contextEmbeddedFiles(ctx)
+	if err != nil {
+		return Attachment{}, file_error(err)
+	}
+	// A new attachment is filed under its name, a replaced one stays where it was
+	key := name
+	if existing, found := findAttachment(files, name); found {
+		if !options.Overwrite {
+			return Attachment{}, fmt.Errorf("%w: %s", ErrAttachmentExists, name)
+		}
+		key = existing.key
+	}
+
+	mod_time := time.Now()
+	spec, err := embedFile(ctx, name, mime_type, options.Description, content, mod_time)
+	if err != nil {
+		return Attachment{}, file_error(err)
+	}
+	tree, err := embeddedFilesTree(ctx, true)
+	if err != nil {
+		return Attachment{}, file_error(err)
+	}
+	if err = putName(ctx, tree, key, spec, 0); err != nil {
+		return Attachment{}, file_error(err)
+	}
+	if err = writeContextFile(ctx, out_path); err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{
+		Name:        name,
+		Size:        int64(len(content)),
+		MimeType:    mime_type,
+		Description: options.Description,
+		ModTime:     mod_time.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+func readAttachmentFile(attachment InputFile) ([]byte, error) {
+	f, err := attachment.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func embedFile(ctx *pdfcpu.Context, name string, mime_type string, description string, content []byte, mod_time time.Time) (pdfcpu.IndirectRef, error) {
+	/*
+		Adds content as an embedded file stream, flate compressed, and the file spec
+		pointing at it. Returns the reference of the file spec, what the name tree holds.
+	*/
+	params := pdfcpu.Dict{
+		"Size":    pdfcpu.Integer(len(content)),
+		"ModDate": pdfcpu.StringLiteral(pdfcpu.DateString(mod_time)),
+	}
+	sd := pdfcpu.StreamDict{
+		Dict:           pdfcpu.Dict{"Type": pdfcpu.Name("EmbeddedFile"), "Params": params, "Filter": pdfcpu.Name(filter.Flate)},
+		Content:        content,
+		FilterPipeline: []pdfcpu.PDFFilter{{Name: filter.Flate}},
+	}
+	if mime_type != "" {
+		// Subtype is a name, the / of a MIME type has to be written as #2F
+		sd.Dict["Subtype"] = pdfcpu.Name(pdfName(mime_type)[1:])
+	}
+	if err := sd.Encode(); err != nil {
+		return pdfcpu.IndirectRef{}, err
+	}
+	stream_ref, err := ctx.IndRefForNewObject(sd)
+	if err != nil {
+		return pdfcpu.IndirectRef{}, err
+	}
+
+	spec := pdfcpu.Dict{
+		"Type": pdfcpu.Name("Filespec"),
+		"F":    textObject(name),
+		"UF":   textObject(name),
+		"EF":   pdfcpu.Dict{"F": *stream_ref, "UF": *stream_ref},
+	}
+	if description != "" {
+		spec["Desc"] = textObject(description)
+	}
+	spec_ref, err := ctx.IndRefForNewObject(spec)
+	if err != nil {
+		return pdfcpu.IndirectRef{}, err
+	}
+	return *spec_ref, nil
+}
+
+func putName(ctx *pdfcpu.Context, node pdfcpu.Dict, key string, value pdfcpu.Object, depth int) error {
+	/*
+		Sets key to value in the name tree below node: replaces the value when key is
+		there already, otherwise inserts it where its order puts it. Intermediate nodes
+		pass it to the kid whose range it falls in (the nearest one when it's in none)
+		and every node on the way widens its Limits to take it in.
+	*/
+	if depth > max_name_tree_depth {
+		return fmt.Errorf("the name tree is more than %d levels deep", max_name_tree_depth)
+	}
+	kids, err := ctx.DereferenceArray(node["Kids"])
+	if err != nil {
+		return err
+	}
+	if len(kids) > 0 {
+		// The last kid starting at or before key, the first one when they all start after it
+		var kid pdfcpu.Dict
+		for _, o := range kids {
+			d, err := ctx.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+			if d == nil {
+				continue
+			}
+			low, _ := nameLimits(ctx, d)
+			if kid == nil || low <= key {
+				kid = d
+			}
+		}
+		if kid == nil {
+			return errors.New("the name tree has no usable kids")
+		}
+		if err = putName(ctx, kid, key, value, depth+1); err != nil {
+			return err
+		}
+		widenLimits(ctx, node, key)
+		return nil
+	}
+
+	pairs, err := ctx.DereferenceArray(node["Names"])
+	if err != nil {
+		return err
+	}
+	at := len(pairs)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		existing, err := ctx.DereferenceText(pairs[i])
+		if err != nil {
+			return err
+		}
+		if existing == key {
+			pairs[i+1] = value
+			return nil
+		}
+		if existing > key {
+			at = i
+			break
+		}
+	}
+	inserted := make(pdfcpu.Array, 0, len(pairs)+2)
+	inserted = append(inserted, pairs[:at]...)
+	inserted = append(inserted, textObject(key), value)
+	node["Names"] = append(inserted, pairs[at:]...)
+	widenLimits(ctx, node, key)
+	return nil
+}
+
+func nameLimits(ctx *pdfcpu.Context, node pdfcpu.Dict) (string, string) {
+	// The first and last key below node, "" when it has no Limits
+	limits, err := ctx.DereferenceArray(node["Limits"])
+	if err != nil || len(limits) != 2 {
+		return "", ""
+	}
+	low, _ := ctx.DereferenceText(limits[0])
+	high, _ := ctx.DereferenceText(limits[1])
+	return low, high
+}
+
+func widenLimits(ctx *pdfcpu.Context, node pdfcpu.Dict, key string) {
+	// The root has no Limits and mustn't get any
+	if node["Limits"] == nil {
+		return
+	}
+	low, high := nameLimits(ctx, node)
+	if low == "" || key < low {
+		low = key
+	}
+	if high == "" || key > high {
+		high = key
+	}
+	node["Limits"] = pdfcpu.Array{textObject(low), textObject(high)}
+}
@@ -0,0 +1,330 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ErrAttachmentNotFound is what ReadAttachment returns, wrapped with the name, for a name no attachment has
+var ErrAttachmentNotFound = errors.New("no attachment by that name")
+
+// Attachment is a file embedded in a PDF, one entry of the EmbeddedFiles name tree
+type Attachment struct {
+	// The file name the attachment was embedded with (UF, or F when there's no UF)
+	Name string `json:"name"`
+	// The size in bytes, as the file spec gives it or else as the decoded stream is
+	Size        int64  `json:"size"`
+	MimeType    string `json:"mime_type,omitempty"`
+	Description string `json:"description,omitempty"`
+	// ModDate of the embedded file, RFC 3339 when it's a proper PDF date
+	ModTime string `json:"mod_time,omitempty"`
+	// Where ExtractAttachments wrote it
+	Path string `json:"path,omitempty"`
+}
+
+// An embedded file along with the stream it's in
+type embeddedFile struct {
+	Attachment
+	key    string
+	stream *pdfcpu.StreamDict
+}
+
+// ListAttachments returns the attachments of in_file in name tree order, without their
+// content. A file without an EmbeddedFiles name tree has none, that's an empty list.
+func ListAttachments(in_file InputFile) ([]Attachment, error) {
+	files, err := embeddedFiles(in_file)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]Attachment, 0, len(files))
+	for _, file := range files {
+		attachments = append(attachments, file.Attachment)
+	}
+	return attachments, nil
+}
+
+// ExtractAttachments writes the attachments of in_file to out_dir under their file names
+// and returns them with the paths they were written to. With names only the attachments
+// by those names are written, one that isn't there is ErrAttachmentNotFound.
+// Names that would escape out_dir are cut to their last part, names used twice get a number.
+func ExtractAttachments(in_file InputFile, out_dir string, names []string) ([]Attachment, error) {
+	files, err := embeddedFiles(in_file)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		selected := make([]embeddedFile, 0, len(names))
+		for _, name := range names {
+			file, found := findAttachment(files, name)
+			if !found {
+				return nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, name)
+			}
+			selected = append(selected, file)
+		}
+		files = selected
+	}
+
+	written := make([]Attachment, 0, len(files))
+	used := make(map[string]bool)
+	for i, file := range files {
+		content, err := attachmentContent(file)
+		if err != nil {
+			return nil, &FileError{Idx: 0, Name: in_file.Name, Err: fmt.Errorf("attachment %q: %v", file.Name, err)}
+		}
+		file_name := attachmentFileName(file, i, used)
+		file.Path = filepath.Join(out_dir, file_name)
+		err = writeFile(file.Path, func(w io.Writer) error {
+			_, err := w.Write(content)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		written = append(written, file.Attachment)
+	}
+	return written, nil
+}
+
+// ReadAttachment writes the content of the attachment of in_file called name to w
+// and returns what it is. A name no attachment has is ErrAttachmentNotFound.
+func ReadAttachment(in_file InputFile, name string, w io.Writer) (Attachment, error) {
+	files, err := embeddedFiles(in_file)
+	if err != nil {
+		return Attachment{}, err
+	}
+	file, found := findAttachment(files, name)
+	if !found {
+		return Attachment{}, fmt.Errorf("%w: %s", ErrAttachmentNotFound, name)
+	}
+	content, err := attachmentContent(file)
+	if err != nil {
+		return Attachment{}, &FileError{Idx: 0, Name: in_file.Name, Err: fmt.Errorf("attachment %q: %v", file.Name, err)}
+	}
+	_, err = w.Write(content)
+	return file.Attachment, err
+}
+
+func embeddedFiles(in_file InputFile) ([]embeddedFile, error) {
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return nil, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	files, err := contextEmbeddedFiles(ctx)
+	if err != nil {
+		return nil, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	return files, nil
+}
+
+func contextEmbeddedFiles(ctx *pdfcpu.Context) ([]embeddedFile, error) {
+	/*
+		Every entry of the EmbeddedFiles name tree (under Names in the catalog), nil when
+		there's no such tree. File specs without an embedded stream (EF) only point at
+		a file somewhere else, they aren't attachments and are left out.
+		pdfcpu only loads name trees when it validates, so the tree is walked here.
+	*/
+	tree, err := embeddedFilesTree(ctx, false)
+	if err != nil || tree == nil {
+		return nil, err
+	}
+	files := make([]embeddedFile, 0)
+	err = walkNameTree(ctx, tree, 0, func(key string, o pdfcpu.Object) error {
+		file, err := embeddedFileSpec(ctx, key, o)
+		if err != nil {
+			return fmt.Errorf("attachment %q: %v", key, err)
+		}
+		if file != nil {
+			files = append(files, *file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func embeddedFilesTree(ctx *pdfcpu.Context, create bool) (pdfcpu.Dict, error) {
+	// The root of the EmbeddedFiles name tree, nil when there's none and it's not to be created
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	names, err := ctx.DereferenceDict(cat["Names"])
+	if err != nil {
+		return nil, err
+	}
+	if names == nil {
+		if !create {
+			return nil, nil
+		}
+		names = pdfcpu.Dict{}
+		ref, err := ctx.IndRefForNewObject(names)
+		if err != nil {
+			return nil, err
+		}
+		cat["Names"] = *ref
+	}
+	tree, err := ctx.DereferenceDict(names["EmbeddedFiles"])
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil && create {
+		tree = pdfcpu.Dict{"Names": pdfcpu.Array{}}
+		ref, err := ctx.IndRefForNewObject(tree)
+		if err != nil {
+			return nil, err
+		}
+		names["EmbeddedFiles"] = *ref
+	}
+	return tree, nil
+}
+
+// How deep walkNameTree goes before taking the tree for a loop
+const max_name_tree_depth = 32
+
+func walkNameTree(ctx *pdfcpu.Context, node pdfcpu.Dict, depth int, visit func(key string, o pdfcpu.Object) error) error {
+	// Calls visit for every key and value of the name tree below node, in key order
+	if depth > max_name_tree_depth {
+		return fmt.Errorf("the name tree is more than %d levels deep", max_name_tree_depth)
+	}
+	kids, err := ctx.DereferenceArray(node["Kids"])
+	if err != nil {
+		return err
+	}
+	for _, o := range kids {
+		kid, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if kid != nil {
+			if err = walkNameTree(ctx, kid, depth+1, visit); err != nil {
+				return err
+			}
+		}
+	}
+	pairs, err := ctx.DereferenceArray(node["Names"])
+	if err != nil {
+		return err
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key, err := ctx.DereferenceText(pairs[i])
+		if err != nil {
+			return err
+		}
+		if err = visit(key, pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func embeddedFileSpec(ctx *pdfcpu.Context, key string, o pdfcpu.Object) (*embeddedFile, error) {
+	// The attachment the file spec o stands for, nil when it has no embedded stream
+	spec, err := ctx.DereferenceDict(o)
+	if err != nil || spec == nil {
+		return nil, err
+	}
+	ef, err := ctx.DereferenceDict(spec["EF"])
+	if err != nil || ef == nil {
+		return nil, err
+	}
+	// UF is the unicode file name, F the older byte string one
+	ef_entry := ef["UF"]
+	if ef_entry == nil {
+		ef_entry = ef["F"]
+	}
+	sd, _, err := ctx.DereferenceStreamDict(ef_entry)
+	if err != nil || sd == nil {
+		return nil, err
+	}
+
+	file := embeddedFile{key: key, stream: sd}
+	for _, name_key := range []string{"UF", "F"} {
+		if name, err := ctx.DereferenceText(spec[name_key]); err == nil && name != "" {
+			file.Name = name
+			break
+		}
+	}
+	if file.Name == "" {
+		file.Name = key
+	}
+	if desc, err := ctx.DereferenceText(spec["Desc"]); err == nil {
+		file.Description = desc
+	}
+	// Subtype is optional (pdfcpu doesn't write one), without it the extension says
+	if subtype := sd.Dict.NameEntry("Subtype"); subtype != nil {
+		file.MimeType = pdfcpu.Name(*subtype).Value()
+	} else {
+		file.MimeType = mime.TypeByExtension(filepath.Ext(file.Name))
+	}
+	file.Size = -1
+	if params, err := ctx.DereferenceDict(sd.Dict["Params"]); err == nil && params != nil {
+		if size, err := ctx.DereferenceInteger(params["Size"]); err == nil && size != nil {
+			file.Size = int64(size.Value())
+		}
+		if mod_date, err := ctx.DereferenceText(params["ModDate"]); err == nil && mod_date != "" {
+			file.ModTime = pdfDate(mod_date)
+		}
+	}
+	if file.Size < 0 {
+		// No Size given, the stream has to be decoded to know it
+		content, err := attachmentContent(file)
+		if err != nil {
+			return nil, err
+		}
+		file.Size = int64(len(content))
+	}
+	return &file, nil
+}
+
+func attachmentContent(file embeddedFile) ([]byte, error) {
+	sd := *file.stream
+	if err := sd.Decode(); err != nil {
+		return nil, err
+	}
+	return sd.Content, nil
+}
+
+func findAttachment(files []embeddedFile, name string) (embeddedFile, bool) {
+	// By file name first, then by the name tree key it's filed under
+	for _, file := range files {
+		if file.Name == name {
+			return file, true
+		}
+	}
+	for _, file := range files {
+		if file.key == name {
+			return file, true
+		}
+	}
+	return embeddedFile{}, false
+}
+
+func attachmentFileName(file embeddedFile, i int, used map[string]bool) string {
+	/*
+		The name to write attachment i under: the last part of its file name, so a name
+		with directories (or ..) in it stays inside out_dir. A name already used gets
+		_2, _3... before the extension.
+	*/
+	name := file.Name
+	if j := strings.LastIndexAny(name, `/\`); j >= 0 {
+		name = name[j+1:]
+	}
+	if name == "" || name == "." || name == ".." {
+		name = fmt.Sprintf("attachment_%d", i+1)
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s_%d%s", base, n, ext)
+	}
+	used[name] = true
+	return name
+}
@@ -0,0 +1,237 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ErrInvalidBlankPages is what InsertBlankPages returns, wrapped with what's wrong, for
+// positions, a count or a size that don't fit the file
+var ErrInvalidBlankPages = errors.New("invalid blank pages")
+
+// MaxBlankPages is the most blank pages InsertBlankPages adds in one go
+const MaxBlankPages = 1000
+
+// BlankPages says where InsertBlankPages adds blank pages and how big they are
+type BlankPages struct {
+	// Where the pages go: after page n, 0 is before the first page
+	Positions []int
+	// Blank pages at every position, 1 when it's 0
+	Count int
+	// A paper size like SheetSize takes (A4, Letter...) for the pages, portrait.
+	// Empty is Width by Height when they're set, or else the size of the page before
+	// the position (the first page for position 0), rotation and crop box included.
+	Size   string
+	Width  float64
+	Height float64
+}
+
+// InsertBlankPages adds blank pages to in_file at blank.Positions, writes the result to
+// out_path and returns its page count. Positions past the last page, positions given twice
+// and sizes that aren't any are ErrInvalidBlankPages. The page label ranges move along with
+// the pages they start at, but for the first one, which still starts at the first page.
+func InsertBlankPages(in_file InputFile, out_path string, blank BlankPages) (int, error) {
+	count := blank.Count
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 || count*len(blank.Positions) > MaxBlankPages {
+		return 0, fmt.Errorf("%w: count must be between 1 and %d blank pages in all, got %d at %d positions", ErrInvalidBlankPages, MaxBlankPages, blank.Count, len(blank.Positions))
+	}
+	if len(blank.Positions) == 0 {
+		return 0, fmt.Errorf("%w: positions must name at least one position", ErrInvalidBlankPages)
+	}
+	var media_box pdfcpu.Array
+	switch {
+	case blank.Size != "":
+		name, ok := SheetSize(blank.Size)
+		if !ok {
+			return 0, fmt.Errorf("%w: unknown size %q", ErrInvalidBlankPages, blank.Size)
+		}
+		dim := pdfcpu.PaperSize[name]
+		media_box = pdfcpu.Array{pdfcpu.Integer(0), pdfcpu.Integer(0), pdfcpu.Float(dim.Width), pdfcpu.Float(dim.Height)}
+	case blank.Width != 0 || blank.Height != 0:
+		if blank.Width <= 0 || blank.Height <= 0 {
+			return 0, fmt.Errorf("%w: width and height must both be above 0, got %g by %g", ErrInvalidBlankPages, blank.Width, blank.Height)
+		}
+		media_box = pdfcpu.Array{pdfcpu.Integer(0), pdfcpu.Integer(0), pdfcpu.Float(blank.Width), pdfcpu.Float(blank.Height)}
+	}
+
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return 0, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	file_error := func(err error) error {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	if err = ctx.EnsurePageCount(); err != nil {
+		return 0, file_error(err)
+	}
+	seen := make(map[int]bool)
+	for _, position := range blank.Positions {
+		if position < 0 || position > ctx.PageCount {
+			return 0, fmt.Errorf("%w: position %d is out of range, the file has %d pages", ErrInvalidBlankPages, position, ctx.PageCount)
+		}
+		if seen[position] {
+			return 0, fmt.Errorf("%w: position %d is in positions more than once", ErrInvalidBlankPages, position)
+		}
+		seen[position] = true
+	}
+	if ctx.PageCount == 0 && media_box == nil {
+		return 0, fmt.Errorf("%w: the file has no pages to take the size of, give a size", ErrInvalidBlankPages)
+	}
+
+	// The pages next to the positions are all looked up before any are added, so their numbers hold
+	type neighbour struct {
+		page_nr   int
+		d         pdfcpu.Dict
+		page_ref  pdfcpu.IndirectRef
+		inherited *pdfcpu.InheritedPageAttrs
+	}
+	neighbours := make([]neighbour, len(blank.Positions))
+	for i, position := range blank.Positions {
+		page_nr := position
+		if position == 0 {
+			page_nr = 1
+		}
+		d, page_ref, inherited, err := ctx.PageDict(page_nr, false)
+		if err != nil {
+			return 0, file_error(err)
+		}
+		if d == nil || page_ref == nil || inherited == nil {
+			return 0, file_error(fmt.Errorf("page %d is missing from the page tree", page_nr))
+		}
+		neighbours[i] = neighbour{page_nr: page_nr, d: d, page_ref: *page_ref, inherited: inherited}
+	}
+	for i, position := range blank.Positions {
+		page_nr, d, inherited := neighbours[i].page_nr, neighbours[i].d, neighbours[i].inherited
+		parent_ref, ok := d["Parent"].(pdfcpu.IndirectRef)
+		if !ok {
+			return 0, file_error(fmt.Errorf("page %d has no parent in the page tree", page_nr))
+		}
+		blank_page := pdfcpu.Dict{
+			"Type":      pdfcpu.Name("Page"),
+			"Parent":    parent_ref,
+			"Resources": pdfcpu.Dict{},
+			"MediaBox":  media_box,
+			"Rotate":    pdfcpu.Integer(0),
+		}
+		if media_box == nil {
+			if inherited.MediaBox == nil {
+				return 0, file_error(fmt.Errorf("page %d has no MediaBox", page_nr))
+			}
+			blank_page["MediaBox"] = inherited.MediaBox.Array()
+			if inherited.CropBox != nil {
+				blank_page["CropBox"] = inherited.CropBox.Array()
+			}
+			blank_page["Rotate"] = pdfcpu.Integer(inherited.Rotate)
+		}
+		blank_refs := make(pdfcpu.Array, count)
+		for i := range blank_refs {
+			ref, err := ctx.IndRefForNewObject(blank_page.Clone())
+			if err != nil {
+				return 0, err
+			}
+			blank_refs[i] = *ref
+		}
+		if err = insertKids(ctx, parent_ref, neighbours[i].page_ref, blank_refs, position > 0); err != nil {
+			return 0, file_error(err)
+		}
+	}
+	ctx.PageCount += count * len(blank.Positions)
+	if err = shiftPageLabels(ctx, blank.Positions, count); err != nil {
+		return 0, file_error(err)
+	}
+	if err = writeContextFile(ctx, out_path); err != nil {
+		return 0, err
+	}
+	return ctx.PageCount, nil
+}
+
+func insertKids(ctx *pdfcpu.Context, parent_ref pdfcpu.IndirectRef, page_ref pdfcpu.IndirectRef, refs pdfcpu.Array, after bool) error {
+	// Puts refs in the Kids of parent_ref next to page_ref and counts them in parent_ref and the nodes above it
+	parent, err := ctx.DereferenceDict(parent_ref)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return errors.New("a page's parent is missing from the page tree")
+	}
+	kids, err := ctx.DereferenceArray(parent["Kids"])
+	if err != nil {
+		return err
+	}
+	at := -1
+	for i, o := range kids {
+		if ref, ok := o.(pdfcpu.IndirectRef); ok && ref.ObjectNumber == page_ref.ObjectNumber {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return errors.New("a page isn't in the Kids of its parent")
+	}
+	if after {
+		at++
+	}
+	new_kids := make(pdfcpu.Array, 0, len(kids)+len(refs))
+	new_kids = append(new_kids, kids[:at]...)
+	new_kids = append(new_kids, refs...)
+	parent["Kids"] = append(new_kids, kids[at:]...)
+
+	// Every node from the parent up to the root counts the pages under it
+	for node, depth := parent, 0; node != nil && depth < 64; depth++ {
+		if count := node.IntEntry("Count"); count != nil {
+			node["Count"] = pdfcpu.Integer(*count + len(refs))
+		}
+		if node["Parent"] == nil {
+			break
+		}
+		node, err = ctx.DereferenceDict(node["Parent"])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func shiftPageLabels(ctx *pdfcpu.Context, positions []int, count int) error {
+	/*
+		Moves the page label ranges of the catalog past the count blank pages at each of positions.
+		A range starting at page index i moves by the pages added before it, at positions up to i,
+		except the range at 0, which keeps labeling the first page whatever it is now.
+		Only a flat Nums array is moved, a number tree with Kids is rare for page labels.
+	*/
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	labels, err := ctx.DereferenceDict(cat["PageLabels"])
+	if err != nil || labels == nil {
+		return err
+	}
+	nums, err := ctx.DereferenceArray(labels["Nums"])
+	if err != nil || nums == nil {
+		return err
+	}
+	sorted := append([]int{}, positions...)
+	sort.Ints(sorted)
+	for i := 0; i+1 < len(nums); i += 2 {
+		index, ok := nums[i].(pdfcpu.Integer)
+		if !ok || index == 0 {
+			continue
+		}
+		shift := 0
+		for _, position := range sorted {
+			if position <= index.Value() {
+				shift += count
+			}
+		}
+		nums[i] = pdfcpu.Integer(index.Value() + shift)
+	}
+	labels["Nums"] = nums
+	return nil
+}
@@ -0,0 +1,71 @@
+package gopdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// Binding edges Booklet takes
+const (
+	// Pages side by side on a landscape sheet, folded down the middle like a book
+	BindingLeft = "left"
+	// Pages one above the other on a portrait sheet, folded across like a calendar
+	BindingTop = "top"
+)
+
+// BookletResult is what Booklet did to get the pages onto whole sheets
+type BookletResult struct {
+	// Blank pages added at the end, every sheet holds 4 pages (2 per side)
+	BlankPages int
+	// Pages of the written file, 2 per sheet
+	PageCount int
+}
+
+// SheetSize returns the pdfcpu paper size name matching size case insensitively
+// (A4, A3, Letter, Legal...), false when there is none.
+func SheetSize(size string) (string, bool) {
+	size = strings.TrimSpace(size)
+	for name := range pdfcpu.PaperSize {
+		if strings.EqualFold(name, size) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Booklet reimposes in_file for saddle stitching: printed double sided, folded and
+// stapled in the middle the sheets read in page order. sheet_size is the paper the
+// booklet is printed on, binding is BindingLeft or BindingTop. Page counts that
+// don't fill the last sheet are padded with blank pages.
+func Booklet(in_file InputFile, out_path string, sheet_size string, binding string) (BookletResult, error) {
+	size, ok := SheetSize(sheet_size)
+	if !ok {
+		return BookletResult{}, fmt.Errorf("unknown sheet size %q", sheet_size)
+	}
+	// The orientation of the sheet decides where pdfcpu puts the fold
+	switch binding {
+	case BindingLeft:
+		size += "L"
+	case BindingTop:
+		size += "P"
+	default:
+		return BookletResult{}, fmt.Errorf("binding must be %s or %s, got %q", BindingLeft, BindingTop, binding)
+	}
+	nup, err := api.PDFBookletConfig(2, "formsize:"+size)
+	if err != nil {
+		return BookletResult{}, err
+	}
+
+	var result BookletResult
+	result.PageCount, err = pagesTransform(in_file, out_path, func(rs io.ReadSeeker, w io.Writer, page_count int) error {
+		if page_count%4 != 0 {
+			result.BlankPages = 4 - page_count%4
+		}
+		return api.Booklet(rs, w, nil, nil, nup, in_file.configuration())
+	})
+	return result, err
+}
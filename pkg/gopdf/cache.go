@@ -0,0 +1,158 @@
+package gopdf
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	Template cache: filling the same template over and over reads and validates the same
+	bytes every time. With a cache size set, scraping and filling keep the bytes of files
+	that passed validation in memory, keyed by path, modification time and size, and skip
+	both the next time. A file that changed on disk has another key so it's read again.
+	Only PathFiles without a password or validation options are cached, least recently
+	used go first.
+*/
+
+type cacheKey struct {
+	path     string
+	mod_time time.Time
+	size     int64
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	content []byte
+}
+
+var template_cache = struct {
+	sync.Mutex
+	max_bytes int64
+	bytes     int64
+	// Most recently used first, by_path points into it
+	order   *list.List
+	by_path map[string]*list.Element
+}{order: list.New(), by_path: make(map[string]*list.Element)}
+
+var template_cache_hits, template_cache_misses uint64
+
+// SetTemplateCacheSize sets how many bytes of templates are kept in memory, 0 turns the cache off
+func SetTemplateCacheSize(max_bytes int64) {
+	template_cache.Lock()
+	defer template_cache.Unlock()
+	template_cache.max_bytes = max_bytes
+	evictTemplates()
+}
+
+// TemplateCacheStats is how often a file was found in the template cache and how often not,
+// and how many bytes it holds now
+func TemplateCacheStats() (hits uint64, misses uint64, size int64) {
+	template_cache.Lock()
+	size = template_cache.bytes
+	template_cache.Unlock()
+	return atomic.LoadUint64(&template_cache_hits), atomic.LoadUint64(&template_cache_misses), size
+}
+
+func evictTemplates() {
+	// Call with template_cache locked
+	for template_cache.bytes > template_cache.max_bytes && template_cache.order.Len() > 0 {
+		removeTemplate(template_cache.order.Back())
+	}
+}
+
+func removeTemplate(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	template_cache.order.Remove(element)
+	delete(template_cache.by_path, entry.key.path)
+	template_cache.bytes -= int64(len(entry.content))
+}
+
+func cachedTemplate(key cacheKey) []byte {
+	template_cache.Lock()
+	defer template_cache.Unlock()
+	element, found := template_cache.by_path[key.path]
+	if !found {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if entry.key != key {
+		// Changed on disk since
+		removeTemplate(element)
+		return nil
+	}
+	template_cache.order.MoveToFront(element)
+	return entry.content
+}
+
+func cacheTemplate(key cacheKey, content []byte) {
+	template_cache.Lock()
+	defer template_cache.Unlock()
+	if int64(len(content)) > template_cache.max_bytes {
+		return
+	}
+	if element, found := template_cache.by_path[key.path]; found {
+		removeTemplate(element)
+	}
+	template_cache.by_path[key.path] = template_cache.order.PushFront(&cacheEntry{key: key, content: content})
+	template_cache.bytes += int64(len(content))
+	evictTemplates()
+}
+
+// bytesFile is a File over cached content, closing it does nothing
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error {
+	return nil
+}
+
+func openValidated(in_file InputFile) (File, error) {
+	/*
+		Opens in_file and validates it with its password and options (see validateOpened),
+		the returned File is at the start and the caller closes it.
+		PathFiles go through the template cache when it's on, the file is still opened
+		to stat it (and for whatever wraps Open) but not read when it's cached.
+	*/
+	f, err := in_file.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	template_cache.Lock()
+	cache_on := template_cache.max_bytes > 0
+	template_cache.Unlock()
+	var key cacheKey
+	if cache_on && in_file.path != "" && in_file.Password == "" && in_file.Validation == (ValidationOptions{}) {
+		if os_file, ok := f.(*os.File); ok {
+			if info, err := os_file.Stat(); err == nil && !info.IsDir() {
+				key = cacheKey{path: in_file.path, mod_time: info.ModTime(), size: info.Size()}
+			}
+		}
+	}
+	if key.path == "" {
+		return validateOpened(in_file, f)
+	}
+
+	defer f.Close()
+	if content := cachedTemplate(key); content != nil {
+		atomic.AddUint64(&template_cache_hits, 1)
+		return bytesFile{bytes.NewReader(content)}, nil
+	}
+	atomic.AddUint64(&template_cache_misses, 1)
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	err = validateFile(bytes.NewReader(content), in_file)
+	if err != nil {
+		return nil, passwordError(in_file, err)
+	}
+	cacheTemplate(key, content)
+	return bytesFile{bytes.NewReader(content)}, nil
+}
@@ -0,0 +1,62 @@
+package gopdf
+
+import "sort"
+
+// FieldDiff is how the form fields changed from one file to another, by fully qualified name
+type FieldDiff struct {
+	Added   []string      `json:"added"`
+	Removed []string      `json:"removed"`
+	Changed []FieldChange `json:"changed"`
+}
+
+// FieldChange is a field that is in both files with a different type
+type FieldChange struct {
+	Name    string `json:"name"`
+	OldType string `json:"old_type"`
+	NewType string `json:"new_type"`
+}
+
+// Compare reports the fields added to new_file, removed from old_file
+// and the ones whose type changed. A file that can't be read is a FileError,
+// old_file is idx 0 and new_file idx 1.
+func Compare(old_file InputFile, new_file InputFile) (FieldDiff, error) {
+	diff := FieldDiff{Added: make([]string, 0), Removed: make([]string, 0), Changed: make([]FieldChange, 0)}
+	old_fields, file_error := scrapeFile(0, old_file)
+	if file_error != nil {
+		return diff, file_error
+	}
+	new_fields, file_error := scrapeFile(1, new_file)
+	if file_error != nil {
+		return diff, file_error
+	}
+
+	old_types := fieldTypes(old_fields)
+	new_types := fieldTypes(new_fields)
+	for name, new_type := range new_types {
+		old_type, found := old_types[name]
+		switch {
+		case !found:
+			diff.Added = append(diff.Added, name)
+		case old_type != new_type:
+			diff.Changed = append(diff.Changed, FieldChange{Name: name, OldType: old_type, NewType: new_type})
+		}
+	}
+	for name := range old_types {
+		if _, found := new_types[name]; !found {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Name < diff.Changed[j].Name })
+	return diff, nil
+}
+
+func fieldTypes(fields []Field) map[string]string {
+	types := make(map[string]string, len(fields))
+	for _, field := range fields {
+		types[field.Name] = field.Type
+	}
+	return types
+}
@@ -0,0 +1,304 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+var (
+	// ErrUnsupportedVersion is what Convert returns, wrapped with the version, for a version not in ConvertVersions
+	ErrUnsupportedVersion = errors.New("unsupported PDF version")
+	// ErrConvertEncrypted is what Convert returns for an encrypted file, it has to be decrypted first
+	ErrConvertEncrypted = errors.New("encrypted files can't be converted, decrypt them first")
+)
+
+// ConvertVersions are the versions Convert writes. pdfcpu reads nothing older than 1.0 or
+// newer than 1.7, and below 1.3 too much of what it writes (like embedded files) didn't exist.
+var ConvertVersions = []string{"1.3", "1.4", "1.5", "1.6", "1.7"}
+
+// ConvertResult is what Convert changed to make a file fit its version
+type ConvertResult struct {
+	// The version the file was, the catalog's Version when it's newer than the header
+	FromVersion string `json:"from_version"`
+	Version     string `json:"version"`
+	// The features newer than Version that were taken out
+	Removed []string `json:"removed"`
+	// The features newer than Version that are still there, there's no converting them
+	Unconverted []string `json:"unconverted"`
+}
+
+// A feature later PDF versions added, see version_features
+type versionFeature struct {
+	name  string
+	since pdfcpu.Version
+	// Whether d (any dict of the file) uses it
+	has func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool
+	// Takes it out of d, nil for the features that can't be taken out without losing content
+	remove func(ctx *pdfcpu.Context, d pdfcpu.Dict)
+}
+
+// The keys of an ExtGState that are about transparency
+var transparency_keys = []string{"SMask", "CA", "ca", "BM", "AIS", "TK"}
+
+var version_features = []versionFeature{
+	{
+		// Soft masks, constant alpha, blend modes and transparency groups. Without them
+		// everything is drawn opaque.
+		name:  "transparency",
+		since: pdfcpu.V14,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			if d["SMask"] != nil && isName(d, "Subtype", "Image") || d["SMaskInData"] != nil {
+				return true
+			}
+			if group, err := ctx.DereferenceDict(d["Group"]); err == nil && group != nil && isName(group, "S", "Transparency") {
+				return true
+			}
+			// Annotations have a CA of their own
+			if alpha, err := ctx.DereferenceNumber(d["CA"]); err == nil && d["CA"] != nil && d["Rect"] != nil && alpha != 1 {
+				return true
+			}
+			for _, gs := range extGStates(ctx, d) {
+				if gs["SMask"] != nil && !isName(gs, "SMask", "None") || gs["BM"] != nil && !isName(gs, "BM", "Normal") && !isName(gs, "BM", "Compatible") {
+					return true
+				}
+				for _, key := range []string{"CA", "ca"} {
+					if alpha, err := ctx.DereferenceNumber(gs[key]); err == nil && gs[key] != nil && alpha != 1 {
+						return true
+					}
+				}
+			}
+			return false
+		},
+		remove: func(ctx *pdfcpu.Context, d pdfcpu.Dict) {
+			if isName(d, "Subtype", "Image") {
+				delete(d, "SMask")
+			}
+			delete(d, "SMaskInData")
+			if group, err := ctx.DereferenceDict(d["Group"]); err == nil && group != nil && isName(group, "S", "Transparency") {
+				delete(d, "Group")
+			}
+			if d["Rect"] != nil {
+				delete(d, "CA")
+			}
+			for _, gs := range extGStates(ctx, d) {
+				for _, key := range transparency_keys {
+					delete(gs, key)
+				}
+			}
+		},
+	},
+	{
+		// Layers. Content in a hidden layer shows up once there are none.
+		name:  "optional_content",
+		since: pdfcpu.V15,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			return d["OCProperties"] != nil && isName(d, "Type", "Catalog") || d["OC"] != nil
+		},
+		remove: func(ctx *pdfcpu.Context, d pdfcpu.Dict) {
+			if isName(d, "Type", "Catalog") {
+				delete(d, "OCProperties")
+			}
+			delete(d, "OC")
+		},
+	},
+	{
+		// The XFA form of an AcroForm, its AcroForm fields stay
+		name:  "xfa",
+		since: pdfcpu.V15,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			return d["XFA"] != nil && d["Fields"] != nil || d["NeedsRendering"] != nil && isName(d, "Type", "Catalog")
+		},
+		remove: func(ctx *pdfcpu.Context, d pdfcpu.Dict) {
+			if d["Fields"] != nil {
+				delete(d, "XFA")
+			}
+			if isName(d, "Type", "Catalog") {
+				delete(d, "NeedsRendering")
+			}
+		},
+	},
+	{
+		// The order tabbing goes through the annotations of a page in
+		name:  "tab_order",
+		since: pdfcpu.V15,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			return d["Tabs"] != nil && isName(d, "Type", "Page")
+		},
+		remove: func(ctx *pdfcpu.Context, d pdfcpu.Dict) {
+			delete(d, "Tabs")
+		},
+	},
+	{
+		// How a viewer lays out the attachments of a portfolio, the attachments stay
+		name:  "portfolio",
+		since: pdfcpu.V17,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			return d["Collection"] != nil && isName(d, "Type", "Catalog")
+		},
+		remove: func(ctx *pdfcpu.Context, d pdfcpu.Dict) {
+			delete(d, "Collection")
+		},
+	},
+	{
+		name:  "jbig2_images",
+		since: pdfcpu.V14,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			return hasFilter(ctx, d, "JBIG2Decode")
+		},
+	},
+	{
+		name:  "jpeg2000_images",
+		since: pdfcpu.V15,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			return hasFilter(ctx, d, "JPXDecode")
+		},
+	},
+	{
+		name:  "3d_annotations",
+		since: pdfcpu.V16,
+		has: func(ctx *pdfcpu.Context, d pdfcpu.Dict) bool {
+			return isName(d, "Subtype", "3D") && d["Rect"] != nil
+		},
+	},
+}
+
+// Convert writes in_file to out_path as a PDF of version (one of ConvertVersions), with
+// that version in the header and no object or xref streams. Features newer than version
+// are taken out where that's possible without losing content (transparency, layers, XFA,
+// portfolio layouts) and reported as unconverted where it isn't (JBIG2 and JPEG 2000 images,
+// 3D annotations). Converting to a newer version only changes the header.
+func Convert(in_file InputFile, out_path string, version string) (ConvertResult, error) {
+	if !containsString(ConvertVersions, version) {
+		return ConvertResult{}, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+	target, _ := pdfcpu.PDFVersion(version)
+
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return ConvertResult{}, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	if ctx.Encrypt != nil || ctx.EncKey != nil {
+		return ConvertResult{}, ErrConvertEncrypted
+	}
+	result := ConvertResult{FromVersion: ctx.VersionString(), Version: version, Removed: make([]string, 0), Unconverted: make([]string, 0)}
+
+	found := make([]bool, len(version_features))
+	walkDicts(ctx, func(d pdfcpu.Dict) {
+		for i, feature := range version_features {
+			if feature.since <= target || !feature.has(ctx, d) {
+				continue
+			}
+			found[i] = true
+			if feature.remove != nil {
+				feature.remove(ctx, d)
+			}
+		}
+	})
+	for i, feature := range version_features {
+		switch {
+		case !found[i]:
+		case feature.remove != nil:
+			result.Removed = append(result.Removed, feature.name)
+		default:
+			result.Unconverted = append(result.Unconverted, feature.name)
+		}
+	}
+
+	// The header says which version it is now, a catalog Version would override it
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return ConvertResult{}, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	delete(cat, "Version")
+	if ctx.Info != nil {
+		if info, err := ctx.DereferenceDict(*ctx.Info); err == nil && info != nil {
+			info["ModDate"] = pdfcpu.StringLiteral(pdfcpu.DateString(time.Now()))
+			info["Producer"] = pdfcpu.StringLiteral("pdfcpu " + pdfcpu.VersionStr)
+		}
+	}
+	err = writeFile(out_path, func(w io.Writer) error {
+		return writeWithXRefTable(ctx, w, target)
+	})
+	if err != nil {
+		return ConvertResult{}, err
+	}
+	return result, nil
+}
+
+func walkDicts(ctx *pdfcpu.Context, visit func(d pdfcpu.Dict)) {
+	/*
+		Calls visit for every dict of ctx: the objects of the xref table, stream dicts and
+		the dicts inside them. References aren't followed, what they point at is in the
+		xref table itself. Objects go in object number order, so the walk is the same every time.
+	*/
+	obj_nrs := make([]int, 0, len(ctx.Table))
+	for obj_nr, entry := range ctx.Table {
+		if entry != nil && !entry.Free && entry.Object != nil {
+			obj_nrs = append(obj_nrs, obj_nr)
+		}
+	}
+	sort.Ints(obj_nrs)
+	var walk func(o pdfcpu.Object)
+	walk = func(o pdfcpu.Object) {
+		switch o := o.(type) {
+		case pdfcpu.Dict:
+			visit(o)
+			for _, value := range o {
+				walk(value)
+			}
+		case pdfcpu.StreamDict:
+			walk(o.Dict)
+		case pdfcpu.Array:
+			for _, value := range o {
+				walk(value)
+			}
+		}
+	}
+	for _, obj_nr := range obj_nrs {
+		walk(ctx.Table[obj_nr].Object)
+	}
+}
+
+func isName(d pdfcpu.Dict, key string, name string) bool {
+	value := d.NameEntry(key)
+	return value != nil && *value == name
+}
+
+func extGStates(ctx *pdfcpu.Context, d pdfcpu.Dict) []pdfcpu.Dict {
+	// The graphics states of d when it's a resource dict, they have no Type to tell them by
+	states, err := ctx.DereferenceDict(d["ExtGState"])
+	if err != nil || states == nil {
+		return nil
+	}
+	gs_dicts := make([]pdfcpu.Dict, 0, len(states))
+	for _, o := range states {
+		if gs, err := ctx.DereferenceDict(o); err == nil && gs != nil {
+			gs_dicts = append(gs_dicts, gs)
+		}
+	}
+	return gs_dicts
+}
+
+func hasFilter(ctx *pdfcpu.Context, d pdfcpu.Dict, filter string) bool {
+	// Filter is one name or a list of them
+	o, err := ctx.Dereference(d["Filter"])
+	if err != nil {
+		return false
+	}
+	switch o := o.(type) {
+	case pdfcpu.Name:
+		return string(o) == filter
+	case pdfcpu.Array:
+		for _, name := range o {
+			if name, ok := name.(pdfcpu.Name); ok && string(name) == filter {
+				return true
+			}
+		}
+	}
+	return false
+}
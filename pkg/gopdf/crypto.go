@@ -0,0 +1,99 @@
+package gopdf
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+var (
+	// ErrPasswordRequired is returned when an encrypted file is read without a password
+	ErrPasswordRequired = errors.New("password required")
+	// ErrWrongPassword is returned when the password doesn't open an encrypted file
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrNotEncrypted is returned when decrypting a file that isn't encrypted
+	ErrNotEncrypted = errors.New("file is not encrypted")
+	// ErrAlreadyEncrypted is returned when encrypting a file that already is
+	ErrAlreadyEncrypted = errors.New("file is already encrypted")
+)
+
+// EncryptOptions is how Encrypt protects a file
+type EncryptOptions struct {
+	// UserPassword opens the file, it can't be empty
+	UserPassword string
+	// OwnerPassword lifts the permissions, it is the UserPassword when empty
+	OwnerPassword string
+	// Permissions are the user access permission bits (P), pdfcpu.PermissionsNone when 0
+	Permissions int16
+}
+
+// Encrypt writes an AES-256 encrypted copy of in_file to out_path.
+func Encrypt(in_file InputFile, out_path string, options EncryptOptions) error {
+	if options.UserPassword == "" {
+		return errors.New("a user password is required")
+	}
+	owner_password := options.OwnerPassword
+	if owner_password == "" {
+		owner_password = options.UserPassword
+	}
+	conf := pdfcpu.NewAESConfiguration(options.UserPassword, owner_password, 256)
+	if options.Permissions != 0 {
+		conf.Permissions = options.Permissions
+	}
+	err := passwordError(in_file, transformFile(in_file, out_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		return api.Encrypt(rs, w, conf)
+	}))
+	// Without a password an encrypted file doesn't even get through validation
+	var file_error *FileError
+	if errors.As(err, &file_error) && (file_error.Err == ErrPasswordRequired || file_error.Err == ErrWrongPassword) {
+		file_error.Err = ErrAlreadyEncrypted
+	}
+	return err
+}
+
+// Decrypt writes a decrypted copy of in_file to out_path, password can be the user or the owner password.
+// A wrong password is ErrWrongPassword and a file that isn't encrypted is ErrNotEncrypted.
+func Decrypt(in_file InputFile, out_path string, password string) error {
+	if password == "" {
+		return errors.New("a password is required")
+	}
+	conf := pdfcpu.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	in_file.Password = password
+	return passwordError(in_file, transformFile(in_file, out_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		return api.Decrypt(rs, w, conf)
+	}))
+}
+
+func passwordError(in_file InputFile, err error) error {
+	/*
+		pdfcpu only tells these cases apart by message, turn them into errors callers can check
+		with errors.Is. A FileError keeps being a FileError so callers still know the file was at fault.
+	*/
+	if err == nil {
+		return nil
+	}
+	target := &err
+	var file_error *FileError
+	if errors.As(err, &file_error) {
+		target = &file_error.Err
+	}
+	message := (*target).Error()
+	switch {
+	case strings.Contains(message, "please provide the correct password"):
+		if in_file.Password == "" {
+			*target = ErrPasswordRequired
+		} else {
+			*target = ErrWrongPassword
+		}
+	case strings.Contains(message, "this file is not encrypted"):
+		*target = ErrNotEncrypted
+	case strings.Contains(message, "this file is already encrypted"):
+		*target = ErrAlreadyEncrypted
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package gopdf
+
+import (
+	"crypto/sha256"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// DedupeResult is what collapsing identical streams saved
+type DedupeResult struct {
+	// The copies dropped, every reference to one now goes to the stream it copied
+	Streams int `json:"streams"`
+	// The stream data of those copies, as it would have been written (encoded)
+	BytesSaved int64 `json:"bytes_saved"`
+}
+
+func dedupeStreams(ctx *pdfcpu.Context) DedupeResult {
+	/*
+		Collapses the streams of ctx that are the same, dict and encoded data, to the one
+		with the lowest object number and points every reference to the others at it. The
+		copies leave the xref table, so they aren't written. This goes round until nothing
+		changes: two images with copies of the same SMask only look the same once their
+		SMasks are one object. pdfcpu's optimize only looks at the fonts and images of page
+		resources, files merged from the same template share more than that: logos inside
+		form XObjects, ICC profiles, widget appearances.
+	*/
+	var result DedupeResult
+	for {
+		copies := make(map[int]pdfcpu.IndirectRef)
+		kept := make(map[[sha256.Size]byte]int)
+		for _, obj_nr := range streamObjects(ctx) {
+			sd := ctx.Table[obj_nr].Object.(pdfcpu.StreamDict)
+			key := streamKey(sd)
+			if first, found := kept[key]; found {
+				copies[obj_nr] = *pdfcpu.NewIndirectRef(first, 0)
+				result.Streams++
+				result.BytesSaved += int64(len(sd.Raw))
+				continue
+			}
+			kept[key] = obj_nr
+		}
+		if len(copies) == 0 {
+			return result
+		}
+		for obj_nr := range copies {
+			delete(ctx.Table, obj_nr)
+		}
+		for _, entry := range ctx.Table {
+			if entry != nil && !entry.Free && entry.Object != nil {
+				entry.Object = replaceRefs(entry.Object, copies)
+			}
+		}
+	}
+}
+
+func streamObjects(ctx *pdfcpu.Context) []int {
+	/*
+		The object numbers of the streams of ctx that can be shared, in order. Only the
+		ones the file still uses count: pdfcpu's optimize points the references to the
+		fonts and images it found twice at one of them and leaves the rest in the xref
+		table for the writer to drop, and a file can have objects nothing refers to.
+	*/
+	used := usedObjects(ctx)
+	obj_nrs := make([]int, 0)
+	for obj_nr, entry := range ctx.Table {
+		if !used[obj_nr] || entry == nil || entry.Free || entry.Generation == nil || *entry.Generation != 0 {
+			continue
+		}
+		sd, ok := entry.Object.(pdfcpu.StreamDict)
+		// Object and xref streams are how a file was written, not part of what's in it
+		if !ok || sd.Raw == nil || isName(sd.Dict, "Type", "ObjStm") || isName(sd.Dict, "Type", "XRef") {
+			continue
+		}
+		obj_nrs = append(obj_nrs, obj_nr)
+	}
+	sort.Ints(obj_nrs)
+	return obj_nrs
+}
+
+func usedObjects(ctx *pdfcpu.Context) map[int]bool {
+	// The object numbers reachable from the trailer of ctx, its catalog and Info dict
+	used := make(map[int]bool)
+	var walk func(o pdfcpu.Object)
+	walk = func(o pdfcpu.Object) {
+		switch o := o.(type) {
+		case pdfcpu.IndirectRef:
+			obj_nr := o.ObjectNumber.Value()
+			entry, found := ctx.Table[obj_nr]
+			if used[obj_nr] || !found || entry == nil || entry.Free {
+				return
+			}
+			used[obj_nr] = true
+			walk(entry.Object)
+		case pdfcpu.Dict:
+			for _, value := range o {
+				walk(value)
+			}
+		case pdfcpu.StreamDict:
+			walk(o.Dict)
+		case pdfcpu.Array:
+			for _, value := range o {
+				walk(value)
+			}
+		}
+	}
+	for _, ref := range []*pdfcpu.IndirectRef{ctx.Root, ctx.Info} {
+		if ref != nil {
+			walk(*ref)
+		}
+	}
+	return used
+}
+
+func streamKey(sd pdfcpu.StreamDict) [sha256.Size]byte {
+	// The hash of sd's dict and encoded data, Length left out: it may be a reference, the data has the length anyway
+	d := sd.Dict.Clone().(pdfcpu.Dict)
+	delete(d, "Length")
+	h := sha256.New()
+	h.Write([]byte(d.PDFString()))
+	h.Write([]byte{0})
+	h.Write(sd.Raw)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+func replaceRefs(o pdfcpu.Object, refs map[int]pdfcpu.IndirectRef) pdfcpu.Object {
+	// o with the references to the keys of refs going to their values instead, dicts and arrays changed in place
+	switch o := o.(type) {
+	case pdfcpu.IndirectRef:
+		if ref, found := refs[o.ObjectNumber.Value()]; found {
+			return ref
+		}
+	case pdfcpu.Dict:
+		for key, value := range o {
+			o[key] = replaceRefs(value, refs)
+		}
+	case pdfcpu.StreamDict:
+		replaceRefs(o.Dict, refs)
+	case pdfcpu.Array:
+		for i, value := range o {
+			o[i] = replaceRefs(value, refs)
+		}
+	}
+	return o
+}
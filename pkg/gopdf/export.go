@@ -0,0 +1,183 @@
+package gopdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// Formats ExportForm writes
+const (
+	FormatFDF  = "fdf"
+	FormatXFDF = "xfdf"
+)
+
+// ValidExportFormat reports whether ExportForm can write format
+func ValidExportFormat(format string) bool {
+	return format == FormatFDF || format == FormatXFDF
+}
+
+// One level of the field tree, fully qualified names are split back into their partial names
+type exportNode struct {
+	name   string
+	field  *Field
+	kids   []*exportNode
+	lookup map[string]*exportNode
+}
+
+// ExportForm writes the values of in_file's form fields to w as FDF or XFDF.
+// Fields nest under their parents like in the PDF, so "address.zip" is a "zip"
+// field under an "address" one. Fields without a value are written without one.
+// A file that can't be read is a FileError.
+func ExportForm(in_file InputFile, format string, w io.Writer) error {
+	if !ValidExportFormat(format) {
+		return fmt.Errorf("format must be %s or %s, got %q", FormatFDF, FormatXFDF, format)
+	}
+	fields, file_error := scrapeFile(0, in_file)
+	if file_error != nil {
+		return file_error
+	}
+
+	root := &exportNode{lookup: make(map[string]*exportNode)}
+	for i := range fields {
+		node := root
+		for _, partial_name := range strings.Split(fields[i].Name, ".") {
+			kid, found := node.lookup[partial_name]
+			if !found {
+				kid = &exportNode{name: partial_name, lookup: make(map[string]*exportNode)}
+				node.lookup[partial_name] = kid
+				node.kids = append(node.kids, kid)
+			}
+			node = kid
+		}
+		node.field = &fields[i]
+	}
+
+	var buf bytes.Buffer
+	if format == FormatFDF {
+		writeFDF(&buf, filepath.Base(in_file.Name), root.kids)
+	} else {
+		writeXFDF(&buf, filepath.Base(in_file.Name), root.kids)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeFDF(buf *bytes.Buffer, file_name string, nodes []*exportNode) {
+	buf.WriteString("%FDF-1.2\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /FDF << /F ")
+	buf.WriteString(pdfString(file_name))
+	buf.WriteString(" /Fields [")
+	writeFDFFields(buf, nodes)
+	buf.WriteString("] >> >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+}
+
+func writeFDFFields(buf *bytes.Buffer, nodes []*exportNode) {
+	for _, node := range nodes {
+		buf.WriteString("\n<< /T ")
+		buf.WriteString(pdfString(node.name))
+		if node.field != nil && node.field.Value != nil {
+			buf.WriteString(" /V ")
+			buf.WriteString(fdfValue(node.field.Type, node.field.Value))
+		}
+		if len(node.kids) > 0 {
+			buf.WriteString(" /Kids [")
+			writeFDFFields(buf, node.kids)
+			buf.WriteString("]")
+		}
+		buf.WriteString(" >>")
+	}
+}
+
+func fdfValue(field_type string, value interface{}) string {
+	// Buttons take the name of their state, everything else strings
+	if values, ok := value.([]interface{}); ok {
+		parts := make([]string, 0, len(values))
+		for _, v := range values {
+			if v != nil {
+				parts = append(parts, fdfValue(field_type, v))
+			}
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	}
+	text := fmt.Sprintf("%v", value)
+	if field_type == "Btn" {
+		return pdfName(text)
+	}
+	return pdfString(text)
+}
+
+func pdfString(s string) string {
+	/*
+		A literal string when s is plain ASCII, otherwise UTF-16BE with a BOM
+		in a hex string, the only other encoding every reader understands.
+	*/
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 || (s[i] < 0x20 && s[i] != '\n' && s[i] != '\r' && s[i] != '\t') {
+			utf16_bytes := []byte{0xFE, 0xFF}
+			for _, r := range utf16.Encode([]rune(s)) {
+				utf16_bytes = append(utf16_bytes, byte(r>>8), byte(r))
+			}
+			return "<" + strings.ToUpper(hex.EncodeToString(utf16_bytes)) + ">"
+		}
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return "(" + escaper.Replace(s) + ")"
+}
+
+func pdfName(s string) string {
+	// Delimiters, whitespace and # are written as #xx
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func writeXFDF(buf *bytes.Buffer, file_name string, nodes []*exportNode) {
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<xfdf xmlns="http://ns.adobe.com/xfdf/" xml:space="preserve">` + "\n")
+	buf.WriteString("<f href=\"")
+	xml.EscapeText(buf, []byte(file_name))
+	buf.WriteString("\"/>\n<fields>\n")
+	writeXFDFFields(buf, nodes)
+	buf.WriteString("</fields>\n</xfdf>\n")
+}
+
+func writeXFDFFields(buf *bytes.Buffer, nodes []*exportNode) {
+	for _, node := range nodes {
+		buf.WriteString(`<field name="`)
+		xml.EscapeText(buf, []byte(node.name))
+		buf.WriteString(`">`)
+		if node.field != nil && node.field.Value != nil {
+			// Multi select fields get a value element per selected option
+			values, ok := node.field.Value.([]interface{})
+			if !ok {
+				values = []interface{}{node.field.Value}
+			}
+			for _, v := range values {
+				if v == nil {
+					continue
+				}
+				buf.WriteString("<value>")
+				xml.EscapeText(buf, []byte(fmt.Sprintf("%v", v)))
+				buf.WriteString("</value>")
+			}
+		}
+		if len(node.kids) > 0 {
+			buf.WriteString("\n")
+			writeXFDFFields(buf, node.kids)
+		}
+		buf.WriteString("</field>\n")
+	}
+}
@@ -0,0 +1,302 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ErrXFAForm is what reading or filling the fields of a file comes back with when its
+// form is XFA (XML Forms Architecture) only, with an AcroForm that has no fields of its own
+var ErrXFAForm = errors.New("XFA form not supported, the AcroForm has no fields, only an XFA form we can't read")
+
+func hasXFA(adict pdfcpu.Dict) bool {
+	_, found := adict.Find("XFA")
+	return found
+}
+
+func walkFields(ctx *pdfcpu.Context, fields pdfcpu.Array, visit func(field_name string, d pdfcpu.Dict) error) error {
+	/*
+		Walks the AcroForm field tree depth first and calls visit for every terminal field
+		with its fully qualified name, e.g. a "zip" field under an "address" parent is "address.zip".
+		Kids that have no T of their own are the widgets of their parent, so a field whose
+		kids are all widgets (radio groups for example) is a terminal field too.
+	*/
+	visited := make(map[int]bool)
+
+	var walk func(fields pdfcpu.Array, parent_name string) error
+	walk = func(fields pdfcpu.Array, parent_name string) error {
+		for i, o := range fields {
+			// Broken files can have cycles in the field tree
+			if ir, ok := o.(pdfcpu.IndirectRef); ok {
+				if visited[ir.ObjectNumber.Value()] {
+					continue
+				}
+				visited[ir.ObjectNumber.Value()] = true
+			}
+			d, err := ctx.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+			if d == nil {
+				return fmt.Errorf("field %d under %q is not a Dict", i, parent_name)
+			}
+
+			field_name := parent_name
+			if t, found := d.Find("T"); found {
+				partial_name, err := ctx.DereferenceText(t)
+				if err != nil {
+					return err
+				}
+				if field_name != "" {
+					field_name += "."
+				}
+				field_name += partial_name
+			}
+
+			kids, err := ctx.DereferenceArray(d["Kids"])
+			if err != nil {
+				return err
+			}
+			if hasFieldKids(ctx, kids) {
+				if err := walk(kids, field_name); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// A field can leave its type to the parent it's in a group of
+			if _, ok := inheritedAttr(ctx, d, "FT").(pdfcpu.Name); !ok {
+				continue
+			}
+			if err := visit(field_name, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return walk(fields, "")
+}
+
+func hasFieldKids(ctx *pdfcpu.Context, kids pdfcpu.Array) bool {
+	// Kids with a T are fields of their own, kids without one are just widgets
+	for _, o := range kids {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+		if _, found := d.Find("T"); found {
+			return true
+		}
+	}
+	return false
+}
+
+func inheritedAttr(ctx *pdfcpu.Context, d pdfcpu.Dict, key string) pdfcpu.Object {
+	// Walks up the field tree until some ancestor has key, FT, V, DV, DA, Q and Ff are all inheritable
+	for depth := 0; d != nil && depth < 32; depth++ {
+		if o, found := d.Find(key); found {
+			if dereferenced, err := ctx.Dereference(o); err == nil {
+				return dereferenced
+			}
+			return o
+		}
+		parent, err := ctx.DereferenceDict(d["Parent"])
+		if err != nil {
+			return nil
+		}
+		d = parent
+	}
+	return nil
+}
+
+func maxLen(ctx *pdfcpu.Context, d pdfcpu.Dict) (int, bool) {
+	/*
+		The MaxLen of a text field (0 when it has none) and whether it's a comb field,
+		one that spreads its MaxLen characters over as many evenly sized cells.
+		Both are inherited, comb is bit 25 of Ff and only means something with a MaxLen.
+	*/
+	max_len := 0
+	if i, ok := inheritedAttr(ctx, d, "MaxLen").(pdfcpu.Integer); ok && i > 0 {
+		max_len = i.Value()
+	}
+	comb := false
+	if ff, ok := inheritedAttr(ctx, d, "Ff").(pdfcpu.Integer); ok {
+		comb = ff&(1<<24) > 0 && max_len > 0
+	}
+	return max_len, comb
+}
+
+func fieldValue(ctx *pdfcpu.Context, o pdfcpu.Object) interface{} {
+	/*
+		Turns a V (or DV) entry into something that serializes to JSON:
+		strings for text, names for buttons and a list for multi select fields.
+	*/
+	o, err := ctx.Dereference(o)
+	if err != nil || o == nil {
+		return nil
+	}
+	switch v := o.(type) {
+	case pdfcpu.StringLiteral, pdfcpu.HexLiteral:
+		s, err := pdfcpu.Text(v)
+		if err != nil {
+			return nil
+		}
+		return s
+	case pdfcpu.Name:
+		return string(v)
+	case pdfcpu.Array:
+		values := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			values = append(values, fieldValue(ctx, e))
+		}
+		return values
+	}
+	return o.String()
+}
+
+func multiSelectValue(value interface{}) []interface{} {
+	/*
+		The V of a multi select list box as the list of export values it is, also when
+		it's a single string (one selected option) or missing (none)
+	*/
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case nil:
+		return make([]interface{}, 0)
+	}
+	return []interface{}{value}
+}
+
+// ChoiceOption is one entry of a choice field's Opt array: Display is the text shown to
+// the user, Export what ends up in V when it's picked
+type ChoiceOption struct {
+	Export  string `json:"export"`
+	Display string `json:"display"`
+}
+
+func choiceOptions(ctx *pdfcpu.Context, o pdfcpu.Object) []ChoiceOption {
+	/*
+		Opt is either a list of strings or a list of [export_value display_value] pairs,
+		a plain string is both the export and the display value.
+	*/
+	opts, err := ctx.DereferenceArray(o)
+	if err != nil || opts == nil {
+		return nil
+	}
+	choices := make([]ChoiceOption, 0, len(opts))
+	for _, opt := range opts {
+		opt, err := ctx.Dereference(opt)
+		if err != nil {
+			continue
+		}
+		pair, ok := opt.(pdfcpu.Array)
+		if !ok {
+			if s, err := pdfcpu.Text(opt); err == nil {
+				choices = append(choices, ChoiceOption{Export: s, Display: s})
+			}
+			continue
+		}
+		if len(pair) == 0 {
+			continue
+		}
+		export, err := ctx.DereferenceText(pair[0])
+		if err != nil {
+			continue
+		}
+		display := export
+		if len(pair) > 1 {
+			if s, err := ctx.DereferenceText(pair[1]); err == nil {
+				display = s
+			}
+		}
+		choices = append(choices, ChoiceOption{Export: export, Display: display})
+	}
+	return choices
+}
+
+// Widget is where a field shows up: the page it's on, starting at 1, and its Rect
+// [llx lly urx ury] in the page's user space. State is the appearance state a radio
+// button or checkbox widget is on with, the value its field gets when that one is picked.
+type Widget struct {
+	Page  int       `json:"page"`
+	Rect  []float64 `json:"rect"`
+	State string    `json:"state,omitempty"`
+}
+
+func fieldWidgets(ctx *pdfcpu.Context) (map[string][]Widget, error) {
+	/*
+		The widgets of every field by fully qualified field name, in page order and the
+		order of each page's Annots. Widgets are only linked to the pages they're on from
+		the Annots of the page, so the page tree is what's walked here. A widget with a T
+		is its own field (merged into one dict), one without is a kid of its Parent field.
+		Widgets on no page aren't shown anywhere and aren't listed.
+	*/
+	widgets := make(map[string][]Widget)
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, err
+	}
+	for page_nr := 1; page_nr <= ctx.PageCount; page_nr++ {
+		page_dict, _, _, err := ctx.PageDict(page_nr, false)
+		if err != nil {
+			return nil, err
+		}
+		annots, err := ctx.DereferenceArray(page_dict["Annots"])
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range annots {
+			annot, err := ctx.DereferenceDict(o)
+			if err != nil || annot == nil {
+				continue
+			}
+			if subtype := annot.NameEntry("Subtype"); subtype == nil || *subtype != "Widget" {
+				continue
+			}
+			rect, ok := numbers(ctx, annot["Rect"], 4)
+			if !ok {
+				continue
+			}
+			field_name := widgetFieldName(ctx, annot)
+			if field_name == "" {
+				continue
+			}
+			state, _ := onState(ctx, annot)
+			widgets[field_name] = append(widgets[field_name], Widget{
+				Page:  page_nr,
+				Rect:  []float64{math.Min(rect[0], rect[2]), math.Min(rect[1], rect[3]), math.Max(rect[0], rect[2]), math.Max(rect[1], rect[3])},
+				State: state,
+			})
+		}
+	}
+	return widgets, nil
+}
+
+func widgetFieldName(ctx *pdfcpu.Context, widget pdfcpu.Dict) string {
+	// The T of widget and its ancestors joined with dots, like walkFields names the fields
+	field_name := ""
+	d := widget
+	for depth := 0; d != nil && depth < 32; depth++ {
+		if t, found := d.Find("T"); found {
+			partial_name, err := ctx.DereferenceText(t)
+			if err != nil {
+				return ""
+			}
+			if field_name == "" {
+				field_name = partial_name
+			} else {
+				field_name = qualifiedName(partial_name, field_name)
+			}
+		}
+		parent, err := ctx.DereferenceDict(d["Parent"])
+		if err != nil {
+			return ""
+		}
+		d = parent
+	}
+	return field_name
+}
@@ -0,0 +1,530 @@
+package gopdf
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+func flattenForm(ctx *pdfcpu.Context) error {
+	/*
+		pdfcpu has no flattening, so it's done here: the appearance of every widget is drawn
+		into the content stream of its page, then the widgets and the AcroForm are removed.
+		Text and choice fields get a fresh appearance for their value, we never write one
+		when filling, buttons and everything else keep the appearance they have.
+	*/
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	acroform, ok := cat.Find("AcroForm")
+	if !ok {
+		// No forms in this file, nothing to flatten
+		return nil
+	}
+	adict, err := ctx.DereferenceDict(acroform)
+	if err != nil {
+		return err
+	}
+	if adict == nil {
+		adict = pdfcpu.NewDict()
+	}
+
+	err = ctx.EnsurePageCount()
+	if err != nil {
+		return err
+	}
+	flattener := formFlattener{ctx: ctx, adict: adict}
+	for page_nr := 1; page_nr <= ctx.PageCount; page_nr++ {
+		err = flattener.flattenPage(page_nr)
+		if err != nil {
+			return fmt.Errorf("flattening page %d: %v", page_nr, err)
+		}
+	}
+
+	delete(cat, "AcroForm")
+	return nil
+}
+
+// formFlattener keeps what flattening the pages of a document shares
+type formFlattener struct {
+	ctx   *pdfcpu.Context
+	adict pdfcpu.Dict
+	// Used by every generated appearance whose DA font isn't in the AcroForm's DR
+	default_font *pdfcpu.IndirectRef
+	// XObject names have to be unique, pages can share a resource dict
+	xobject_count int
+}
+
+func (flattener *formFlattener) flattenPage(page_nr int) error {
+	ctx := flattener.ctx
+	page_dict, _, inherited, err := ctx.PageDict(page_nr, false)
+	if err != nil {
+		return err
+	}
+	annots, err := ctx.DereferenceArray(page_dict["Annots"])
+	if err != nil || len(annots) == 0 {
+		return err
+	}
+
+	kept_annots := make(pdfcpu.Array, 0, len(annots))
+	xobjects := make(map[string]*pdfcpu.IndirectRef)
+	var draws strings.Builder
+	for _, o := range annots {
+		annot, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if annot == nil {
+			continue
+		}
+		if subtype := annot.NameEntry("Subtype"); subtype == nil || *subtype != "Widget" {
+			kept_annots = append(kept_annots, o)
+			continue
+		}
+		// Hidden (2) and NoView (32) widgets aren't drawn but they go away all the same
+		if flags := annot.IntEntry("F"); flags != nil && *flags&(2|32) > 0 {
+			continue
+		}
+		appearance, err := flattener.widgetAppearance(annot)
+		if err != nil {
+			return err
+		}
+		if appearance == nil {
+			continue
+		}
+		matrix, ok := flattener.appearanceMatrix(annot, appearance)
+		if !ok {
+			continue
+		}
+		flattener.xobject_count++
+		name := fmt.Sprintf("GopdfFlat%d", flattener.xobject_count)
+		xobjects[name] = appearance
+		fmt.Fprintf(&draws, "q %s cm /%s Do Q\n", matrix, name)
+	}
+
+	if len(kept_annots) > 0 {
+		page_dict["Annots"] = kept_annots
+	} else {
+		delete(page_dict, "Annots")
+	}
+	if len(xobjects) == 0 {
+		return nil
+	}
+
+	// Resources inherited from the page tree get their own copy on this page
+	resources, err := ctx.DereferenceDict(page_dict["Resources"])
+	if err != nil {
+		return err
+	}
+	if resources == nil {
+		resources = pdfcpu.NewDict()
+		if inherited.Resources != nil {
+			resources = inherited.Resources.Clone().(pdfcpu.Dict)
+		}
+		page_dict["Resources"] = resources
+	}
+	xobject_dict, err := ctx.DereferenceDict(resources["XObject"])
+	if err != nil {
+		return err
+	}
+	if xobject_dict == nil {
+		xobject_dict = pdfcpu.NewDict()
+		resources["XObject"] = xobject_dict
+	}
+	for name, appearance := range xobjects {
+		xobject_dict[name] = *appearance
+	}
+
+	// The page content is wrapped in q/Q so whatever state it leaves behind doesn't move our drawing
+	save_ref, err := flattener.newStream([]byte("q\n"), nil)
+	if err != nil {
+		return err
+	}
+	draw_ref, err := flattener.newStream([]byte("Q\n"+draws.String()), nil)
+	if err != nil {
+		return err
+	}
+	contents := pdfcpu.Array{*save_ref}
+	switch o := page_dict["Contents"].(type) {
+	case nil:
+	case pdfcpu.Array:
+		contents = append(contents, o...)
+	case pdfcpu.IndirectRef:
+		if array, err := ctx.DereferenceArray(o); err == nil && array != nil {
+			contents = append(contents, array...)
+		} else {
+			contents = append(contents, o)
+		}
+	default:
+		contents = append(contents, o)
+	}
+	page_dict["Contents"] = append(contents, *draw_ref)
+	return nil
+}
+
+func (flattener *formFlattener) widgetAppearance(widget pdfcpu.Dict) (*pdfcpu.IndirectRef, error) {
+	/*
+		The form XObject to draw for a widget, nil when there is nothing to draw.
+		The widget is its field or one of the kids of it, so the field attributes
+		are looked up through the Parent chain.
+	*/
+	ctx := flattener.ctx
+	ft, _ := flattener.fieldAttr(widget, "FT").(pdfcpu.Name)
+	if ft == "Tx" || ft == "Ch" {
+		text := flattener.displayText(widget, string(ft))
+		if text == "" {
+			return nil, nil
+		}
+		return flattener.textAppearance(widget, text)
+	}
+
+	ap, err := ctx.DereferenceDict(widget["AP"])
+	if err != nil || ap == nil {
+		return nil, err
+	}
+	normal := ap["N"]
+	// N is either the appearance itself or a dict of appearance states (buttons), AS picks the state
+	if states, err := ctx.DereferenceDict(normal); err == nil && states != nil {
+		as := widget.NameEntry("AS")
+		if as == nil {
+			return nil, nil
+		}
+		normal = states[*as]
+	}
+	switch o := normal.(type) {
+	case pdfcpu.IndirectRef:
+		sd, _, err := ctx.DereferenceStreamDict(o)
+		if err != nil || sd == nil {
+			return nil, err
+		}
+		return &o, nil
+	case pdfcpu.StreamDict:
+		return ctx.IndRefForNewObject(o)
+	}
+	return nil, nil
+}
+
+func (flattener *formFlattener) fieldAttr(widget pdfcpu.Dict, key string) pdfcpu.Object {
+	return inheritedAttr(flattener.ctx, widget, key)
+}
+
+func (flattener *formFlattener) displayText(widget pdfcpu.Dict, ft string) string {
+	// The text a text or choice field shows, choice fields show the display value of their option
+	value := fieldValue(flattener.ctx, flattener.fieldAttr(widget, "V"))
+	text := ""
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, part := range v {
+			parts = append(parts, fmt.Sprintf("%v", part))
+		}
+		text = strings.Join(parts, ", ")
+	}
+	if ft == "Ch" {
+		for _, choice := range choiceOptions(flattener.ctx, flattener.fieldAttr(widget, "Opt")) {
+			if choice.Export == text {
+				return choice.Display
+			}
+		}
+	}
+	return text
+}
+
+var da_font = regexp.MustCompile(`/([^\s/]+)\s+([0-9.]+)\s+Tf`)
+
+func (flattener *formFlattener) textAppearance(widget pdfcpu.Dict, text string) (*pdfcpu.IndirectRef, error) {
+	/*
+		Builds a form XObject showing text the way the field's DA (font, size and color)
+		and Q (0 left, 1 centered, 2 right) say, single line, multiline or comb.
+	*/
+	ctx := flattener.ctx
+	llx, lly, urx, ury, ok := widgetRect(ctx, widget)
+	if !ok {
+		return nil, nil
+	}
+	width, height := urx-llx, ury-lly
+
+	da, _ := flattener.fieldAttr(widget, "DA").(pdfcpu.StringLiteral)
+	if da == "" {
+		if adict_da, ok := flattener.adict["DA"].(pdfcpu.StringLiteral); ok {
+			da = adict_da
+		}
+	}
+	font_name, font_size := "Helv", 0.0
+	if match := da_font.FindStringSubmatch(string(da)); match != nil {
+		font_name = match[1]
+		font_size, _ = strconv.ParseFloat(match[2], 64)
+	}
+	// Everything in DA but the font, that's the color
+	color := strings.TrimSpace(da_font.ReplaceAllString(string(da), ""))
+
+	font_ref, base_font := flattener.appearanceFont(font_name)
+	if font_ref == nil {
+		font_name = "GopdfHelv"
+		font_ref, base_font = flattener.helvetica(), "Helvetica"
+	}
+
+	ff := 0
+	if i, ok := flattener.fieldAttr(widget, "Ff").(pdfcpu.Integer); ok {
+		ff = int(i)
+	}
+	quadding := 0
+	if q, ok := flattener.fieldAttr(widget, "Q").(pdfcpu.Integer); ok {
+		quadding = int(q)
+	} else if q, ok := flattener.adict["Q"].(pdfcpu.Integer); ok {
+		quadding = int(q)
+	}
+	is_multiline := ff&(1<<12) > 0
+	is_password := ff&(1<<13) > 0
+	is_comb := ff&(1<<24) > 0
+	if is_password {
+		text = strings.Repeat("*", len([]rune(text)))
+	}
+
+	lines := []string{text}
+	if is_multiline {
+		lines = strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	}
+	if font_size == 0 {
+		// Auto size: as big as fits, up to 12
+		font_size = 12
+		if !is_multiline {
+			font_size = math.Min(12, (height-4)*0.75)
+			for font_size > 4 && textWidth(text, base_font, font_size) > width-4 {
+				font_size--
+			}
+		}
+		font_size = math.Max(font_size, 4)
+	}
+
+	var content strings.Builder
+	content.WriteString("/Tx BMC\nq\nBT\n")
+	fmt.Fprintf(&content, "/%s %s Tf\n", font_name, formatNumber(font_size))
+	if color != "" {
+		content.WriteString(color + "\n")
+	}
+	max_len := 0
+	if i, ok := flattener.fieldAttr(widget, "MaxLen").(pdfcpu.Integer); ok {
+		max_len = int(i)
+	}
+	switch {
+	case is_comb && max_len > 0 && !is_multiline:
+		// One character per cell, each centered in its cell
+		cell := width / float64(max_len)
+		y := (height-font_size)/2 + 0.22*font_size
+		for i, r := range []rune(text) {
+			if i >= max_len {
+				break
+			}
+			x := cell*float64(i) + (cell-textWidth(string(r), base_font, font_size))/2
+			fmt.Fprintf(&content, "1 0 0 1 %s %s Tm (%s) Tj\n", formatNumber(x), formatNumber(y), escapeLiteral(winAnsi(string(r))))
+		}
+	default:
+		y := (height-font_size)/2 + 0.22*font_size
+		if is_multiline {
+			y = height - 2 - font_size
+		}
+		for _, line := range lines {
+			x := 2.0
+			switch quadding {
+			case 1:
+				x = (width - textWidth(line, base_font, font_size)) / 2
+			case 2:
+				x = width - 2 - textWidth(line, base_font, font_size)
+			}
+			fmt.Fprintf(&content, "1 0 0 1 %s %s Tm (%s) Tj\n", formatNumber(x), formatNumber(y), escapeLiteral(winAnsi(line)))
+			y -= font_size * 1.15
+		}
+	}
+	content.WriteString("ET\nQ\nEMC\n")
+
+	form := pdfcpu.Dict{
+		"Type":    pdfcpu.Name("XObject"),
+		"Subtype": pdfcpu.Name("Form"),
+		"BBox":    pdfcpu.Array{pdfcpu.Float(0), pdfcpu.Float(0), pdfcpu.Float(width), pdfcpu.Float(height)},
+		"Resources": pdfcpu.Dict{
+			"Font": pdfcpu.Dict{font_name: *font_ref},
+		},
+	}
+	return flattener.newStream([]byte(content.String()), form)
+}
+
+func (flattener *formFlattener) appearanceFont(font_name string) (*pdfcpu.IndirectRef, string) {
+	// The DA font out of the AcroForm's DR, with its base font name when it's one we have metrics for
+	ctx := flattener.ctx
+	dr, err := ctx.DereferenceDict(flattener.adict["DR"])
+	if err != nil || dr == nil {
+		return nil, ""
+	}
+	fonts, err := ctx.DereferenceDict(dr["Font"])
+	if err != nil || fonts == nil {
+		return nil, ""
+	}
+	font_ref, ok := fonts[font_name].(pdfcpu.IndirectRef)
+	if !ok {
+		return nil, ""
+	}
+	font_dict, err := ctx.DereferenceDict(font_ref)
+	if err != nil || font_dict == nil {
+		return nil, ""
+	}
+	base_font := "Helvetica"
+	if name := font_dict.NameEntry("BaseFont"); name != nil && font.IsCoreFont(*name) {
+		base_font = *name
+	}
+	return &font_ref, base_font
+}
+
+func (flattener *formFlattener) helvetica() *pdfcpu.IndirectRef {
+	if flattener.default_font == nil {
+		flattener.default_font, _ = newCoreFont(flattener.ctx, "Helvetica")
+	}
+	return flattener.default_font
+}
+
+func newCoreFont(ctx *pdfcpu.Context, base_font string) (*pdfcpu.IndirectRef, error) {
+	// One of the standard 14 fonts, every viewer has those without embedding
+	font_dict := pdfcpu.Dict{
+		"Type":     pdfcpu.Name("Font"),
+		"Subtype":  pdfcpu.Name("Type1"),
+		"BaseFont": pdfcpu.Name(base_font),
+	}
+	// Symbol and ZapfDingbats have their own built in encoding
+	if base_font != "Symbol" && base_font != "ZapfDingbats" {
+		font_dict["Encoding"] = pdfcpu.Name("WinAnsiEncoding")
+	}
+	return ctx.IndRefForNewObject(font_dict)
+}
+
+func (flattener *formFlattener) newStream(content []byte, d pdfcpu.Dict) (*pdfcpu.IndirectRef, error) {
+	sd, err := flattener.ctx.NewStreamDictForBuf(content)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range d {
+		sd.Insert(key, value)
+	}
+	err = sd.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return flattener.ctx.IndRefForNewObject(*sd)
+}
+
+func (flattener *formFlattener) appearanceMatrix(widget pdfcpu.Dict, appearance *pdfcpu.IndirectRef) (string, bool) {
+	/*
+		The cm that puts the appearance on the widget's Rect: the appearance's BBox,
+		transformed by its Matrix, is scaled and moved onto Rect (PDF 32000-1 12.5.5).
+	*/
+	ctx := flattener.ctx
+	llx, lly, urx, ury, ok := widgetRect(ctx, widget)
+	if !ok {
+		return "", false
+	}
+	sd, _, err := ctx.DereferenceStreamDict(*appearance)
+	if err != nil || sd == nil {
+		return "", false
+	}
+	bbox, ok := numbers(ctx, sd.Dict["BBox"], 4)
+	if !ok {
+		return "", false
+	}
+	matrix, ok := numbers(ctx, sd.Dict["Matrix"], 6)
+	if !ok {
+		matrix = []float64{1, 0, 0, 1, 0, 0}
+	}
+	min_x, min_y := math.Inf(1), math.Inf(1)
+	max_x, max_y := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{bbox[0], bbox[1]}, {bbox[2], bbox[1]}, {bbox[0], bbox[3]}, {bbox[2], bbox[3]}} {
+		x := matrix[0]*corner[0] + matrix[2]*corner[1] + matrix[4]
+		y := matrix[1]*corner[0] + matrix[3]*corner[1] + matrix[5]
+		min_x, max_x = math.Min(min_x, x), math.Max(max_x, x)
+		min_y, max_y = math.Min(min_y, y), math.Max(max_y, y)
+	}
+	if max_x-min_x == 0 || max_y-min_y == 0 {
+		return "", false
+	}
+	scale_x := (urx - llx) / (max_x - min_x)
+	scale_y := (ury - lly) / (max_y - min_y)
+	return fmt.Sprintf("%s 0 0 %s %s %s", formatNumber(scale_x), formatNumber(scale_y), formatNumber(llx-min_x*scale_x), formatNumber(lly-min_y*scale_y)), true
+}
+
+func widgetRect(ctx *pdfcpu.Context, widget pdfcpu.Dict) (float64, float64, float64, float64, bool) {
+	rect, ok := numbers(ctx, widget["Rect"], 4)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	llx, urx := math.Min(rect[0], rect[2]), math.Max(rect[0], rect[2])
+	lly, ury := math.Min(rect[1], rect[3]), math.Max(rect[1], rect[3])
+	if urx-llx == 0 || ury-lly == 0 {
+		return 0, 0, 0, 0, false
+	}
+	return llx, lly, urx, ury, true
+}
+
+func numbers(ctx *pdfcpu.Context, o pdfcpu.Object, count int) ([]float64, bool) {
+	array, err := ctx.DereferenceArray(o)
+	if err != nil || len(array) != count {
+		return nil, false
+	}
+	values := make([]float64, count)
+	for i, elem := range array {
+		elem, _ = ctx.Dereference(elem)
+		switch n := elem.(type) {
+		case pdfcpu.Integer:
+			values[i] = float64(n)
+		case pdfcpu.Float:
+			values[i] = float64(n)
+		default:
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+func textWidth(text, base_font string, font_size float64) float64 {
+	// pdfcpu's metrics take whole font sizes, scale from 1000 to keep the fraction
+	return font.TextWidth(text, base_font, 1000) * font_size / 1000
+}
+
+func formatNumber(n float64) string {
+	// Thousandths of a point are plenty, and keep 6.640000000000001 out of the content
+	return strconv.FormatFloat(math.Round(n*1000)/1000, 'f', -1, 64)
+}
+
+// WinAnsiEncoding puts these where Latin-1 has control characters
+var win_ansi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+func winAnsi(text string) string {
+	// The fonts we draw with are WinAnsi encoded, what they can't show becomes ?
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			encoded = append(encoded, byte(r))
+		case win_ansi[r] != 0:
+			encoded = append(encoded, win_ansi[r])
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return string(encoded)
+}
+
+func escapeLiteral(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return replacer.Replace(s)
+}
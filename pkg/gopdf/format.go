@@ -0,0 +1,227 @@
+package gopdf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+/*
+	Text fields of government and bank forms format their values with Acrobat's
+	JavaScript (AFDate_FormatEx("mm/dd/yyyy"), AFNumber_Format(2, 0, 0, 0, "$", true)...)
+	in their format (F) or keystroke (K) action. We don't run JavaScript, but reading
+	the call is enough to tell when a value obviously isn't a date or a number.
+*/
+
+// fieldFormat is what a field's format action declares its value to be
+type fieldFormat struct {
+	// "date" or "number"
+	kind string
+	// The date format, e.g. mm/dd/yyyy
+	date_format string
+	// What a number may have around it, like "$" or "EUR"
+	currency string
+}
+
+var (
+	af_date_ex     = regexp.MustCompile(`AFDate_(?:Format|Keystroke)Ex\s*\(\s*["']([^"']*)["']`)
+	af_date        = regexp.MustCompile(`AFDate_(?:Format|Keystroke)\s*\(\s*(\d+)`)
+	af_number      = regexp.MustCompile(`AF(?:Number|Percent)_(?:Format|Keystroke)\s*\(([^)]*)\)`)
+	af_date_tokens = regexp.MustCompile(`y+|m+|d+|H+|h+|M+|s+|t+|[^ymdHhMst]+`)
+)
+
+// The formats AFDate_Format(n) picks from by index, as Acrobat numbers them
+var af_date_formats = []string{"m/d", "m/d/yy", "mm/dd/yy", "mm/yy", "d-mmm", "d-mmm-yy", "dd-mmm-yy", "yy-mm-dd", "mmm-yy", "mmmm-yy", "mmm d, yyyy", "mmmm d, yyyy", "m/d/yy h:MM tt", "m/d/yy HH:MM"}
+
+func textFieldFormat(ctx *pdfcpu.Context, d pdfcpu.Dict) *fieldFormat {
+	// The format d's additional actions declare, nil for a field without a date or number one
+	aa, err := ctx.DereferenceDict(d["AA"])
+	if err != nil || aa == nil {
+		return nil
+	}
+	for _, trigger := range []string{"F", "K"} {
+		js := actionJavaScript(ctx, aa[trigger])
+		if js == "" {
+			continue
+		}
+		if match := af_date_ex.FindStringSubmatch(js); match != nil {
+			return &fieldFormat{kind: "date", date_format: match[1]}
+		}
+		if match := af_date.FindStringSubmatch(js); match != nil {
+			if i, err := strconv.Atoi(match[1]); err == nil && i < len(af_date_formats) {
+				return &fieldFormat{kind: "date", date_format: af_date_formats[i]}
+			}
+		}
+		if match := af_number.FindStringSubmatch(js); match != nil {
+			format := fieldFormat{kind: "number"}
+			// AFNumber_Format(nDec, sepStyle, negStyle, currStyle, strCurrency, bCurrencyPrepend)
+			args := strings.Split(match[1], ",")
+			if len(args) >= 5 {
+				format.currency = strings.Trim(strings.TrimSpace(args[4]), `"'`)
+			}
+			return &format
+		}
+	}
+	return nil
+}
+
+// checkFormat is fieldFormat.check of the text field d's format, nil when it has none
+func checkFormat(ctx *pdfcpu.Context, d pdfcpu.Dict, value interface{}) error {
+	format := textFieldFormat(ctx, d)
+	if format == nil {
+		return nil
+	}
+	return format.check(fmt.Sprintf("%v", value))
+}
+
+func actionJavaScript(ctx *pdfcpu.Context, o pdfcpu.Object) string {
+	// The JS of a JavaScript action, a string or a stream
+	action, err := ctx.DereferenceDict(o)
+	if err != nil || action == nil {
+		return ""
+	}
+	js, err := ctx.Dereference(action["JS"])
+	if err != nil || js == nil {
+		return ""
+	}
+	if sd, ok := js.(pdfcpu.StreamDict); ok {
+		if err := sd.Decode(); err != nil {
+			return ""
+		}
+		return string(sd.Content)
+	}
+	return textEntry(ctx, js)
+}
+
+func (format *fieldFormat) check(text string) error {
+	/*
+		An error when text obviously isn't what format declares, best effort: a number
+		is digits with separators, signs and its currency around them, a date has the
+		parts format lists in that order with months, days and times in range.
+		An empty value clears the field so it's always fine.
+	*/
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	switch format.kind {
+	case "number":
+		stripped := text
+		if format.currency != "" {
+			stripped = strings.ReplaceAll(stripped, format.currency, "")
+		}
+		has_digit := false
+		for _, r := range stripped {
+			switch {
+			case unicode.IsDigit(r):
+				has_digit = true
+			case unicode.IsSpace(r), strings.ContainsRune(".,'+-()%", r), unicode.Is(unicode.Sc, r):
+			default:
+				return fmt.Errorf("value %q isn't a number, the field is formatted as one", text)
+			}
+		}
+		if !has_digit {
+			return fmt.Errorf("value %q isn't a number, the field is formatted as one", text)
+		}
+	case "date":
+		if !format.matchesDate(text) {
+			return fmt.Errorf("value %q isn't a date in the field's format %s", text, format.date_format)
+		}
+	}
+	return nil
+}
+
+func (format *fieldFormat) matchesDate(text string) bool {
+	pattern := strings.Builder{}
+	pattern.WriteString(`^`)
+	// What each capture group is, to range check it
+	groups := make([]string, 0)
+	for _, token := range af_date_tokens.FindAllString(format.date_format, -1) {
+		switch {
+		case token[0] == 'y':
+			pattern.WriteString(`(\d{4}|\d{2})`)
+			groups = append(groups, "year")
+		case token[0] == 'm' && len(token) > 2:
+			pattern.WriteString(`(\pL+\.?)`)
+			groups = append(groups, "month_name")
+		case token[0] == 'm':
+			pattern.WriteString(`(\d{1,2})`)
+			groups = append(groups, "month")
+		case token[0] == 'd' && len(token) > 2:
+			pattern.WriteString(`(\pL+\.?)`)
+			groups = append(groups, "weekday")
+		case token[0] == 'd':
+			pattern.WriteString(`(\d{1,2})`)
+			groups = append(groups, "day")
+		case token[0] == 'H' || token[0] == 'h':
+			pattern.WriteString(`(\d{1,2})`)
+			groups = append(groups, "hour")
+		case token[0] == 'M' || token[0] == 's':
+			pattern.WriteString(`(\d{1,2})`)
+			groups = append(groups, "minute")
+		case token[0] == 't':
+			pattern.WriteString(`([AaPp]\.?[Mm]?\.?)`)
+			groups = append(groups, "am_pm")
+		default:
+			// Separators, people aren't careful with those
+			pattern.WriteString(`[\s/.,:-]*`)
+		}
+	}
+	pattern.WriteString(`$`)
+	date_pattern, err := regexp.Compile(pattern.String())
+	if err != nil {
+		// Not a format we understand, nothing to hold the value against
+		return true
+	}
+	match := date_pattern.FindStringSubmatch(text)
+	if match == nil {
+		return false
+	}
+	for i, group := range groups {
+		value := match[i+1]
+		n, _ := strconv.Atoi(value)
+		switch group {
+		case "month":
+			if n < 1 || n > 12 {
+				return false
+			}
+		case "day":
+			if n < 1 || n > 31 {
+				return false
+			}
+		case "hour":
+			if n > 23 {
+				return false
+			}
+		case "minute":
+			if n > 59 {
+				return false
+			}
+		case "month_name":
+			if !isMonthName(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var month_names = []string{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"}
+
+func isMonthName(name string) bool {
+	// English month names, whole or abbreviated to at least three letters
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if len(name) < 3 {
+		return false
+	}
+	for _, month := range month_names {
+		if strings.HasPrefix(month, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,397 @@
+package gopdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// How much of a form data file ReadFormData reads
+const form_data_max_bytes = 10 << 20
+
+// ReadFormData reads the field values of an FDF or XFDF file (as ExportForm writes them,
+// or Acrobat does) into a context for Generate, keyed by fully qualified field name.
+// Fields without a value are left out, a field with several values gets a list.
+func ReadFormData(in_file InputFile) (map[string]interface{}, error) {
+	f, err := in_file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, form_data_max_bytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > form_data_max_bytes {
+		return nil, fmt.Errorf("bigger than %d bytes", form_data_max_bytes)
+	}
+
+	context := make(map[string]interface{})
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("%FDF")):
+		err = readFDF(data, context)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		err = readXFDF(data, context)
+	default:
+		err = errors.New("not an FDF or XFDF file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return context, nil
+}
+
+// One <field> of an XFDF file, they nest like the fields in the PDF
+type xfdfField struct {
+	Name   string      `xml:"name,attr"`
+	Values []string    `xml:"value"`
+	Fields []xfdfField `xml:"field"`
+}
+
+func readXFDF(data []byte, context map[string]interface{}) error {
+	var doc struct {
+		XMLName xml.Name
+		Fields  []xfdfField `xml:"fields>field"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("XFDF: %v", err)
+	}
+	if doc.XMLName.Local != "xfdf" {
+		return fmt.Errorf("XFDF: root element is %s, not xfdf", doc.XMLName.Local)
+	}
+	var walk func(fields []xfdfField, parent_name string)
+	walk = func(fields []xfdfField, parent_name string) {
+		for _, field := range fields {
+			field_name := qualifiedName(parent_name, field.Name)
+			switch len(field.Values) {
+			case 0:
+			case 1:
+				context[field_name] = field.Values[0]
+			default:
+				values := make([]interface{}, len(field.Values))
+				for i, v := range field.Values {
+					values[i] = v
+				}
+				context[field_name] = values
+			}
+			walk(field.Fields, field_name)
+		}
+	}
+	walk(doc.Fields, "")
+	return nil
+}
+
+/*
+	FDF is PDF syntax without the xref table: the catalog has an FDF dict whose
+	Fields are the field dicts, each with its partial name T, a value V and its Kids.
+	We read every object of the file, only the ones the fields refer to matter.
+*/
+
+// An indirect reference to object nr
+type fdfRef int
+
+// A PDF name, strings are plain Go strings
+type fdfName string
+
+// fdfParser reads whole objects, with contentLexer doing strings, names and numbers
+type fdfParser struct {
+	contentLexer
+	// How many arrays and dicts the object being read is in, up to max_content_nesting
+	depth int
+}
+
+func readFDF(data []byte, context map[string]interface{}) error {
+	objects, trailer, err := parseFDF(data)
+	if err != nil {
+		return fmt.Errorf("FDF: %v", err)
+	}
+	resolve := func(o interface{}) interface{} {
+		// A ref to a ref is broken, don't follow more than a few
+		for i := 0; i < 8; i++ {
+			ref, ok := o.(fdfRef)
+			if !ok {
+				return o
+			}
+			o = objects[int(ref)]
+		}
+		return nil
+	}
+
+	// The catalog is the trailer's Root, files without a trailer have it as their first object
+	var fdf map[string]interface{}
+	if root, ok := resolve(trailer["Root"]).(map[string]interface{}); ok {
+		fdf, _ = resolve(root["FDF"]).(map[string]interface{})
+	}
+	if fdf == nil {
+		nrs := make([]int, 0, len(objects))
+		for nr := range objects {
+			nrs = append(nrs, nr)
+		}
+		sort.Ints(nrs)
+		for _, nr := range nrs {
+			if root, ok := objects[nr].(map[string]interface{}); ok && fdf == nil {
+				fdf, _ = resolve(root["FDF"]).(map[string]interface{})
+			}
+		}
+	}
+	if fdf == nil {
+		return errors.New("FDF: no FDF dict in the catalog")
+	}
+
+	visited := make(map[interface{}]bool)
+	var walk func(fields interface{}, parent_name string, depth int) error
+	walk = func(fields interface{}, parent_name string, depth int) error {
+		if depth > 32 {
+			return errors.New("FDF: fields nested too deep")
+		}
+		list, _ := resolve(fields).([]interface{})
+		for _, o := range list {
+			if ref, ok := o.(fdfRef); ok {
+				if visited[ref] {
+					continue
+				}
+				visited[ref] = true
+			}
+			d, ok := resolve(o).(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("FDF: field under %q is not a dict", parent_name)
+			}
+			field_name := parent_name
+			if t, ok := resolve(d["T"]).(string); ok {
+				field_name = qualifiedName(parent_name, t)
+			}
+			if value := importedValue(resolve(d["V"]), resolve); value != nil {
+				context[field_name] = value
+			}
+			if err := walk(d["Kids"], field_name, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(fdf["Fields"], "", 0)
+}
+
+func importedValue(o interface{}, resolve func(interface{}) interface{}) interface{} {
+	// Strings and names as text, multi select lists as a list of them
+	switch v := o.(type) {
+	case string:
+		return v
+	case fdfName:
+		return string(v)
+	case bool:
+		return v
+	case float64:
+		return v
+	case []interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			if value := importedValue(resolve(e), resolve); value != nil {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func qualifiedName(parent_name string, partial_name string) string {
+	if parent_name == "" {
+		return partial_name
+	}
+	return parent_name + "." + partial_name
+}
+
+func parseFDF(data []byte) (map[int]interface{}, map[string]interface{}, error) {
+	/*
+		Reads the file as a sequence of "nr gen obj ... endobj" objects and a trailer,
+		anything else at the top level (the header, xref tables, startxref) is skipped.
+	*/
+	p := &fdfParser{contentLexer: contentLexer{data: data}}
+	objects := make(map[int]interface{})
+	trailer := make(map[string]interface{})
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return objects, trailer, nil
+		}
+		start := p.pos
+		if p.keyword("trailer") {
+			o, err := p.parseObject()
+			if err != nil {
+				return nil, nil, err
+			}
+			if d, ok := o.(map[string]interface{}); ok {
+				trailer = d
+			}
+			continue
+		}
+		if nr, err := strconv.Atoi(p.word()); err == nil {
+			p.skipSpace()
+			_ = p.word() // generation
+			p.skipSpace()
+			if p.keyword("obj") {
+				o, err := p.parseObject()
+				if err != nil {
+					return nil, nil, err
+				}
+				objects[nr] = o
+				p.skipSpace()
+				if p.keyword("stream") {
+					// Embedded files and such, nothing a field value lives in
+					end := bytes.Index(p.data[p.pos:], []byte("endstream"))
+					if end < 0 {
+						return nil, nil, errors.New("stream without endstream")
+					}
+					p.pos += end + len("endstream")
+					p.skipSpace()
+				}
+				p.keyword("endobj")
+				continue
+			}
+		}
+		if p.pos == start {
+			// A delimiter we don't care about at the top level
+			p.pos++
+		}
+	}
+}
+
+func (p *fdfParser) keyword(keyword string) bool {
+	// Consumes keyword when it's next
+	end := p.pos + len(keyword)
+	if end > len(p.data) || string(p.data[p.pos:end]) != keyword {
+		return false
+	}
+	if end < len(p.data) && !isSpace(p.data[end]) && !isDelimiter(p.data[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *fdfParser) parseObject() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errors.New("unexpected end of file")
+	}
+	switch c := p.data[p.pos]; {
+	case c == '(':
+		return pdfText(p.literalString()), nil
+	case c == '<' && p.peek(1) == '<':
+		return p.parseDict()
+	case c == '<':
+		return pdfText(p.hexString()), nil
+	case c == '[':
+		return p.parseArray()
+	case c == '/':
+		p.pos++
+		return fdfName(decodeName(p.word())), nil
+	case isDelimiter(c):
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+	}
+
+	word := p.word()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected %q at offset %d", word, p.pos-len(word))
+	}
+	// "nr gen R" is a reference, look ahead without losing our place if it isn't one
+	after_number := p.pos
+	p.skipSpace()
+	if _, err := strconv.Atoi(p.word()); err == nil {
+		p.skipSpace()
+		if p.keyword("R") {
+			return fdfRef(int(n)), nil
+		}
+	}
+	p.pos = after_number
+	return n, nil
+}
+
+func (p *fdfParser) parseDict() (map[string]interface{}, error) {
+	if p.depth++; p.depth > max_content_nesting {
+		return nil, errContentNesting
+	}
+	defer func() { p.depth-- }()
+	p.pos += 2
+	d := make(map[string]interface{})
+	for {
+		p.skipSpace()
+		if p.peek(0) == '>' && p.peek(1) == '>' {
+			p.pos += 2
+			return d, nil
+		}
+		key, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(fdfName)
+		if !ok {
+			return nil, fmt.Errorf("dict key is not a name at offset %d", p.pos)
+		}
+		value, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		d[string(name)] = value
+	}
+}
+
+func (p *fdfParser) parseArray() ([]interface{}, error) {
+	if p.depth++; p.depth > max_content_nesting {
+		return nil, errContentNesting
+	}
+	defer func() { p.depth-- }()
+	p.pos++
+	array := make([]interface{}, 0)
+	for {
+		p.skipSpace()
+		if p.peek(0) == ']' {
+			p.pos++
+			return array, nil
+		}
+		o, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, o)
+	}
+}
+
+func pdfText(s contentString) string {
+	// UTF-16BE when it starts with the BOM, PDFDocEncoding otherwise which is Latin-1 for the printable part
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		return utf16Text(s[2:])
+	}
+	return latin1(s)
+}
+
+func decodeName(name string) string {
+	// #xx is the byte xx
+	var b []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if n, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(n))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, name[i])
+	}
+	return string(b)
+}
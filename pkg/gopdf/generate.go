@@ -0,0 +1,663 @@
+package gopdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// GenerateResult is what Generate did: per input file the fields it filled, left untouched
+// or had to skip because their value was rejected, and the keys of the context that matched no field.
+// Warnings are the values filled in anyway that don't look like their field's date or number format.
+type GenerateResult struct {
+	Filled      map[string][]string
+	Untouched   map[string][]string
+	FieldErrors map[string][]string
+	Warnings    map[string][]string
+	Missing     []string
+	// The keys of the context whose value the transforms changed, see GenerateOptions.Transforms
+	Transformed map[string]TransformedValue
+}
+
+// ErrFieldsNotFound is what Generate returns, wrapped with their names, when keys of the context match no field
+var ErrFieldsNotFound = errors.New("fields not found in any input file")
+
+// GenerateOptions changes what Generate does on top of filling the fields
+type GenerateOptions struct {
+	// Flatten draws the filled values into the pages and removes the form, so it can't be edited anymore
+	Flatten bool
+	// DryRun matches the context against the fields and reports it like a real run
+	// but stops there, nothing is flattened or written and out_dir and out are ignored
+	DryRun bool
+	// StrictFormats rejects values that don't look like their field's date or number format
+	// (see fieldFormat) instead of filling them in with a warning
+	StrictFormats bool
+	// TruncateToMaxLen cuts values longer than their text field's MaxLen down to it, with
+	// a warning, instead of rejecting them
+	TruncateToMaxLen bool
+	// Reproducible writes the output so the same input files and context always give the
+	// same bytes, with ReproducibleDate (ReproducibleEpoch when it's zero) as the Info dict
+	// dates. Encrypted output can't be, it's ErrReproducibleEncrypted.
+	Reproducible     bool
+	ReproducibleDate time.Time
+	// TabOrder is TabOrderPreserve (or empty) or TabOrderRows, see CheckTabOrder
+	TabOrder string
+	// Transforms are applied to the values of the context before they're filled in, by key,
+	// with the ones under "*" applied to every key first. See TransformNames.
+	Transforms map[string][]string
+}
+
+// Generate fills the AcroForms of input_files with the values in context and writes the result
+// to out_dir, or to out when out_dir is empty.
+func Generate(ctx context.Context, context map[string]interface{}, out_dir string, input_files []InputFile, out io.Writer, options GenerateOptions) (GenerateResult, error) {
+	/*
+		Fills a PDF's forms (acro form) with user information.
+
+		Every key of context is a field name (T) and its value is what ends up in V.
+		With a single input file out_dir is the output file path, with several input
+		files out_dir is a directory and each filled file keeps its base name, made
+		unique (see OutputNames).
+		When out_dir is empty the filled PDF is written to out instead, which only
+		works with a single input file.
+
+		The returned GenerateResult reports per input file which fields were filled, which
+		were left untouched and which were skipped because their value was rejected,
+		plus the keys of context that matched no field at all.
+		It is filled in even when we error out because of those missing keys.
+		The values are transformed by options.Transforms before anything is matched,
+		a value a transform can't be applied to fails the whole call with ErrTransform.
+
+		Reading and filling a file may take FileTimeout, a file that takes longer
+		fails with ErrTimeout. Once ctx is done we stop and return ctx.Err().
+	*/
+	result := GenerateResult{Filled: make(map[string][]string), Untouched: make(map[string][]string), FieldErrors: make(map[string][]string), Warnings: make(map[string][]string), Missing: make([]string, 0)}
+	if len(input_files) == 0 {
+		return result, errors.New("no input files provided")
+	}
+	if out_dir == "" && len(input_files) != 1 && !options.DryRun {
+		return result, errors.New("output_file is required when filling more than one input file")
+	}
+	context, transformed, err := transformContext(context, options.Transforms)
+	if err != nil {
+		return result, err
+	}
+	result.Transformed = transformed
+
+	// We fill every file first and only write once we know the mapping is good
+	contexts := make([]*pdfcpu.Context, len(input_files))
+	found_fields := make(map[string]bool)
+	for idx, in_file := range input_files {
+		in_file := in_file
+		filled_file, err := runFile(ctx, func() (filledFile, error) {
+			return fillFile(in_file, context, options)
+		})
+		if callDone(err) {
+			return result, err
+		}
+		if err != nil {
+			return result, &FileError{Idx: idx, Name: in_file.Name, Err: err}
+		}
+		filled, untouched, field_errors := filled_file.filled, filled_file.untouched, filled_file.field_errors
+		if len(filled_file.warnings) > 0 {
+			messages := make([]string, 0, len(filled_file.warnings))
+			for field_name, warning := range filled_file.warnings {
+				messages = append(messages, fmt.Sprintf("%s: %s", field_name, warning))
+			}
+			sort.Strings(messages)
+			result.Warnings[in_file.Name] = messages
+		}
+		for _, key := range filled_file.matched {
+			found_fields[key] = true
+		}
+		if len(field_errors) > 0 {
+			messages := make([]string, 0, len(field_errors))
+			for field_name, field_error := range field_errors {
+				messages = append(messages, fmt.Sprintf("%s: %s", field_name, field_error))
+			}
+			sort.Strings(messages)
+			result.FieldErrors[in_file.Name] = messages
+		}
+		result.Filled[in_file.Name] = filled
+		result.Untouched[in_file.Name] = untouched
+		contexts[idx] = filled_file.pdf_ctx
+	}
+
+	for field_name := range context {
+		if !found_fields[field_name] {
+			result.Missing = append(result.Missing, field_name)
+		}
+	}
+	if len(result.Missing) > 0 {
+		sort.Strings(result.Missing)
+		return result, fmt.Errorf("%w: %s", ErrFieldsNotFound, strings.Join(result.Missing, ", "))
+	}
+	if options.DryRun {
+		return result, nil
+	}
+
+	if options.Flatten {
+		for idx, pdf_ctx := range contexts {
+			err := flattenForm(pdf_ctx)
+			if err != nil {
+				return result, &FileError{Idx: idx, Name: input_files[idx].Name, Err: err}
+			}
+		}
+	} else if options.TabOrder == TabOrderRows {
+		for idx, pdf_ctx := range contexts {
+			err := arrangeTabOrder(pdf_ctx)
+			if err != nil {
+				return result, &FileError{Idx: idx, Name: input_files[idx].Name, Err: err}
+			}
+		}
+	}
+
+	// Nobody is waiting for the result anymore, don't write it
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	write := api.WriteContext
+	if options.Reproducible {
+		date := options.ReproducibleDate
+		if date.IsZero() {
+			date = ReproducibleEpoch
+		}
+		write = func(pdf_ctx *pdfcpu.Context, w io.Writer) error {
+			return writeReproducible(pdf_ctx, w, date)
+		}
+	}
+	if out_dir == "" {
+		return result, write(contexts[0], out)
+	}
+
+	out_names := OutputNames(input_files)
+	for idx, pdf_ctx := range contexts {
+		out_path := out_dir
+		if len(input_files) > 1 {
+			out_path = filepath.Join(out_dir, out_names[idx])
+		}
+		err := writeFile(out_path, func(w io.Writer) error {
+			return write(pdf_ctx, w)
+		})
+		if err != nil {
+			// Not the input file's fault, we couldn't write the result
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// OutputNames are the names Generate writes input_files under in out_dir when there are
+// several: their base names, a URL's without its query, with " (2)", " (3)"... added to
+// the ones another input file has already, so no file overwrites another.
+func OutputNames(input_files []InputFile) []string {
+	names := make([]string, len(input_files))
+	used := make(map[string]bool)
+	for idx, in_file := range input_files {
+		name := filepath.Base(in_file.Name)
+		if u, err := url.Parse(in_file.Name); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			name = path.Base(u.Path)
+		}
+		if name == "." || name == "/" || name == string(filepath.Separator) {
+			name = "input.pdf"
+		}
+		names[idx] = uniqueName(name, used)
+	}
+	return names
+}
+
+// filledFile is an input file read and filled by fillFile
+type filledFile struct {
+	pdf_ctx      *pdfcpu.Context
+	filled       []string
+	untouched    []string
+	field_errors map[string]string
+	warnings     map[string]string
+	// The context keys that named a field, whether or not its value was taken
+	matched []string
+}
+
+func fillFile(in_file InputFile, context map[string]interface{}, options GenerateOptions) (filledFile, error) {
+	pdf_ctx, err := readContextFile(in_file)
+	if err != nil {
+		return filledFile{}, err
+	}
+	filled_file, err := fillAcro(pdf_ctx, context, options)
+	if err != nil {
+		return filledFile{}, err
+	}
+	filled_file.pdf_ctx = pdf_ctx
+	return filled_file, nil
+}
+
+func fillAcro(ctx *pdfcpu.Context, context map[string]interface{}, options GenerateOptions) (filledFile, error) {
+	/*
+		Sets V for every field in the AcroForm whose name is a key of context. Keys are
+		fully qualified names ("address.zip"), a field can also go by its own partial name
+		("zip") as long as no other field in the file has that one too. A partial name
+		several fields end in is rejected with the candidates, it needs the qualified name.
+		Reports the fields we filled and the ones we left untouched, plus name -> error
+		for the values we had to reject and the partial names that were ambiguous.
+		Text values that are too long or don't fit their field's date or number format
+		are rejected or filled with a warning, depending on options (see fillText).
+		Signature fields are left untouched with a warning, only signing sets their V.
+		The returned error is for when the file itself is broken.
+	*/
+	filled_file := filledFile{filled: make([]string, 0), untouched: make([]string, 0), field_errors: make(map[string]string), warnings: make(map[string]string), matched: make([]string, 0)}
+
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return filledFile{}, err
+	}
+
+	acroform, ok := cat.Find("AcroForm")
+	if !ok {
+		// No forms in this file, nothing to fill
+		return filled_file, nil
+	}
+
+	adict, err := ctx.DereferenceDict(acroform)
+	if err != nil {
+		return filledFile{}, err
+	}
+
+	fields, err := ctx.DereferenceArray(adict["Fields"])
+	if err != nil {
+		return filledFile{}, err
+	}
+
+	type terminalField struct {
+		name string
+		d    pdfcpu.Dict
+	}
+	terminal_fields := make([]terminalField, 0)
+	by_partial_name := make(map[string][]string)
+	err = walkFields(ctx, fields, func(field_name string, d pdfcpu.Dict) error {
+		terminal_fields = append(terminal_fields, terminalField{field_name, d})
+		partial_name := field_name[strings.LastIndex(field_name, ".")+1:]
+		by_partial_name[partial_name] = append(by_partial_name[partial_name], field_name)
+		return nil
+	})
+	if err != nil {
+		return filledFile{}, err
+	}
+	if len(terminal_fields) == 0 && hasXFA(adict) {
+		return filledFile{}, ErrXFAForm
+	}
+
+	matched := make(map[string]bool)
+	for _, field := range terminal_fields {
+		field_name, d := field.name, field.d
+		key := field_name
+		value, ok := context[key]
+		partial_name := field_name[strings.LastIndex(field_name, ".")+1:]
+		_, has_partial := context[partial_name]
+		switch {
+		case partial_name == field_name || !has_partial:
+		case len(by_partial_name[partial_name]) > 1:
+			filled_file.field_errors[partial_name] = fmt.Sprintf("ambiguous, use one of the qualified names %s", strings.Join(by_partial_name[partial_name], ", "))
+			matched[partial_name] = true
+		case ok:
+			filled_file.field_errors[partial_name] = fmt.Sprintf("%s is given by its qualified name too, that value is used", field_name)
+			matched[partial_name] = true
+		default:
+			value, ok = context[partial_name]
+			key = partial_name
+		}
+		if !ok {
+			filled_file.untouched = append(filled_file.untouched, field_name)
+			continue
+		}
+		matched[key] = true
+		var err error
+		_, is_list := value.([]interface{})
+		// FT, like Ff, DA and Q, can be set on a parent field for all its kids
+		ft, _ := inheritedAttr(ctx, d, "FT").(pdfcpu.Name)
+		switch {
+		case ft == "Sig":
+			// A V on a signature field is a signature dict, a value there would pass for a broken signature
+			filled_file.warnings[field_name] = "signature fields can't be filled, the value is ignored"
+			filled_file.untouched = append(filled_file.untouched, field_name)
+			continue
+		case is_list && ft != "Ch":
+			// Only multi select list boxes have a list as their value, fillChoice checks those
+			err = errors.New("the field takes a single value, not a list")
+		case ft == "Btn":
+			err = fillButton(ctx, d, value)
+		case ft == "Ch":
+			err = fillChoice(ctx, d, value)
+		case ft == "Tx":
+			var warnings []string
+			warnings, err = fillText(ctx, adict, d, value, options)
+			if len(warnings) > 0 {
+				filled_file.warnings[field_name] = strings.Join(warnings, "; ")
+			}
+		default:
+			d.Update("V", textObject(valueText(value)))
+		}
+		if err != nil {
+			filled_file.field_errors[field_name] = err.Error()
+			continue
+		}
+		filled_file.filled = append(filled_file.filled, field_name)
+	}
+	for key := range matched {
+		filled_file.matched = append(filled_file.matched, key)
+	}
+	sort.Strings(filled_file.matched)
+
+	// Viewers should regenerate the appearance streams with the new values
+	adict["NeedAppearances"] = pdfcpu.Boolean(true)
+	return filled_file, nil
+}
+
+func fillText(ctx *pdfcpu.Context, adict pdfcpu.Dict, d pdfcpu.Dict, value interface{}, options GenerateOptions) ([]string, error) {
+	/*
+		Sets V of a text field. A value longer than the field's MaxLen is rejected, viewers
+		wouldn't show the rest, or cut down to it with options.TruncateToMaxLen. One that isn't
+		in the field's date or number format is filled in with a warning, or rejected with
+		options.StrictFormats. Returns the warnings.
+	*/
+	warnings := make([]string, 0)
+	text := valueText(value)
+	if max_len, _ := maxLen(ctx, d); max_len > 0 && utf8.RuneCountInString(text) > max_len {
+		if !options.TruncateToMaxLen {
+			return nil, fmt.Errorf("value is %d characters long, the field takes at most %d", utf8.RuneCountInString(text), max_len)
+		}
+		text = string([]rune(text)[:max_len])
+		warnings = append(warnings, fmt.Sprintf("value truncated to the field's %d characters", max_len))
+	}
+	format_err := checkFormat(ctx, d, text)
+	if format_err != nil && options.StrictFormats {
+		return nil, format_err
+	}
+	if format_err != nil {
+		warnings = append(warnings, format_err.Error())
+	}
+	err := fillDA(ctx, adict, d)
+	if err != nil {
+		return nil, err
+	}
+	d.Update("V", textObject(text))
+	return warnings, nil
+}
+
+// What viewers get told to draw a text field with when neither it nor the AcroForm has a DA
+const default_da = "/Helv 0 Tf 0 g"
+
+// The names Acrobat gives the standard fonts in DR, any other DA font we add is Helvetica
+var dr_core_fonts = map[string]string{
+	"Helv": "Helvetica",
+	"HeBo": "Helvetica-Bold",
+	"Cour": "Courier",
+	"CoBo": "Courier-Bold",
+	"TiRo": "Times-Roman",
+	"TiBo": "Times-Bold",
+	"Symb": "Symbol",
+	"ZaDb": "ZapfDingbats",
+}
+
+func fillDA(ctx *pdfcpu.Context, adict pdfcpu.Dict, d pdfcpu.Dict) error {
+	/*
+		Viewers draw the value of a text field with its DA (inherited from its parents or else
+		the AcroForm's), using the font DA names out of the AcroForm's DR. Without either the
+		filled value shows up in no font or not at all, so a field without a DA gets the
+		AcroForm's (or default_da) and a DA font missing from DR is added there as one of the
+		standard fonts (see dr_core_fonts).
+	*/
+	da := textEntry(ctx, inheritedAttr(ctx, d, "DA"))
+	if da == "" {
+		da = textEntry(ctx, adict["DA"])
+		if da == "" {
+			da = default_da
+		}
+		d["DA"] = pdfcpu.StringLiteral(da)
+	}
+	match := da_font.FindStringSubmatch(da)
+	if match == nil {
+		return nil
+	}
+	font_name := match[1]
+
+	dr, err := ctx.DereferenceDict(adict["DR"])
+	if err != nil {
+		return err
+	}
+	if dr == nil {
+		dr = pdfcpu.Dict{}
+		adict["DR"] = dr
+	}
+	fonts, err := ctx.DereferenceDict(dr["Font"])
+	if err != nil {
+		return err
+	}
+	if fonts == nil {
+		fonts = pdfcpu.Dict{}
+		dr["Font"] = fonts
+	}
+	if _, found := fonts.Find(font_name); found {
+		return nil
+	}
+	base_font, ok := dr_core_fonts[font_name]
+	if !ok {
+		base_font = "Helvetica"
+	}
+	font_ref, err := newCoreFont(ctx, base_font)
+	if err != nil {
+		return err
+	}
+	fonts[font_name] = *font_ref
+	return nil
+}
+
+func textEntry(ctx *pdfcpu.Context, o pdfcpu.Object) string {
+	// A string entry like DA as text, "" when it isn't there or isn't a string
+	if o == nil {
+		return ""
+	}
+	s, err := ctx.DereferenceText(o)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+func valueText(value interface{}) string {
+	/*
+		A context value as the text it's filled in as. JSON numbers are float64s, which %v
+		writes in exponent form from 7 digits on (1234567 is "1.234567e+06"), so they're
+		written out in full with as many decimals as they have.
+	*/
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func textObject(s string) pdfcpu.Object {
+	/*
+		s as a PDF text string. pdfcpu writes literals as they are, so ( ) and \ have to be
+		escaped or they end the string early. Text that's all Latin-1 (minus its control
+		characters, PDFDocEncoding has other ones there) is a literal of those bytes,
+		anything else is UTF-16BE with a BOM in a hex string.
+	*/
+	latin1 := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r >= 0x100 || (r >= 0x80 && r < 0xA0) {
+			return pdfcpu.NewHexLiteral([]byte(pdfcpu.EncodeUTF16String(s)))
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	return pdfcpu.StringLiteral(escapeLiteral(string(latin1)))
+}
+
+func fillChoice(ctx *pdfcpu.Context, d pdfcpu.Dict, value interface{}) error {
+	/*
+		A choice field only displays values from its Opt list, anything else renders blank.
+		The value may be given as the export or the display value, V always gets the export value.
+		Combo boxes with the Edit flag let the user type their own value so those take anything.
+		Multi select list boxes also take a list of values, V is then the list of their export
+		values in Opt order. List boxes get I too, the indices of the selected options, which
+		viewers go by when several options have the same export value.
+	*/
+	ff := 0
+	if i, ok := inheritedAttr(ctx, d, "Ff").(pdfcpu.Integer); ok {
+		ff = i.Value()
+	}
+	is_combo := ff&(1<<17) > 0
+	is_editable := ff&(1<<18) > 0
+	is_multi_select := !is_combo && ff&(1<<21) > 0
+
+	values, is_list := value.([]interface{})
+	if is_list && !is_multi_select {
+		return errors.New("the field takes a single value, not a list, only multi select list boxes take several")
+	}
+	if !is_list {
+		values = []interface{}{value}
+	}
+
+	choices := choiceOptions(ctx, d["Opt"])
+	selected := make([]bool, len(choices))
+	for _, v := range values {
+		switch v.(type) {
+		case []interface{}, map[string]interface{}:
+			return errors.New("the values in the list must be strings or numbers")
+		}
+		text := valueText(v)
+		found := false
+		for i, choice := range choices {
+			if text == choice.Export || text == choice.Display {
+				selected[i] = true
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		if !is_list && is_combo && is_editable {
+			d.Update("V", textObject(text))
+			return nil
+		}
+		options := make([]string, len(choices))
+		for i, choice := range choices {
+			options[i] = choice.Export
+		}
+		return fmt.Errorf("value %q is not one of the options %s", text, strings.Join(options, ", "))
+	}
+
+	export_values := make(pdfcpu.Array, 0, len(values))
+	indices := make(pdfcpu.Array, 0, len(values))
+	for i, choice := range choices {
+		if selected[i] {
+			export_values = append(export_values, textObject(choice.Export))
+			indices = append(indices, pdfcpu.Integer(i))
+		}
+	}
+	if is_list {
+		d.Update("V", export_values)
+	} else {
+		d.Update("V", export_values[0])
+	}
+	if !is_combo {
+		d.Update("I", indices)
+	}
+	return nil
+}
+
+func fillButton(ctx *pdfcpu.Context, d pdfcpu.Dict, value interface{}) error {
+	/*
+		Checkboxes and radio buttons don't take free text, V (on the field) and AS (on every widget)
+		must name one of the appearance states in the widget's /AP /N dict, or be Off.
+		The on state is whatever the PDF author called it ("Yes", "On", "1", "Ja"...) so we read
+		it from the widgets instead of assuming. For a checkbox true/"yes"/"on" picks its on state,
+		for a radio group the value has to be the name of the state of the button to select.
+	*/
+	ff, _ := inheritedAttr(ctx, d, "Ff").(pdfcpu.Integer)
+	if ff&(1<<16) > 0 {
+		return errors.New("push buttons have no value to fill")
+	}
+
+	// The field can be its own widget, otherwise the widgets are its kids
+	widgets := []pdfcpu.Dict{d}
+	kids, err := ctx.DereferenceArray(d["Kids"])
+	if err != nil {
+		return err
+	}
+	if len(kids) > 0 {
+		widgets = make([]pdfcpu.Dict, 0, len(kids))
+		for _, o := range kids {
+			kid, err := ctx.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+			if kid != nil {
+				widgets = append(widgets, kid)
+			}
+		}
+	}
+
+	widget_states := make([]string, len(widgets))
+	on_states := make([]string, 0)
+	for i, widget := range widgets {
+		widget_states[i], err = onState(ctx, widget)
+		if err != nil {
+			return err
+		}
+		if widget_states[i] != "" && !containsString(on_states, widget_states[i]) {
+			on_states = append(on_states, widget_states[i])
+		}
+	}
+
+	state := ""
+	text := strings.TrimSpace(valueText(value))
+	switch {
+	case containsString(on_states, text):
+		state = text
+	case value == false || value == nil || text == "" || strings.EqualFold(text, "off") || strings.EqualFold(text, "no") || strings.EqualFold(text, "false"):
+		state = "Off"
+	case len(on_states) == 1 && (value == true || strings.EqualFold(text, "on") || strings.EqualFold(text, "yes") || strings.EqualFold(text, "true")):
+		state = on_states[0]
+	default:
+		return fmt.Errorf("value %q is not one of the states %s (or Off)", text, strings.Join(on_states, ", "))
+	}
+
+	d.Update("V", pdfcpu.Name(state))
+	for i, widget := range widgets {
+		if widget_states[i] == state {
+			widget.Update("AS", pdfcpu.Name(state))
+		} else {
+			widget.Update("AS", pdfcpu.Name("Off"))
+		}
+	}
+	return nil
+}
+
+func onState(ctx *pdfcpu.Context, widget pdfcpu.Dict) (string, error) {
+	// The on state of a button widget is the key of its normal appearance dict that isn't Off
+	ap, err := ctx.DereferenceDict(widget["AP"])
+	if err != nil || ap == nil {
+		return "", err
+	}
+	n, err := ctx.DereferenceDict(ap["N"])
+	if err != nil || n == nil {
+		return "", err
+	}
+	for state := range n {
+		if state != "Off" {
+			return state, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,233 @@
+package gopdf
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+func TestFillSignatureFieldSkipped(t *testing.T) {
+	content := formPDF(2,
+		"<< /Type /Annot /Subtype /Widget /FT /Sig /T (signature) /Rect [72 100 272 140] >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>",
+	)
+	result, ctx := generateBytes(t, content, map[string]interface{}{"signature": "John Doe", "name": "John Doe"}, GenerateOptions{})
+	if v, found := fieldDict(t, ctx, "signature")["V"]; found {
+		t.Errorf("signature field got V %v", v)
+	}
+	if !reflect.DeepEqual(result.Filled["form.pdf"], []string{"name"}) || !reflect.DeepEqual(result.Untouched["form.pdf"], []string{"signature"}) {
+		t.Errorf("got filled %v and untouched %v", result.Filled, result.Untouched)
+	}
+	warnings := result.Warnings["form.pdf"]
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "signature: ") {
+		t.Errorf("got warnings %v, want one for the signature field", warnings)
+	}
+	if len(result.Missing) != 0 {
+		t.Errorf("got missing %v", result.Missing)
+	}
+}
+
+func TestFillCheckboxOnState(t *testing.T) {
+	// The on state is the name the author gave it in the appearance dict, not Yes
+	content := formPDF(1,
+		"<< /Type /Annot /Subtype /Widget /FT /Btn /T (agree) /Rect [72 560 84 572] /V /Off /AS /Off /AP << /N << /Ja 6 0 R /Off 6 0 R >> >> >>",
+		pdfStream("<< /Type /XObject /Subtype /Form /BBox [0 0 12 12]", "0 0 m 12 12 l S"),
+	)
+	for _, value := range []interface{}{true, "yes", "on", "Ja"} {
+		result, ctx := generateBytes(t, content, map[string]interface{}{"agree": value}, GenerateOptions{})
+		d := fieldDict(t, ctx, "agree")
+		if d["V"] != pdfcpu.Name("Ja") || d["AS"] != pdfcpu.Name("Ja") {
+			t.Errorf("%v: got V %v and AS %v, want Ja", value, d["V"], d["AS"])
+		}
+		if len(result.FieldErrors) != 0 {
+			t.Errorf("%v: got field errors %v", value, result.FieldErrors)
+		}
+	}
+	result, ctx := generateBytes(t, content, map[string]interface{}{"agree": false}, GenerateOptions{})
+	if d := fieldDict(t, ctx, "agree"); d["V"] != pdfcpu.Name("Off") || d["AS"] != pdfcpu.Name("Off") {
+		t.Errorf("false: got V %v and AS %v, want Off", d["V"], d["AS"])
+	}
+	if len(result.FieldErrors) != 0 {
+		t.Errorf("false: got field errors %v", result.FieldErrors)
+	}
+	result, _ = generateBytes(t, content, map[string]interface{}{"agree": "Nein"}, GenerateOptions{})
+	if errors := result.FieldErrors["form.pdf"]; len(errors) != 1 || !strings.Contains(errors[0], "Ja") {
+		t.Errorf("Nein: got field errors %v, want one naming the Ja state", result.FieldErrors)
+	}
+}
+
+func TestOutputNames(t *testing.T) {
+	input_files := []InputFile{
+		BytesFile("/forms/a/form.pdf", nil),
+		BytesFile("/forms/b/form.pdf", nil),
+		BytesFile("https://example.com/templates/form.pdf?token=secret#page=2", nil),
+		BytesFile("https://example.com/download?id=7", nil),
+		BytesFile("https://example.com/", nil),
+		BytesFile("upload.pdf", nil),
+	}
+	want := []string{"form.pdf", "form (2).pdf", "form (3).pdf", "download", "input.pdf", "upload.pdf"}
+	if got := OutputNames(input_files); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGenerateSameBaseNames(t *testing.T) {
+	// Two input files with the same base name each get a file of their own
+	first := formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>")
+	second := formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] /MaxLen 40 >>")
+	out_dir := t.TempDir()
+	input_files := []InputFile{BytesFile("/a/form.pdf", first), BytesFile("/b/form.pdf", second)}
+	_, err := Generate(context.Background(), map[string]interface{}{"name": "Jane"}, out_dir, input_files, nil, GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx, name := range []string{"form.pdf", "form (2).pdf"} {
+		ctx, err := api.ReadContextFile(filepath.Join(out_dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Only the second one has a MaxLen
+		if _, found := fieldDict(t, ctx, "name")["MaxLen"]; found != (idx == 1) {
+			t.Errorf("%s isn't the filled %s", name, input_files[idx].Name)
+		}
+	}
+}
+
+func drFont(t *testing.T, ctx *pdfcpu.Context, font_name string) pdfcpu.Dict {
+	// The font of the AcroForm's DR under font_name, nil if there's none
+	t.Helper()
+	cat, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dr, err := ctx.DereferenceDict(adict["DR"])
+	if err != nil || dr == nil {
+		return nil
+	}
+	fonts, err := ctx.DereferenceDict(dr["Font"])
+	if err != nil || fonts == nil {
+		return nil
+	}
+	font, err := ctx.DereferenceDict(fonts[font_name])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestFillDA(t *testing.T) {
+	field := "<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>"
+	for _, test := range []struct {
+		what      string
+		content   []byte
+		da        string
+		font_name string
+		base_font string
+	}{
+		{"the AcroForm's DA", formPDF(1, field), "/Helv 0 Tf 0 g", "Helv", "Helvetica"},
+		{"no DA at all", buildPDF(1,
+			"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R] >> >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+			field,
+		), default_da, "Helv", "Helvetica"},
+		{"the field's own DA", formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] /DA (/TiBo 12 Tf 0 0 1 rg) >>"), "/TiBo 12 Tf 0 0 1 rg", "TiBo", "Times-Bold"},
+		{"a font that isn't a standard one", formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] /DA (/F1 10 Tf 0 g) >>"), "/F1 10 Tf 0 g", "F1", "Helvetica"},
+	} {
+		_, ctx := generateBytes(t, test.content, map[string]interface{}{"name": "Jane"}, GenerateOptions{})
+		if da := textEntry(ctx, fieldDict(t, ctx, "name")["DA"]); da != test.da {
+			t.Errorf("%s: field has DA %q, want %q", test.what, da, test.da)
+		}
+		font := drFont(t, ctx, test.font_name)
+		if font == nil {
+			t.Errorf("%s: DR has no font %s", test.what, test.font_name)
+		} else if font["BaseFont"] != pdfcpu.Name(test.base_font) {
+			t.Errorf("%s: DR font %s is %v, want %s", test.what, test.font_name, font["BaseFont"], test.base_font)
+		}
+	}
+}
+
+func TestFillDAKeepsDRFont(t *testing.T) {
+	// A font DR already has is the author's, it isn't replaced by a standard one
+	content := buildPDF(1,
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R] /DA (/Helv 0 Tf 0 g) /DR << /Font << /Helv 5 0 R >> >> >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	)
+	_, ctx := generateBytes(t, content, map[string]interface{}{"name": "Jane"}, GenerateOptions{})
+	if font := drFont(t, ctx, "Helv"); font == nil || font["BaseFont"] != pdfcpu.Name("Courier") {
+		t.Errorf("DR font Helv is %v, want the Courier that was there", font)
+	}
+}
+
+func TestTextObject(t *testing.T) {
+	for s, want := range map[string]pdfcpu.Object{
+		"Jane":          pdfcpu.StringLiteral("Jane"),
+		"O'Brien (Jr.)": pdfcpu.StringLiteral(`O'Brien \(Jr.\)`),
+		`C:\forms\`:     pdfcpu.StringLiteral(`C:\\forms\\`),
+		"Müller":        pdfcpu.StringLiteral("M\xfcller"),
+		"名前":            pdfcpu.HexLiteral("feff540d524d"),
+		"5 €":           pdfcpu.HexLiteral("feff0035002020ac"),
+	} {
+		if got := textObject(s); got != want {
+			t.Errorf("%q: got %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestFillTextRoundTrip(t *testing.T) {
+	// What's filled in is what a viewer, and Scrape, read back out of the written file
+	content := formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>")
+	for _, value := range []string{"O'Brien (Jr.)", "名前", "unbalanced ( and )) parens", `back\slash`, "Müller", "5 € ✓"} {
+		var out bytes.Buffer
+		if _, err := Generate(context.Background(), map[string]interface{}{"name": value}, "", []InputFile{BytesFile("form.pdf", content)}, &out, GenerateOptions{}); err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		ctx, err := api.ReadContext(bytes.NewReader(out.Bytes()), nil)
+		if err != nil {
+			t.Fatalf("%q: reading the filled file: %v", value, err)
+		}
+		if got := textEntry(ctx, fieldDict(t, ctx, "name")["V"]); got != value {
+			t.Errorf("%q: V reads back as %q", value, got)
+		}
+		fields, err := getAcro(bytes.NewReader(out.Bytes()), nil)
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		if len(fields) != 1 || fields[0].Value != value {
+			t.Errorf("%q: scraped %+v", value, fields)
+		}
+	}
+}
+
+func TestFillNumberValues(t *testing.T) {
+	// JSON numbers come in as float64s, big ones aren't filled in exponent form
+	content := formPDF(2,
+		`<< /Type /Annot /Subtype /Widget /FT /Tx /T (amount) /Rect [72 600 272 620] /AA << /F << /S /JavaScript /JS (AFNumber_Format\(2, 0, 0, 0, "", true\);) >> >> >>`,
+		"<< /Type /Annot /Subtype /Widget /FT /Ch /T (account) /Rect [72 560 272 580] /Ff 131072 /Opt [(1234567) (7654321)] >>",
+	)
+	for value, want := range map[float64]string{1234567: "1234567", 1234567.25: "1234567.25", 0.00001: "0.00001", 12345678901234: "12345678901234"} {
+		result, ctx := generateBytes(t, content, map[string]interface{}{"amount": value, "account": 1234567.0}, GenerateOptions{StrictFormats: true})
+		if got := textEntry(ctx, fieldDict(t, ctx, "amount")["V"]); got != want {
+			t.Errorf("%v: filled in as %q, want %q", value, got, want)
+		}
+		if got := textEntry(ctx, fieldDict(t, ctx, "account")["V"]); got != "1234567" {
+			t.Errorf("%v: account is %q, want the option 1234567", value, got)
+		}
+		if len(result.Warnings) != 0 || len(result.FieldErrors) != 0 {
+			t.Errorf("%v: got warnings %v and field errors %v", value, result.Warnings, result.FieldErrors)
+		}
+	}
+}
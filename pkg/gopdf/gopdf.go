@@ -0,0 +1,201 @@
+// Package gopdf has the PDF side of the server: scraping and filling AcroForms,
+// merging and validating files. None of it knows about HTTP, so it can be used
+// from any Go program, the gin handlers in package main are thin wrappers around it.
+//
+// Every function takes its PDFs as InputFiles so callers can hand in paths
+// (PathFile) or anything else that opens to an io.ReadSeeker, like uploads.
+package gopdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+func init() {
+	// pdfcpu loads its config.yml the first time a default configuration is asked for,
+	// which every api call with a nil one does too. Loaded here, before any of them,
+	// concurrent requests don't race to load it.
+	pdfcpu.NewDefaultConfiguration()
+}
+
+// InputFile is a PDF to process, Open is called every time we need to read it
+type InputFile struct {
+	Name string
+	Open func() (File, error)
+	// Password opens the file when it is encrypted, user or owner password
+	Password string
+	// How strictly the file is checked before it's read
+	Validation ValidationOptions
+	// The path of a PathFile, what the template cache knows it by
+	path string
+}
+
+// configuration is the pdfcpu configuration to read the file with, nil when there's no password
+// and the validation is the default one
+func (in_file InputFile) configuration() *pdfcpu.Configuration {
+	if in_file.Password == "" && (in_file.Validation.Mode == "" || in_file.Validation.Mode == ValidationRelaxed) {
+		return nil
+	}
+	conf := pdfcpu.NewDefaultConfiguration()
+	if in_file.Password != "" {
+		conf.UserPW = in_file.Password
+		conf.OwnerPW = in_file.Password
+	}
+	switch in_file.Validation.Mode {
+	case ValidationStrict:
+		conf.ValidationMode = pdfcpu.ValidationStrict
+	case ValidationNone:
+		// pdfcpu's api calls skip their own validation of the file too then
+		conf.ValidationMode = pdfcpu.ValidationNone
+	}
+	return conf
+}
+
+// File is what an InputFile opens to, both *os.File and multipart.File are one of these
+type File interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// PathFile is an InputFile for a path on the local filesystem
+func PathFile(path string) InputFile {
+	return InputFile{Name: path, path: path, Open: func() (File, error) {
+		return os.Open(path)
+	}}
+}
+
+// BytesFile is an InputFile for a PDF that's in memory already, like one fetched from a URL
+func BytesFile(name string, content []byte) InputFile {
+	return InputFile{Name: name, Open: func() (File, error) {
+		return bytesFile{bytes.NewReader(content)}, nil
+	}}
+}
+
+// FileError is what went wrong with one of the input files
+type FileError struct {
+	Idx  int
+	Name string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s (idx: %d): %v", e.Name, e.Idx, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FileErrors holds a FileError for every input file that failed
+type FileErrors []*FileError
+
+func (e FileErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, file_error := range e {
+		messages[i] = file_error.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func readContextFile(in_file InputFile) (*pdfcpu.Context, error) {
+	f, err := openValidated(in_file)
+	if err != nil {
+		return nil, err
+	}
+	//Close the file this ain't python!
+	defer f.Close()
+	return api.ReadContext(f, in_file.configuration())
+}
+
+func transformFile(in_file InputFile, out_path string, out io.Writer, transform func(rs io.ReadSeeker, w io.Writer) error) error {
+	/*
+		Runs a pdfcpu api call that reads in_file and writes the result to out_path.
+		The result goes to a temp file next to out_path first, so out_path can be in_file
+		and a failed call doesn't leave half a PDF behind. in_file is validated as its
+		Validation says first.
+		With an empty out_path the result is written straight to out instead.
+		Anything wrong with in_file, including the call failing on it, is a FileError.
+	*/
+	f, err := openValidated(in_file)
+	if err != nil {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	//Close the file this ain't python!
+	defer f.Close()
+
+	if out_path == "" {
+		err = transform(f, out)
+		if err != nil {
+			return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+		}
+		return nil
+	}
+
+	transform_failed := false
+	err = writeFile(out_path, func(w io.Writer) error {
+		err := transform(f, w)
+		transform_failed = err != nil
+		return err
+	})
+	if transform_failed {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	return err
+}
+
+func writeContextFile(ctx *pdfcpu.Context, out_path string) error {
+	return writeFile(out_path, func(w io.Writer) error {
+		return api.WriteContext(ctx, w)
+	})
+}
+
+func writeFile(out_path string, write func(w io.Writer) error) error {
+	/*
+		Writes out_path atomically: write goes to a temp file next to it, which only
+		replaces out_path once it's complete. A failed write, or a crash halfway, never
+		leaves half a PDF at out_path and whatever was there before stays untouched.
+	*/
+	out_dir, out_name := filepath.Split(out_path)
+	if out_dir == "" {
+		out_dir = "."
+	}
+	tmp, err := createTemp(out_dir, "."+out_name+".*")
+	if err != nil {
+		return err
+	}
+	defer removeTemp(tmp.Name())
+
+	err = write(tmp)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	// TempFile makes it 0600, the other PDFs we write are 0644
+	tmp.Chmod(0644)
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), out_path)
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package gopdf
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checkUnchanged(t *testing.T, what string, out_path string, want []byte) {
+	// Fails t unless out_path still holds want and nothing was left next to it
+	t.Helper()
+	got, err := os.ReadFile(out_path)
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s: out_path changed to %q", what, got)
+	}
+	entries, err := os.ReadDir(filepath.Dir(out_path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%s: %d files next to out_path, the temp file wasn't removed", what, len(entries)-1)
+	}
+}
+
+func TestWriteFileFailureKeepsDestination(t *testing.T) {
+	original := []byte("the PDF that was there before")
+	out_path := writeTestFile(t, "out.pdf", original)
+	broken := errors.New("disk full")
+	err := writeFile(out_path, func(w io.Writer) error {
+		w.Write([]byte("%PDF-1.7\nhalf a "))
+		return broken
+	})
+	if !errors.Is(err, broken) {
+		t.Fatalf("got %v, want the write's error", err)
+	}
+	checkUnchanged(t, "failed write", out_path, original)
+
+	_, err = Generate(context.Background(), map[string]interface{}{"name": "Jane"}, out_path, []InputFile{BytesFile("broken.pdf", []byte("%PDF-1.7\nnot a PDF after all"))}, nil, GenerateOptions{})
+	if err == nil {
+		t.Fatal("generate on a broken file didn't fail")
+	}
+	checkUnchanged(t, "failed generate", out_path, original)
+}
+
+func TestWriteFileUnwritableDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root writes to read only directories")
+	}
+	original := []byte("the PDF that was there before")
+	out_path := writeTestFile(t, "out.pdf", original)
+	out_dir := filepath.Dir(out_path)
+	if err := os.Chmod(out_dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(out_dir, 0755)
+	if err := writeFile(out_path, func(w io.Writer) error {
+		_, err := w.Write([]byte("%PDF-1.7\n"))
+		return err
+	}); err == nil {
+		t.Fatal("writing to a read only directory didn't fail")
+	}
+	checkUnchanged(t, "read only directory", out_path, original)
+}
+
+func TestWriteFileRenameFailure(t *testing.T) {
+	// A directory at out_path can't be renamed over, the last step fails after a complete write
+	out_path := filepath.Join(t.TempDir(), "out.pdf")
+	if err := os.Mkdir(out_path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out_path, "keep"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(out_path, func(w io.Writer) error {
+		_, err := w.Write([]byte("%PDF-1.7\n"))
+		return err
+	}); err == nil {
+		t.Fatal("renaming over a directory didn't fail")
+	}
+	checkUnchanged(t, "failed rename", filepath.Join(out_path, "keep"), nil)
+	if entries, _ := os.ReadDir(filepath.Dir(out_path)); len(entries) != 1 {
+		t.Errorf("%d files next to out_path, the temp file wasn't removed", len(entries)-1)
+	}
+}
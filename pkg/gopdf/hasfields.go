@@ -0,0 +1,75 @@
+package gopdf
+
+import (
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// FieldPresence is what HasFields reports for every name it's asked about
+type FieldPresence struct {
+	Name   string `json:"name"`
+	Exists bool   `json:"exists"`
+	// FT of the field (Tx, Btn, Ch or Sig) when it exists
+	Type string `json:"type,omitempty"`
+	// Field names are case sensitive, so "Name" isn't found when the form has "name".
+	// The fields that are only different in case are listed here so that's easy to spot.
+	CaseMismatch []string `json:"case_mismatch,omitempty"`
+}
+
+// HasFields says for every name in names whether in_file has a field with that fully
+// qualified name, and its type when it does. Unlike Scrape no values or widgets are read.
+// A file without a form has none of them, an XFA only form is ErrXFAForm.
+func HasFields(in_file InputFile, names []string) ([]FieldPresence, error) {
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return nil, err
+	}
+	file_error := func(err error) error {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return nil, file_error(err)
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil {
+		return nil, file_error(err)
+	}
+
+	// The type of every field, and the names of the fields under their lower case name
+	types := make(map[string]string)
+	folded := make(map[string][]string)
+	if adict != nil {
+		fields, err := ctx.DereferenceArray(adict["Fields"])
+		if err != nil {
+			return nil, file_error(err)
+		}
+		err = walkFields(ctx, fields, func(field_name string, d pdfcpu.Dict) error {
+			if ft, ok := inheritedAttr(ctx, d, "FT").(pdfcpu.Name); ok {
+				types[field_name] = string(ft)
+			}
+			lower := strings.ToLower(field_name)
+			folded[lower] = append(folded[lower], field_name)
+			return nil
+		})
+		if err != nil {
+			return nil, file_error(err)
+		}
+		if len(types) == 0 && hasXFA(adict) {
+			return nil, file_error(ErrXFAForm)
+		}
+	}
+
+	results := make([]FieldPresence, len(names))
+	for i, name := range names {
+		results[i] = FieldPresence{Name: name}
+		if field_type, found := types[name]; found {
+			results[i].Exists = true
+			results[i].Type = field_type
+			continue
+		}
+		results[i].CaseMismatch = folded[strings.ToLower(name)]
+	}
+	return results, nil
+}
@@ -0,0 +1,94 @@
+package gopdf
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// PageImages are the image files ExtractImages wrote for one page
+type PageImages struct {
+	Page   int      `json:"page"`
+	Images []string `json:"images"`
+}
+
+// ExtractImages writes the images embedded in the selected pages of every input file
+// to out_dir, as <file>_<page>_<object number>.<type>, and reports them per file and page.
+// pages uses pdfcpu's page selection syntax (e.g. "1-3,5"), empty means every page.
+// Pages without images are left out, a file without any gets an empty list.
+func ExtractImages(input_files []InputFile, out_dir string, pages string) (map[string][]PageImages, error) {
+	var page_selection []string
+	if pages != "" {
+		var err error
+		page_selection, err = api.ParsePageSelection(pages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	images := make(map[string][]PageImages)
+	file_errors := make(FileErrors, 0)
+	for idx, in_file := range input_files {
+		file_images, err := extractFileImages(in_file, out_dir, page_selection)
+		if err != nil {
+			file_errors = append(file_errors, &FileError{Idx: idx, Name: in_file.Name, Err: err})
+			continue
+		}
+		images[in_file.Name] = file_images
+	}
+	if len(file_errors) > 0 {
+		return images, file_errors
+	}
+	return images, nil
+}
+
+func extractFileImages(in_file InputFile, out_dir string, page_selection []string) ([]PageImages, error) {
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return nil, err
+	}
+	// The images of every page are only collected by the optimizer
+	if err = api.OptimizeContext(ctx); err != nil {
+		return nil, err
+	}
+	page_numbers, err := selectedPageNumbers(ctx, page_selection)
+	if err != nil {
+		return nil, err
+	}
+
+	base_name := strings.TrimSuffix(filepath.Base(in_file.Name), filepath.Ext(in_file.Name))
+	file_images := make([]PageImages, 0)
+	for _, page_nr := range page_numbers {
+		// Resource names like Im0 are only unique within a page's resources, the object number is unique in the file
+		obj_nrs := ctx.ImageObjNrs(page_nr)
+		sort.Ints(obj_nrs)
+		written := PageImages{Page: page_nr, Images: make([]string, 0, len(obj_nrs))}
+		for _, obj_nr := range obj_nrs {
+			image_obj := ctx.Optimize.ImageObjects[obj_nr]
+			img, err := ctx.ExtractImage(image_obj.ImageDict, false, image_obj.ResourceNames[0], obj_nr, false)
+			if err != nil {
+				return nil, err
+			}
+			if img == nil {
+				continue
+			}
+			out_path := filepath.Join(out_dir, fmt.Sprintf("%s_%d_%d.%s", base_name, page_nr, obj_nr, img.FileType))
+			err = writeFile(out_path, func(w io.Writer) error {
+				_, err := io.Copy(w, img)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			written.Images = append(written.Images, out_path)
+		}
+		if len(written.Images) > 0 {
+			file_images = append(file_images, written)
+		}
+	}
+	return file_images, nil
+}
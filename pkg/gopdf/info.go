@@ -0,0 +1,116 @@
+package gopdf
+
+import (
+	"errors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// FileInfo is what Info reports for every input file
+type FileInfo struct {
+	File        string `json:"file"`
+	PageCount   int    `json:"page_count"`
+	Version     string `json:"version,omitempty"`
+	Encrypted   bool   `json:"encrypted"`
+	HasAcroForm bool   `json:"has_acroform"`
+	// The form is XFA, when it's XFA only scraping and filling it fails with ErrXFAForm
+	HasXFA bool `json:"has_xfa"`
+	// The signature fields of the form, signed or not
+	Signatures []Signature `json:"signatures,omitempty"`
+	// Laid out for fast web view, pdfcpu can't write those so any file we write isn't
+	Linearized bool `json:"linearized"`
+	// Size of the first page in points, as it's displayed (rotation applied)
+	PageWidth  float64 `json:"page_width,omitempty"`
+	PageHeight float64 `json:"page_height,omitempty"`
+	// From the document Info dict
+	Title   string `json:"title,omitempty"`
+	Author  string `json:"author,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Info reads the basics of every input file: page count, PDF version, encryption,
+// whether there is a form, its signature fields, the first page size and title, author and subject.
+// A file that can't be read is a result with Error set, an encrypted one without
+// its password still reports Encrypted.
+func Info(input_files []InputFile) []FileInfo {
+	results := make([]FileInfo, len(input_files))
+	for idx, in_file := range input_files {
+		results[idx] = FileInfo{File: in_file.Name}
+		err := fileInfo(in_file, &results[idx])
+		if err != nil {
+			if errors.Is(err, ErrPasswordRequired) || errors.Is(err, ErrWrongPassword) {
+				results[idx].Encrypted = true
+			}
+			results[idx].Error = err.Error()
+		}
+	}
+	return results
+}
+
+func fileInfo(in_file InputFile, info *FileInfo) error {
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return err
+	}
+
+	info.Version = ctx.VersionString()
+	info.Encrypted = ctx.Encrypt != nil
+	info.Linearized = ctx.Read.Linearized
+	err = ctx.EnsurePageCount()
+	if err != nil {
+		return err
+	}
+	info.PageCount = ctx.PageCount
+
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	var acroform pdfcpu.Object
+	acroform, info.HasAcroForm = cat.Find("AcroForm")
+	if info.HasAcroForm {
+		adict, err := ctx.DereferenceDict(acroform)
+		if err != nil {
+			return err
+		}
+		info.HasXFA = adict != nil && hasXFA(adict)
+		if adict != nil {
+			if fields, err := ctx.DereferenceArray(adict["Fields"]); err == nil {
+				info.Signatures = signatures(ctx, fields)
+			}
+		}
+	}
+
+	if info.PageCount > 0 {
+		dims, err := ctx.PageDims()
+		if err != nil {
+			return err
+		}
+		info.PageWidth, info.PageHeight = dims[0].Width, dims[0].Height
+	}
+
+	if ctx.Info != nil {
+		info_dict, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil {
+			return err
+		}
+		info.Title = infoText(ctx, info_dict, "Title")
+		info.Author = infoText(ctx, info_dict, "Author")
+		info.Subject = infoText(ctx, info_dict, "Subject")
+	}
+	return nil
+}
+
+func infoText(ctx *pdfcpu.Context, info_dict pdfcpu.Dict, key string) string {
+	// A broken entry is left out, it's not worth failing the whole file over
+	o, found := info_dict.Find(key)
+	if !found {
+		return ""
+	}
+	text, err := ctx.DereferenceText(o)
+	if err != nil {
+		return ""
+	}
+	return text
+}
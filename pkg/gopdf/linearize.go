@@ -0,0 +1,36 @@
+package gopdf
+
+import (
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// LinearizeResult is what Linearize wrote
+type LinearizeResult struct {
+	// Whether the written file is linearized ("fast web view")
+	Linearized bool
+	// The size of the written file in bytes
+	Size int64
+}
+
+// Linearize writes a linearized version of in_file to out_path. pdfcpu can't write
+// linearized files, so the original is written unchanged: Linearized says whether it
+// already was. in_file is read in full first, a file pdfcpu can't read is a FileError.
+func Linearize(in_file InputFile, out_path string) (LinearizeResult, error) {
+	var result LinearizeResult
+	err := transformFile(in_file, out_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		ctx, err := api.ReadContext(rs, in_file.configuration())
+		if err != nil {
+			return err
+		}
+		result.Linearized = ctx.Read.Linearized
+		_, err = rs.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		result.Size, err = io.Copy(w, rs)
+		return err
+	})
+	return result, err
+}
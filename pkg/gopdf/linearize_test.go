@@ -0,0 +1,53 @@
+package gopdf
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinearizePassesOriginalThrough(t *testing.T) {
+	linearized := buildPDF(2,
+		"<< /Linearized 1 /L 1000 /H [0 0] /O 4 /E 0 /N 1 /T 0 >>",
+		"<< /Type /Catalog /Pages 3 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 3 0 R /MediaBox [0 0 612 792] >>",
+	)
+	for name, test := range map[string]struct {
+		content    []byte
+		linearized bool
+	}{
+		"plain":      {onePagePDF(), false},
+		"linearized": {linearized, true},
+	} {
+		out_path := filepath.Join(t.TempDir(), "out.pdf")
+		result, err := Linearize(BytesFile(name, test.content), out_path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Linearized != test.linearized || result.Size != int64(len(test.content)) {
+			t.Errorf("%s: got %+v, want linearized %v and size %d", name, result, test.linearized, len(test.content))
+		}
+		written, err := os.ReadFile(out_path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, test.content) {
+			t.Errorf("%s: output_file isn't the original", name)
+		}
+	}
+}
+
+func TestLinearizeInvalid(t *testing.T) {
+	out_path := filepath.Join(t.TempDir(), "out.pdf")
+	_, err := Linearize(BytesFile("broken.pdf", []byte("%PDF-1.7\nnot a pdf")), out_path)
+	var file_err *FileError
+	if !errors.As(err, &file_err) {
+		t.Fatalf("got %v, want a FileError", err)
+	}
+	if _, err := os.Stat(out_path); !os.IsNotExist(err) {
+		t.Errorf("output_file was written for a broken input: %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package gopdf
+
+import (
+	"errors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// MergeOptions are the options of Merge and MergePages
+type MergeOptions struct {
+	// TabOrder is TabOrderPreserve (or empty) or TabOrderRows, see CheckTabOrder
+	TabOrder string
+	// Dedupe collapses streams that are the same in several of the files (a letterhead logo,
+	// a font file) to one object shared by all of them, see dedupeStreams
+	Dedupe bool
+}
+
+// MergeResult is what Merge and MergePages wrote
+type MergeResult struct {
+	PageCount int
+	// What MergeOptions.Dedupe saved, nil without it
+	Dedupe *DedupeResult
+}
+
+// Merge merges input_files, in order, into a single PDF written to out_path.
+// Input files that can't be read are reported as FileErrors.
+func Merge(input_files []InputFile, out_path string, options MergeOptions) (MergeResult, error) {
+	/*
+		Appends the pages of every input file to the first one and merges their AcroForms,
+		so the fields of all the files end up in the merged document.
+	*/
+	var result MergeResult
+	if len(input_files) < 2 {
+		return result, errors.New("merging needs at least two input files")
+	}
+	contexts, err := readContexts(input_files)
+	if err != nil {
+		return result, err
+	}
+	ctx_dest, err := mergedContext(input_files, contexts)
+	if err != nil {
+		return result, err
+	}
+	if options.TabOrder == TabOrderRows {
+		if err = arrangeTabOrder(ctx_dest); err != nil {
+			return result, err
+		}
+	}
+	if options.Dedupe {
+		dedupe := dedupeStreams(ctx_dest)
+		result.Dedupe = &dedupe
+	}
+	result.PageCount = ctx_dest.PageCount
+	return result, writeContextFile(ctx_dest, out_path)
+}
+
+func readContexts(input_files []InputFile) ([]*pdfcpu.Context, error) {
+	// The contexts of all input_files, FileErrors for every one that can't be read
+	contexts := make([]*pdfcpu.Context, len(input_files))
+	file_errors := make(FileErrors, 0)
+	for idx, in_file := range input_files {
+		ctx, err := readContextFile(in_file)
+		if err != nil {
+			file_errors = append(file_errors, &FileError{Idx: idx, Name: in_file.Name, Err: err})
+			continue
+		}
+		contexts[idx] = ctx
+	}
+	if len(file_errors) > 0 {
+		return nil, file_errors
+	}
+	return contexts, nil
+}
+
+func mergeContexts(input_files []InputFile, contexts []*pdfcpu.Context, out_path string) error {
+	// The pages of every context appended to the first one, AcroForms merged, written to out_path
+	ctx_dest, err := mergedContext(input_files, contexts)
+	if err != nil {
+		return err
+	}
+	return writeContextFile(ctx_dest, out_path)
+}
+
+func mergedContext(input_files []InputFile, contexts []*pdfcpu.Context) (*pdfcpu.Context, error) {
+	// contexts[0] with the pages of the others appended and their AcroForms merged into its own
+	ctx_dest := contexts[0]
+	ctx_dest.Cmd = pdfcpu.MERGECREATE
+	ctx_dest.EnsureVersionForWriting()
+
+	for idx, ctx_src := range contexts[1:] {
+		root_src, err := ctx_src.Catalog()
+		if err != nil {
+			return nil, err
+		}
+
+		/*
+			pdfcpu.MergeXRefTables merges the AcroForms on its own, we want our mergeAcroForms
+			to do it instead. Parking the AcroForm under another catalog key hides it from pdfcpu
+			while still getting its object numbers patched along with the rest of the source.
+		*/
+		acroform, has_acroform := root_src.Find("AcroForm")
+		if has_acroform {
+			delete(root_src, "AcroForm")
+			root_src["GopdfAcroForm"] = acroform
+		}
+
+		err = pdfcpu.MergeXRefTables(ctx_src, ctx_dest)
+		if err != nil {
+			return nil, &FileError{Idx: idx + 1, Name: input_files[idx+1].Name, Err: err}
+		}
+
+		if has_acroform {
+			root_src["AcroForm"] = root_src["GopdfAcroForm"]
+			delete(root_src, "GopdfAcroForm")
+			err = mergeAcroForms(ctx_src, ctx_dest)
+			if err != nil {
+				return nil, &FileError{Idx: idx + 1, Name: input_files[idx+1].Name, Err: err}
+			}
+		}
+	}
+
+	err := api.OptimizeContext(ctx_dest)
+	if err != nil {
+		return nil, err
+	}
+	err = api.ValidateContext(ctx_dest)
+	if err != nil {
+		return nil, err
+	}
+	return ctx_dest, nil
+}
+
+func mergeAcroForms(ctxSource, ctxDest *pdfcpu.Context) error {
+	rootDictDest, err := ctxDest.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootDictSource, err := ctxSource.Catalog()
+	if err != nil {
+		return err
+	}
+
+	o, found := rootDictSource.Find("AcroForm")
+	if !found {
+		return nil
+	}
+
+	dSrc, err := ctxSource.DereferenceDict(o)
+	if err != nil || len(dSrc) == 0 {
+		return err
+	}
+
+	// Retrieve ctxSrc AcroForm Fields
+	o, found = dSrc.Find("Fields")
+	if !found {
+		return nil
+	}
+	arrFieldsSrc, err := ctxDest.DereferenceArray(o)
+	if err != nil {
+		return err
+	}
+	if len(arrFieldsSrc) == 0 {
+		return nil
+	}
+
+	// We have a ctxSrc.Acroform with fields.
+
+	o, found = rootDictDest.Find("AcroForm")
+	if !found {
+		rootDictDest["AcroForm"] = dSrc
+		return nil
+	}
+
+	dDest, err := ctxDest.DereferenceDict(o)
+	if err != nil {
+		return err
+	}
+
+	if len(dDest) == 0 {
+		rootDictDest["AcroForm"] = dSrc
+		return nil
+	}
+
+	// Retrieve ctxDest AcroForm Fields
+	o, found = dDest.Find("Fields")
+	if !found {
+		rootDictDest["AcroForm"] = dSrc
+		return nil
+	}
+	arrFieldsDest, err := ctxDest.DereferenceArray(o)
+	if err != nil {
+		return err
+	}
+	if len(arrFieldsDest) == 0 {
+		rootDictDest["AcroForm"] = dSrc
+		return nil
+	}
+
+	// Merge Dsrc into dDest.
+
+	// Fields: add all indrefs
+
+	// Merge all fields from ctxSrc into ctxDest
+	arrFieldsDest = append(arrFieldsDest, arrFieldsSrc...)
+	dDest["Fields"] = arrFieldsDest
+
+	return handleFormAttributes(ctxSource, ctxDest, dSrc, dDest, arrFieldsSrc)
+}
+
+func handleNeedAppearances(ctxSource *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
+	o, found := dSrc.Find("NeedAppearances")
+	if !found || o == nil {
+		return nil
+	}
+	b, err := ctxSource.DereferenceBoolean(o, pdfcpu.V10)
+	if err != nil {
+		return err
+	}
+	if b != nil && *b {
+		dDest["NeedAppearances"] = pdfcpu.Boolean(true)
+	}
+	return nil
+}
+
+func handleSigFlags(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
+	o, found := dSrc.Find("SigFlags")
+	if !found {
+		return nil
+	}
+	iSrc, err := ctxSource.DereferenceInteger(o)
+	if err != nil {
+		return err
+	}
+	if iSrc == nil {
+		return nil
+	}
+	// SignaturesExist (1) and AppendOnly (2) are the only flags
+	flags := iSrc.Value() & 3
+	// Merge SigFlags into dDest.
+	o, found = dDest.Find("SigFlags")
+	if !found {
+		dDest["SigFlags"] = pdfcpu.Integer(flags)
+		return nil
+	}
+	iDest, err := ctxDest.DereferenceInteger(o)
+	if err != nil {
+		return err
+	}
+	if iDest != nil {
+		flags |= iDest.Value()
+	}
+	// iDest is a copy, the ORed flags have to be written back
+	dDest["SigFlags"] = pdfcpu.Integer(flags)
+	return nil
+}
+
+func handleCO(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
+	o, found := dSrc.Find("CO")
+	if !found {
+		return nil
+	}
+	arrSrc, err := ctxSource.DereferenceArray(o)
+	if err != nil {
+		return err
+	}
+	o, found = dDest.Find("CO")
+	if !found {
+		dDest["CO"] = arrSrc
+		return nil
+	}
+	arrDest, err := ctxDest.DereferenceArray(o)
+	if err != nil {
+		return err
+	}
+	if len(arrDest) == 0 {
+		dDest["CO"] = arrSrc
+	} else {
+		arrDest = append(arrDest, arrSrc...)
+		dDest["CO"] = arrDest
+	}
+	return nil
+}
+
+func handleDR(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict) error {
+	// The source's DR goes to the destination AcroForm when that has none or an empty one
+	o, found := dSrc.Find("DR")
+	if !found {
+		return nil
+	}
+	drSrc, err := ctxSource.DereferenceDict(o)
+	if err != nil {
+		return err
+	}
+	if len(drSrc) == 0 {
+		return nil
+	}
+	if o, found = dDest.Find("DR"); found {
+		drDest, err := ctxDest.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if len(drDest) > 0 {
+			return nil
+		}
+	}
+	dDest["DR"] = drSrc
+	return nil
+}
+
+func handleDA(ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict, arrFieldsSrc pdfcpu.Array) error {
+	// The source's DA becomes the destination's default, or when that has its own goes to the
+	// source's text fields that relied on it
+	sSrc := dSrc.StringEntry("DA")
+	if sSrc == nil || len(*sSrc) == 0 {
+		return nil
+	}
+	if dDest.StringEntry("DA") == nil {
+		dDest["DA"] = pdfcpu.StringLiteral(*sSrc)
+		return nil
+	}
+	return pushDownDefault(ctxDest, arrFieldsSrc, "DA", pdfcpu.StringLiteral(*sSrc))
+}
+
+func handleQ(ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict, arrFieldsSrc pdfcpu.Array) error {
+	// Same as handleDA for the quadding Q
+	iSrc := dSrc.IntEntry("Q")
+	if iSrc == nil {
+		return nil
+	}
+	if dDest.IntEntry("Q") == nil {
+		dDest["Q"] = pdfcpu.Integer(*iSrc)
+		return nil
+	}
+	return pushDownDefault(ctxDest, arrFieldsSrc, "Q", pdfcpu.Integer(*iSrc))
+}
+
+func pushDownDefault(ctxDest *pdfcpu.Context, arrFieldsSrc pdfcpu.Array, key string, value pdfcpu.Object) error {
+	/*
+		Sets key to the source AcroForm's value on the terminal text fields of arrFieldsSrc
+		that neither have it nor inherit it from a parent, once merged they'd get the
+		destination's instead. They're in ctxDest by now, MergeXRefTables moved them there.
+	*/
+	return walkFields(ctxDest, arrFieldsSrc, func(_ string, d pdfcpu.Dict) error {
+		if ft, ok := inheritedAttr(ctxDest, d, "FT").(pdfcpu.Name); !ok || ft != "Tx" {
+			return nil
+		}
+		if inheritedAttr(ctxDest, d, key) == nil {
+			d[key] = value
+		}
+		return nil
+	})
+}
+
+func handleFormAttributes(ctxSource, ctxDest *pdfcpu.Context, dSrc, dDest pdfcpu.Dict, arrFieldsSrc pdfcpu.Array) error {
+
+	// NeedAppearances: try: set to true only
+	if err := handleNeedAppearances(ctxSource, dSrc, dDest); err != nil {
+		return err
+	}
+
+	// SigFlags: set bit 1 to true only (SignaturesExist)
+	//           set bit 2 to true only (AppendOnly)
+	if err := handleSigFlags(ctxSource, ctxDest, dSrc, dDest); err != nil {
+		return err
+	}
+
+	// CO: add all indrefs
+	if err := handleCO(ctxSource, ctxDest, dSrc, dDest); err != nil {
+		return err
+	}
+
+	// DR: default resource dict
+	if err := handleDR(ctxSource, ctxDest, dSrc, dDest); err != nil {
+		return err
+	}
+
+	// DA: default appearance streams for variable text fields
+	if err := handleDA(ctxDest, dSrc, dDest, arrFieldsSrc); err != nil {
+		return err
+	}
+
+	// Q: left, center, right for variable text fields
+	if err := handleQ(ctxDest, dSrc, dDest, arrFieldsSrc); err != nil {
+		return err
+	}
+
+	// XFA: ignore
+	delete(dDest, "XFA")
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package gopdf
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// A form with a signed signature field, SignaturesExist and AppendOnly set
+var signed_form = buildPDF(1,
+	"<< /Type /Catalog /Pages 2 0 R /AcroForm 4 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	"<< /Fields [5 0 R] /SigFlags 3 >>",
+	"<< /Type /Annot /Subtype /Widget /FT /Sig /T (signature) /Rect [72 100 272 140] /V 6 0 R >>",
+	"<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached /Name (Jane Doe) /ByteRange [0 0 0 0] /Contents <00> >>",
+)
+
+func mergedAcroForm(t *testing.T, out_path string) (*pdfcpu.Context, pdfcpu.Dict) {
+	t.Helper()
+	ctx, err := api.ReadContextFile(out_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil || adict == nil {
+		t.Fatalf("merged file has no AcroForm (%v)", err)
+	}
+	return ctx, adict
+}
+
+func acroFormSigFlags(t *testing.T, out_path string) (int, bool) {
+	t.Helper()
+	ctx, adict := mergedAcroForm(t, out_path)
+	flags, err := ctx.DereferenceInteger(adict["SigFlags"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags == nil {
+		return 0, false
+	}
+	return flags.Value(), true
+}
+
+func TestMergeSigFlags(t *testing.T) {
+	unsigned_form := formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>")
+	for name, input_files := range map[string][]InputFile{
+		"signed first":   {BytesFile("signed.pdf", signed_form), BytesFile("unsigned.pdf", unsigned_form)},
+		"unsigned first": {BytesFile("unsigned.pdf", unsigned_form), BytesFile("signed.pdf", signed_form)},
+	} {
+		out_path := filepath.Join(t.TempDir(), "merged.pdf")
+		if _, err := Merge(input_files, out_path, MergeOptions{}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if flags, found := acroFormSigFlags(t, out_path); flags != 3 {
+			t.Errorf("%s: got SigFlags %d (found %v), want 3, the signed form's", name, flags, found)
+		}
+	}
+
+	out_path := filepath.Join(t.TempDir(), "merged.pdf")
+	if _, err := Merge([]InputFile{BytesFile("a.pdf", unsigned_form), BytesFile("b.pdf", unsigned_form)}, out_path, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if flags, found := acroFormSigFlags(t, out_path); found {
+		t.Errorf("two unsigned forms: got SigFlags %d, want none", flags)
+	}
+}
+
+func acroFormPDF(acroform string, fields ...string) []byte {
+	// A one page PDF with acroform as the AcroForm, object 4, and fields from 5 on
+	return buildPDF(1, append([]string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm 4 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		acroform,
+	}, fields...)...)
+}
+
+func TestMergeDR(t *testing.T) {
+	source := acroFormPDF("<< /Fields [5 0 R] /DA (/TiRo 0 Tf 0 g) /DR << /Font << /TiRo << /Type /Font /Subtype /Type1 /BaseFont /Times-Roman >> >> >> >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (second) /Rect [72 560 272 580] >>")
+	for _, test := range []struct {
+		what      string
+		dest      []byte
+		base_font string
+	}{
+		{"an empty DR", acroFormPDF("<< /Fields [5 0 R] /DA (/Helv 0 Tf 0 g) /DR << >> >>",
+			"<< /Type /Annot /Subtype /Widget /FT /Tx /T (first) /Rect [72 600 272 620] >>"), "Times-Roman"},
+		{"a DR that's null", acroFormPDF("<< /Fields [5 0 R] /DA (/Helv 0 Tf 0 g) /DR 6 0 R >>",
+			"<< /Type /Annot /Subtype /Widget /FT /Tx /T (first) /Rect [72 600 272 620] >>", "null"), "Times-Roman"},
+		{"no DR", formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (first) /Rect [72 600 272 620] >>"), "Times-Roman"},
+		{"a DR of its own", acroFormPDF("<< /Fields [5 0 R] /DA (/Helv 0 Tf 0 g) /DR << /Font << /TiRo << /Type /Font /Subtype /Type1 /BaseFont /Courier >> >> >> >>",
+			"<< /Type /Annot /Subtype /Widget /FT /Tx /T (first) /Rect [72 600 272 620] >>"), "Courier"},
+	} {
+		out_path := filepath.Join(t.TempDir(), "merged.pdf")
+		if _, err := Merge([]InputFile{BytesFile("first.pdf", test.dest), BytesFile("second.pdf", source)}, out_path, MergeOptions{}); err != nil {
+			t.Fatalf("%s: %v", test.what, err)
+		}
+		ctx, adict := mergedAcroForm(t, out_path)
+		dr, err := ctx.DereferenceDict(adict["DR"])
+		if err != nil || dr == nil {
+			t.Fatalf("%s: merged AcroForm has no DR (%v): %v", test.what, err, adict)
+		}
+		if _, nested := dr["DR"]; nested {
+			t.Errorf("%s: the source DR went into the destination's DR: %v", test.what, dr)
+		}
+		fonts, err := ctx.DereferenceDict(dr["Font"])
+		if err != nil || fonts == nil {
+			t.Fatalf("%s: DR has no fonts (%v): %v", test.what, err, dr)
+		}
+		font, err := ctx.DereferenceDict(fonts["TiRo"])
+		if err != nil || font == nil || font["BaseFont"] != pdfcpu.Name(test.base_font) {
+			t.Errorf("%s: DR font TiRo is %v (%v), want %s", test.what, font, err, test.base_font)
+		}
+	}
+}
+
+func TestMergeKeepsFields(t *testing.T) {
+	first := formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Tx /T (first) /Rect [72 600 272 620] >>")
+	second := formPDF(1, "<< /Type /Annot /Subtype /Widget /FT /Btn /T (second) /Rect [72 560 84 572] >>")
+	out_path := filepath.Join(t.TempDir(), "merged.pdf")
+	result, err := Merge([]InputFile{BytesFile("first.pdf", first), BytesFile("second.pdf", second)}, out_path, MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PageCount != 2 {
+		t.Errorf("got %d pages, want 2", result.PageCount)
+	}
+	ctx, err := api.ReadContextFile(out_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := fieldDict(t, ctx, "second"); d["FT"] != pdfcpu.Name("Btn") {
+		t.Errorf("second has FT %v after merging, want Btn", d["FT"])
+	}
+	fieldDict(t, ctx, "first")
+}
+
+func TestMergeSelfCheck(t *testing.T) {
+	// What /healthcheck runs at startup, failing here is a pdfcpu upgrade breaking mergedContext
+	if err := MergeSelfCheck(); err != nil {
+		t.Fatal(err)
+	}
+	if err := SelfCheck(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeDAQNestedFields(t *testing.T) {
+	// The source's text fields under a parent keep drawing with its DA and Q, not the destination's
+	dest := acroFormPDF("<< /Fields [5 0 R] /DA (/Helv 0 Tf 0 g) /Q 0 >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (first) /Rect [72 600 272 620] >>")
+	source := acroFormPDF("<< /Fields [5 0 R 8 0 R] /DA (/TiRo 12 Tf 1 0 0 rg) /Q 2 >>",
+		"<< /T (address) /Kids [6 0 R 7 0 R] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 5 0 R /FT /Tx /T (street) /Rect [72 560 272 580] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 5 0 R /FT /Tx /T (city) /Rect [72 520 272 540] /DA (/Cour 10 Tf 0 g) /Q 1 >>",
+		"<< /T (boxed) /FT /Tx /DA (/HeBo 9 Tf 0 g) /Kids [9 0 R] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 8 0 R /T (zip) /Rect [72 480 272 500] >>",
+	)
+	out_path := filepath.Join(t.TempDir(), "merged.pdf")
+	if _, err := Merge([]InputFile{BytesFile("first.pdf", dest), BytesFile("second.pdf", source)}, out_path, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, adict := mergedAcroForm(t, out_path)
+	// What a field has, inherits from its parents or else gets from the AcroForm
+	attr := func(d pdfcpu.Dict, key string) pdfcpu.Object {
+		if o := inheritedAttr(ctx, d, key); o != nil {
+			return o
+		}
+		return adict[key]
+	}
+	for _, test := range []struct {
+		field string
+		da    string
+		q     int
+	}{
+		{"first", "/Helv 0 Tf 0 g", 0},
+		{"address.street", "/TiRo 12 Tf 1 0 0 rg", 2},
+		{"address.city", "/Cour 10 Tf 0 g", 1},
+		{"boxed.zip", "/HeBo 9 Tf 0 g", 2},
+	} {
+		d := fieldDict(t, ctx, test.field)
+		if da := textEntry(ctx, attr(d, "DA")); da != test.da {
+			t.Errorf("%s: DA is %q, want %q", test.field, da, test.da)
+		}
+		if q, ok := attr(d, "Q").(pdfcpu.Integer); !ok || q.Value() != test.q {
+			t.Errorf("%s: Q is %v, want %d", test.field, attr(d, "Q"), test.q)
+		}
+	}
+}
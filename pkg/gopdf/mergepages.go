@@ -0,0 +1,270 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// PageSelector is a run of pages out of one of the input files of MergePages
+type PageSelector struct {
+	// The index of the file in the input files, from 0
+	File int
+	// The pages, in pdfcpu's page selection syntax and in the order selected like TrimPages
+	// takes them, so "3,1-2" is pages 3, 1 and 2. Empty is every page.
+	Pages string
+}
+
+// SelectorError is what MergePages returns for a selector that doesn't fit the input files
+type SelectorError struct {
+	// The index of the selector
+	Idx int
+	Err error
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("selector %d: %v", e.Idx, e.Err)
+}
+
+func (e *SelectorError) Unwrap() error {
+	return e.Err
+}
+
+// MergePages writes the pages the selectors pick out of input_files to out_path, in the
+// order of the selectors, and returns the page count written. The pages keep their
+// annotations and the forms are merged like Merge merges them, minus the fields that were
+// only on pages left out. A page picked more than once keeps its fields the first time only,
+// a widget can't be on two pages. The outlines and page labels of the first file are left
+// out, they're about its own pages. Input files that can't be read are reported as FileErrors,
+// a selector naming a file or a page that doesn't exist as a *SelectorError.
+func MergePages(input_files []InputFile, out_path string, selectors []PageSelector, options MergeOptions) (MergeResult, error) {
+	var result MergeResult
+	if len(selectors) == 0 {
+		return result, errors.New("merging pages needs at least one selector")
+	}
+	contexts, err := readContexts(input_files)
+	if err != nil {
+		return result, err
+	}
+
+	// The merged file has the pages of the input files one after the other
+	offsets := make([]int, len(contexts))
+	offset := 0
+	for idx, ctx := range contexts {
+		if err = ctx.EnsurePageCount(); err != nil {
+			return result, &FileError{Idx: idx, Name: input_files[idx].Name, Err: err}
+		}
+		offsets[idx] = offset
+		offset += ctx.PageCount
+	}
+	order := make([]int, 0)
+	for idx, selector := range selectors {
+		if selector.File < 0 || selector.File >= len(contexts) {
+			return result, &SelectorError{Idx: idx, Err: fmt.Errorf("file %d is out of range, there are %d input files", selector.File, len(contexts))}
+		}
+		page_count := contexts[selector.File].PageCount
+		page_nrs := make([]int, page_count)
+		for i := range page_nrs {
+			page_nrs[i] = i + 1
+		}
+		if selector.Pages != "" {
+			page_selection, err := api.ParsePageSelection(selector.Pages)
+			if err == nil {
+				page_nrs, err = collectedPages(page_selection, selector.Pages, page_count)
+			}
+			if err != nil {
+				return result, &SelectorError{Idx: idx, Err: err}
+			}
+		}
+		for _, page_nr := range page_nrs {
+			order = append(order, offsets[selector.File]+page_nr)
+		}
+	}
+
+	ctx_dest, err := mergedContext(input_files, contexts)
+	if err != nil {
+		return result, err
+	}
+	if err = arrangePages(ctx_dest, order); err != nil {
+		return result, err
+	}
+	if options.TabOrder == TabOrderRows {
+		if err = arrangeTabOrder(ctx_dest); err != nil {
+			return result, err
+		}
+	}
+	if options.Dedupe {
+		dedupe := dedupeStreams(ctx_dest)
+		result.Dedupe = &dedupe
+	}
+	if err = api.ValidateContext(ctx_dest); err != nil {
+		return result, err
+	}
+	result.PageCount = len(order)
+	return result, writeContextFile(ctx_dest, out_path)
+}
+
+func arrangePages(ctx *pdfcpu.Context, order []int) error {
+	/*
+		Replaces the page tree of ctx with one page node holding the pages numbered in
+		order, in that order. Pages lose the attributes they inherited from the nodes in
+		between, so those are copied onto them. A page that comes up again is copied,
+		without its widgets, and the fields whose widgets were all on pages left out go.
+	*/
+	pages_ref, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	pages_dict, err := ctx.DereferenceDict(*pages_ref)
+	if err != nil {
+		return err
+	}
+	page_dicts := make([]pdfcpu.Dict, ctx.PageCount+1)
+	page_refs := make([]pdfcpu.IndirectRef, ctx.PageCount+1)
+	for page_nr := 1; page_nr <= ctx.PageCount; page_nr++ {
+		d, page_ref, inherited, err := ctx.PageDict(page_nr, false)
+		if err != nil {
+			return err
+		}
+		if d == nil || page_ref == nil {
+			return fmt.Errorf("page %d is missing from the page tree", page_nr)
+		}
+		if d["Resources"] == nil && inherited.Resources != nil {
+			d["Resources"] = inherited.Resources
+		}
+		if d["MediaBox"] == nil && inherited.MediaBox != nil {
+			d["MediaBox"] = inherited.MediaBox.Array()
+		}
+		if d["CropBox"] == nil && inherited.CropBox != nil {
+			d["CropBox"] = inherited.CropBox.Array()
+		}
+		if d["Rotate"] == nil && inherited.Rotate%360 != 0 {
+			d["Rotate"] = pdfcpu.Integer(inherited.Rotate)
+		}
+		page_dicts[page_nr] = d
+		page_refs[page_nr] = *page_ref
+	}
+
+	kids := make(pdfcpu.Array, 0, len(order))
+	used := make([]bool, ctx.PageCount+1)
+	for _, page_nr := range order {
+		page_ref := page_refs[page_nr]
+		if used[page_nr] {
+			copy_ref, err := copyPage(ctx, page_dicts[page_nr])
+			if err != nil {
+				return err
+			}
+			page_ref = *copy_ref
+		} else {
+			page_dicts[page_nr]["Parent"] = *pages_ref
+		}
+		used[page_nr] = true
+		kids = append(kids, page_ref)
+	}
+	pages_dict["Kids"] = kids
+	pages_dict["Count"] = pdfcpu.Integer(len(kids))
+	ctx.PageCount = len(kids)
+
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	delete(cat, "Outlines")
+	delete(cat, "PageLabels")
+
+	// The widgets of the pages left out, and the fields that are nothing but those
+	dropped := make(map[int]bool)
+	for page_nr := 1; page_nr < len(page_dicts); page_nr++ {
+		if used[page_nr] {
+			continue
+		}
+		annots, err := ctx.DereferenceArray(page_dicts[page_nr]["Annots"])
+		if err != nil {
+			return err
+		}
+		for _, o := range annots {
+			if ref, ok := o.(pdfcpu.IndirectRef); ok {
+				dropped[ref.ObjectNumber.Value()] = true
+			}
+		}
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil || adict == nil || len(dropped) == 0 {
+		return err
+	}
+	fields, err := ctx.DereferenceArray(adict["Fields"])
+	if err != nil {
+		return err
+	}
+	adict["Fields"], err = pruneFields(ctx, fields, dropped)
+	return err
+}
+
+func copyPage(ctx *pdfcpu.Context, d pdfcpu.Dict) (*pdfcpu.IndirectRef, error) {
+	// A new page with what d has, the contents and resources shared, its annotations but the widgets copied
+	page_copy := d.Clone().(pdfcpu.Dict)
+	delete(page_copy, "Annots")
+	copy_ref, err := ctx.IndRefForNewObject(page_copy)
+	if err != nil {
+		return nil, err
+	}
+	annots, err := ctx.DereferenceArray(d["Annots"])
+	if err != nil {
+		return nil, err
+	}
+	annots_copy := make(pdfcpu.Array, 0, len(annots))
+	for _, o := range annots {
+		annot, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if annot == nil || isName(annot, "Subtype", "Widget") {
+			continue
+		}
+		annot_copy := annot.Clone().(pdfcpu.Dict)
+		annot_copy["P"] = *copy_ref
+		// A popup belongs to the annotation on the other page
+		delete(annot_copy, "Popup")
+		annot_ref, err := ctx.IndRefForNewObject(annot_copy)
+		if err != nil {
+			return nil, err
+		}
+		annots_copy = append(annots_copy, *annot_ref)
+	}
+	if len(annots_copy) > 0 {
+		page_copy["Annots"] = annots_copy
+	}
+	return copy_ref, nil
+}
+
+func pruneFields(ctx *pdfcpu.Context, fields pdfcpu.Array, dropped map[int]bool) (pdfcpu.Array, error) {
+	// fields without the widgets in dropped and without the fields that had nothing but those as kids
+	kept := make(pdfcpu.Array, 0, len(fields))
+	for _, o := range fields {
+		if ref, ok := o.(pdfcpu.IndirectRef); ok && dropped[ref.ObjectNumber.Value()] {
+			continue
+		}
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil && d["Kids"] != nil {
+			kids, err := ctx.DereferenceArray(d["Kids"])
+			if err != nil {
+				return nil, err
+			}
+			kept_kids, err := pruneFields(ctx, kids, dropped)
+			if err != nil {
+				return nil, err
+			}
+			if len(kids) > 0 && len(kept_kids) == 0 {
+				continue
+			}
+			d["Kids"] = kept_kids
+		}
+		kept = append(kept, o)
+	}
+	return kept, nil
+}
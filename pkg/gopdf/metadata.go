@@ -0,0 +1,150 @@
+package gopdf
+
+import (
+	"errors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// Metadata is a document's metadata: the entries of its Info dict and its XMP packet
+type Metadata struct {
+	Title        string `json:"title,omitempty"`
+	Author       string `json:"author,omitempty"`
+	Subject      string `json:"subject,omitempty"`
+	Keywords     string `json:"keywords,omitempty"`
+	Creator      string `json:"creator,omitempty"`
+	Producer     string `json:"producer,omitempty"`
+	CreationDate string `json:"creation_date,omitempty"`
+	ModDate      string `json:"mod_date,omitempty"`
+	// The catalog's Metadata stream, an XML document
+	XMP string `json:"xmp,omitempty"`
+}
+
+// MetadataUpdate is what SetMetadata changes, nil is left as it is and "" removes the entry
+type MetadataUpdate struct {
+	Title    *string
+	Author   *string
+	Subject  *string
+	Keywords *string
+	XMP      *string
+	// Clear removes the Info dict and the XMP packet before anything is set
+	Clear bool
+}
+
+// SetMetadata writes in_file with its metadata updated to out_path and returns the metadata
+// before and after. pdfcpu writes a Producer, CreationDate and ModDate of its own into every
+// file, so even a cleared file has those. A file that can't be read is a FileError.
+func SetMetadata(in_file InputFile, out_path string, update MetadataUpdate) (Metadata, Metadata, error) {
+	file_error := func(err error) *FileError {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return Metadata{}, Metadata{}, file_error(err)
+	}
+	before, err := readMetadata(ctx)
+	if err != nil {
+		return Metadata{}, Metadata{}, file_error(err)
+	}
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return before, Metadata{}, file_error(err)
+	}
+
+	if update.Clear {
+		ctx.Info = nil
+		delete(cat, "Metadata")
+	}
+
+	entries := []struct {
+		key   string
+		value *string
+	}{{"Title", update.Title}, {"Author", update.Author}, {"Subject", update.Subject}, {"Keywords", update.Keywords}}
+	for _, entry := range entries {
+		if entry.value == nil {
+			continue
+		}
+		if ctx.Info == nil {
+			if *entry.value == "" {
+				continue
+			}
+			ctx.Info, err = ctx.IndRefForNewObject(pdfcpu.Dict{})
+			if err != nil {
+				return before, Metadata{}, err
+			}
+		}
+		info_dict, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil || info_dict == nil {
+			return before, Metadata{}, file_error(errors.New("the Info dict is broken"))
+		}
+		if *entry.value == "" {
+			delete(info_dict, entry.key)
+		} else {
+			info_dict[entry.key] = textObject(*entry.value)
+		}
+	}
+
+	if update.XMP != nil && *update.XMP == "" {
+		delete(cat, "Metadata")
+	} else if update.XMP != nil {
+		// Left uncompressed, tools that don't parse PDFs look for the packet in the plain bytes
+		sd := pdfcpu.StreamDict{Dict: pdfcpu.Dict{"Type": pdfcpu.Name("Metadata"), "Subtype": pdfcpu.Name("XML")}, Content: []byte(*update.XMP)}
+		if err = sd.Encode(); err != nil {
+			return before, Metadata{}, err
+		}
+		ref, err := ctx.IndRefForNewObject(sd)
+		if err != nil {
+			return before, Metadata{}, err
+		}
+		cat["Metadata"] = *ref
+	}
+
+	err = writeContextFile(ctx, out_path)
+	if err != nil {
+		return before, Metadata{}, err
+	}
+	// What ended up in the file, pdfcpu's own entries included
+	out_file := PathFile(out_path)
+	out_file.Password = in_file.Password
+	out_ctx, err := readContextFile(out_file)
+	if err != nil {
+		return before, Metadata{}, err
+	}
+	after, err := readMetadata(out_ctx)
+	return before, after, err
+}
+
+func readMetadata(ctx *pdfcpu.Context) (Metadata, error) {
+	var metadata Metadata
+	if ctx.Info != nil {
+		info_dict, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil {
+			return metadata, err
+		}
+		if info_dict != nil {
+			metadata.Title = infoText(ctx, info_dict, "Title")
+			metadata.Author = infoText(ctx, info_dict, "Author")
+			metadata.Subject = infoText(ctx, info_dict, "Subject")
+			metadata.Keywords = infoText(ctx, info_dict, "Keywords")
+			metadata.Creator = infoText(ctx, info_dict, "Creator")
+			metadata.Producer = infoText(ctx, info_dict, "Producer")
+			metadata.CreationDate = infoText(ctx, info_dict, "CreationDate")
+			metadata.ModDate = infoText(ctx, info_dict, "ModDate")
+		}
+	}
+
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return metadata, err
+	}
+	sd, _, err := ctx.DereferenceStreamDict(cat["Metadata"])
+	if err != nil || sd == nil {
+		// A broken packet is reported as none, like broken Info entries
+		return metadata, nil
+	}
+	if err = sd.Decode(); err == nil {
+		metadata.XMP = string(sd.Content)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,71 @@
+package gopdf
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// Presets NUp takes as a single number, laid out on A4 pages like pdfcpu's nup command does
+var nup_presets = []int{2, 3, 4, 6, 8, 9, 12, 16}
+
+// Rows and columns of a grid can't be more than this
+const nup_max_dim = 10
+
+// NUpGrid is how pages get arranged on a sheet: a preset N (2, 3, 4, 6, 8, 9, 12 or 16 pages
+// on an A4 sheet) or Rows x Cols pages on a sheet sized to fit them.
+type NUpGrid struct {
+	N    int
+	Rows int
+	Cols int
+}
+
+// ParseNUpGrid reads a grid as a preset ("4") or as rows x cols ("2x3")
+func ParseNUpGrid(grid string) (NUpGrid, error) {
+	grid = strings.ToLower(strings.TrimSpace(grid))
+	if rows, cols, found := strings.Cut(grid, "x"); found {
+		r, err_rows := strconv.Atoi(strings.TrimSpace(rows))
+		c, err_cols := strconv.Atoi(strings.TrimSpace(cols))
+		if err_rows != nil || err_cols != nil || r < 1 || c < 1 || r > nup_max_dim || c > nup_max_dim {
+			return NUpGrid{}, fmt.Errorf("grid rows and columns must be between 1 and %d, got %q", nup_max_dim, grid)
+		}
+		if r*c == 1 {
+			return NUpGrid{}, fmt.Errorf("grid %q puts one page on every sheet", grid)
+		}
+		return NUpGrid{Rows: r, Cols: c}, nil
+	}
+	n, err := strconv.Atoi(grid)
+	if err != nil || !pdfcpu.IntMemberOf(n, nup_presets) {
+		return NUpGrid{}, fmt.Errorf("grid must be rows x cols (like 2x2) or one of 2, 3, 4, 6, 8, 9, 12, 16, got %q", grid)
+	}
+	return NUpGrid{N: n}, nil
+}
+
+// NUp puts the selected pages of in_file (every page by default) onto sheets
+// of grid pages each and writes them to out_path.
+func NUp(in_file InputFile, out_path string, grid NUpGrid, pages string) error {
+	var nup *pdfcpu.NUp
+	var err error
+	if grid.N > 0 {
+		nup, err = api.PDFNUpConfig(grid.N, "")
+	} else {
+		nup, err = api.PDFGridConfig(grid.Rows, grid.Cols, "")
+	}
+	if err != nil {
+		return err
+	}
+	var page_selection []string
+	if pages != "" {
+		page_selection, err = api.ParsePageSelection(pages)
+		if err != nil {
+			return err
+		}
+	}
+	return transformFile(in_file, out_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		return api.NUp(rs, w, nil, page_selection, nup, in_file.configuration())
+	})
+}
@@ -0,0 +1,292 @@
+package gopdf
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// DefaultOCRCoverage is how much of a page without text images have to cover for it to be an OCR candidate
+const DefaultOCRCoverage = 0.5
+
+// PageOCR is what OCRReady found on a page
+type PageOCR struct {
+	Page int `json:"page"`
+	// Whether ExtractText gets any text out of the page
+	HasText bool `json:"has_text"`
+	// The fraction of the page (its CropBox, or MediaBox) images are drawn over, 0 to 1.
+	// It's an estimate: an image counts with the box it's drawn into, masks and clipping aren't looked at.
+	ImageCoverage float64 `json:"image_coverage"`
+	// No text and images over at least the minimum coverage, most likely a scan
+	OCRCandidate bool `json:"ocr_candidate"`
+}
+
+// OCRReady reports for the selected pages (pdfcpu's page selection syntax, empty is every
+// page) of every input file whether they have extractable text and how much of them images
+// cover. Pages without text that images cover min_coverage or more of are OCR candidates.
+// Files that can't be read don't stop the others, they come back as FileErrors.
+func OCRReady(input_files []InputFile, pages string, min_coverage float64) (map[string][]PageOCR, error) {
+	var page_selection []string
+	if pages != "" {
+		var err error
+		page_selection, err = api.ParsePageSelection(pages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reports := make(map[string][]PageOCR)
+	file_errors := make(FileErrors, 0)
+	for idx, in_file := range input_files {
+		ctx, err := readContextFile(in_file)
+		if err == nil {
+			reports[in_file.Name], err = ocrReport(ctx, page_selection, min_coverage)
+		}
+		if err != nil {
+			file_errors = append(file_errors, &FileError{Idx: idx, Name: in_file.Name, Err: err})
+		}
+	}
+	if len(file_errors) > 0 {
+		return reports, file_errors
+	}
+	return reports, nil
+}
+
+func ocrReport(ctx *pdfcpu.Context, page_selection []string, min_coverage float64) ([]PageOCR, error) {
+	texts, err := extractContextText(ctx, page_selection)
+	if err != nil {
+		return nil, err
+	}
+	page_numbers, err := selectedPageNumbers(ctx, page_selection)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]PageOCR, len(page_numbers))
+	for i, page_nr := range page_numbers {
+		page := PageOCR{Page: page_nr, HasText: strings.TrimSpace(texts[i]) != ""}
+		coverage, err := imageCoverage(ctx, page_nr)
+		if err != nil {
+			return nil, err
+		}
+		// Rounded, it's an estimate and 0.9999999 says nothing 1 doesn't
+		page.ImageCoverage = math.Round(coverage*1000) / 1000
+		page.OCRCandidate = !page.HasText && page.ImageCoverage > 0 && page.ImageCoverage >= min_coverage
+		report[i] = page
+	}
+	return report, nil
+}
+
+// imageBox is where an image lands on the page, in default user space
+type imageBox struct {
+	llx, lly, urx, ury float64
+}
+
+func imageCoverage(ctx *pdfcpu.Context, page_nr int) (float64, error) {
+	/*
+		The fraction of page page_nr that the images drawn on it cover. Images are drawn
+		into the unit square of the current transformation matrix, so every Do of an image
+		XObject (and every inline image) covers the unit square mapped through it, and
+		overlapping images count once.
+	*/
+	page_dict, _, inherited, err := ctx.PageDict(page_nr, false)
+	if err != nil || page_dict == nil {
+		return 0, err
+	}
+	page_box := inherited.MediaBox
+	if inherited.CropBox != nil {
+		page_box = inherited.CropBox
+	}
+	if page_box == nil || page_box.Width() <= 0 || page_box.Height() <= 0 {
+		return 0, nil
+	}
+	content, err := ctx.PageContent(page_dict)
+	if err != nil {
+		// No content stream at all, nothing drawn
+		return 0, nil
+	}
+	resources, err := ctx.DereferenceDict(page_dict["Resources"])
+	if err != nil || resources == nil {
+		resources = inherited.Resources
+	}
+
+	boxes := make([]imageBox, 0)
+	if err = streamImageBoxes(ctx, content, resources, identity_matrix, &boxes, 0); err != nil {
+		return 0, err
+	}
+	clipped := make([]imageBox, 0, len(boxes))
+	for _, box := range boxes {
+		box.llx, box.lly = math.Max(box.llx, page_box.LL.X), math.Max(box.lly, page_box.LL.Y)
+		box.urx, box.ury = math.Min(box.urx, page_box.UR.X), math.Min(box.ury, page_box.UR.Y)
+		if box.urx > box.llx && box.ury > box.lly {
+			clipped = append(clipped, box)
+		}
+	}
+	coverage := unionArea(clipped) / (page_box.Width() * page_box.Height())
+	return math.Min(coverage, 1), nil
+}
+
+// A transformation matrix [a b c d e f] as content streams write them
+type matrix [6]float64
+
+var identity_matrix = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) times(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) unitSquare() imageBox {
+	// The bounding box of the unit square mapped through m
+	box := imageBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, corner := range [][2]float64{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		x := m[0]*corner[0] + m[2]*corner[1] + m[4]
+		y := m[1]*corner[0] + m[3]*corner[1] + m[5]
+		box.llx, box.urx = math.Min(box.llx, x), math.Max(box.urx, x)
+		box.lly, box.ury = math.Min(box.lly, y), math.Max(box.ury, y)
+	}
+	return box
+}
+
+func streamImageBoxes(ctx *pdfcpu.Context, content []byte, resources pdfcpu.Dict, ctm matrix, boxes *[]imageBox, depth int) error {
+	/*
+		Walks the operators of a content stream like extractStreamText does, following
+		the CTM through q, Q and cm, and adds the box of every image it draws to boxes.
+		Form XObjects are walked with their Matrix and own resources.
+	*/
+	if depth > 8 {
+		return nil
+	}
+	saved := make([]matrix, 0)
+	lexer := contentLexer{data: content}
+	operands := make([]interface{}, 0)
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		op, is_op := token.(contentOp)
+		if !is_op {
+			operands = append(operands, token)
+			continue
+		}
+		switch op {
+		case "q":
+			saved = append(saved, ctm)
+		case "Q":
+			if len(saved) > 0 {
+				ctm = saved[len(saved)-1]
+				saved = saved[:len(saved)-1]
+			}
+		case "cm":
+			if m, ok := operandMatrix(operands); ok {
+				ctm = m.times(ctm)
+			}
+		case "EI":
+			*boxes = append(*boxes, ctm.unitSquare())
+		case "Do":
+			if len(operands) >= 1 {
+				if name, ok := operands[len(operands)-1].(contentName); ok {
+					if err := xobjectImageBoxes(ctx, resources, string(name), ctm, boxes, depth); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return lexer.err
+}
+
+func xobjectImageBoxes(ctx *pdfcpu.Context, resources pdfcpu.Dict, name string, ctm matrix, boxes *[]imageBox, depth int) error {
+	xobjects, err := ctx.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return nil
+	}
+	sd, _, err := ctx.DereferenceStreamDict(xobjects[name])
+	if err != nil || sd == nil {
+		return nil
+	}
+	subtype := sd.Dict.NameEntry("Subtype")
+	if subtype != nil && *subtype == "Image" {
+		*boxes = append(*boxes, ctm.unitSquare())
+		return nil
+	}
+	if subtype == nil || *subtype != "Form" || sd.Decode() != nil {
+		return nil
+	}
+	form_matrix := identity_matrix
+	if values, ok := numbers(ctx, sd.Dict["Matrix"], 6); ok {
+		copy(form_matrix[:], values)
+	}
+	form_resources, err := ctx.DereferenceDict(sd.Dict["Resources"])
+	if err != nil || form_resources == nil {
+		form_resources = resources
+	}
+	return streamImageBoxes(ctx, sd.Content, form_resources, form_matrix.times(ctm), boxes, depth+1)
+}
+
+func operandMatrix(operands []interface{}) (matrix, bool) {
+	// The last six operands as a matrix, they have to be numbers
+	var m matrix
+	if len(operands) < 6 {
+		return m, false
+	}
+	for i, operand := range operands[len(operands)-6:] {
+		n, ok := operand.(float64)
+		if !ok {
+			return m, false
+		}
+		m[i] = n
+	}
+	return m, true
+}
+
+// The most image boxes unionArea works out the overlaps of
+const max_union_boxes = 200
+
+func unionArea(boxes []imageBox) float64 {
+	/*
+		The area boxes cover together, overlaps counted once: the plane is cut into
+		cells along every box edge and the cells inside any box are added up.
+		That's cubic in the number of boxes, pages tiled out of more images than
+		max_union_boxes get the sum of their areas instead.
+	*/
+	if len(boxes) > max_union_boxes {
+		area := 0.0
+		for _, box := range boxes {
+			area += (box.urx - box.llx) * (box.ury - box.lly)
+		}
+		return area
+	}
+	xs := make([]float64, 0, 2*len(boxes))
+	ys := make([]float64, 0, 2*len(boxes))
+	for _, box := range boxes {
+		xs = append(xs, box.llx, box.urx)
+		ys = append(ys, box.lly, box.ury)
+	}
+	sort.Float64s(xs)
+	sort.Float64s(ys)
+	area := 0.0
+	for i := 0; i+1 < len(xs); i++ {
+		for j := 0; j+1 < len(ys); j++ {
+			cell_x, cell_y := (xs[i]+xs[i+1])/2, (ys[j]+ys[j+1])/2
+			for _, box := range boxes {
+				if cell_x > box.llx && cell_x < box.urx && cell_y > box.lly && cell_y < box.ury {
+					area += (xs[i+1] - xs[i]) * (ys[j+1] - ys[j])
+					break
+				}
+			}
+		}
+	}
+	return area
+}
@@ -0,0 +1,48 @@
+package gopdf
+
+import (
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// OptimizeResult is the size of the file in bytes before and after Optimize.
+// Optimizing can make a file bigger, it's up to the caller to keep the original then.
+type OptimizeResult struct {
+	OriginalSize  int64
+	OptimizedSize int64
+}
+
+// Optimize runs pdfcpu's optimization (duplicate objects and resources removed,
+// streams compressed) on in_file and writes the result to out_path.
+func Optimize(in_file InputFile, out_path string) (OptimizeResult, error) {
+	var result OptimizeResult
+	err := transformFile(in_file, out_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		size, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		result.OriginalSize = size
+		_, err = rs.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		counter := &countingWriter{w: w}
+		err = api.Optimize(rs, counter, in_file.configuration())
+		result.OptimizedSize = counter.n
+		return err
+	})
+	return result, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,116 @@
+package gopdf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// OverlayResult is what Overlay did: the pages the output has, how many of them got an
+// overlay page drawn on top and how many are extra overlay pages appended at the end
+type OverlayResult struct {
+	PageCount int
+	Overlaid  int
+	Appended  int
+}
+
+// Overlay draws page N of overlay_file on top of page N of base_file, at its own size from the
+// bottom left corner, and writes the result to out_path. A shorter overlay leaves the rest of the
+// base pages as they are, the extra pages of a longer one are dropped or, with append_extra,
+// added after the base pages. base_file is idx 0 and overlay_file idx 1 of the FileErrors.
+func Overlay(base_file InputFile, overlay_file InputFile, out_path string, append_extra bool) (OverlayResult, error) {
+	var result OverlayResult
+	base_count, err := pageCount(base_file)
+	if err != nil {
+		return result, &FileError{Idx: 0, Name: base_file.Name, Err: err}
+	}
+	overlay_count, err := pageCount(overlay_file)
+	if err != nil {
+		return result, &FileError{Idx: 1, Name: overlay_file.Name, Err: err}
+	}
+
+	// pdfcpu only reads PDF watermarks from a path, so uploads get copied to one first
+	overlay_path, err := stampFilePath(overlay_file)
+	if err != nil {
+		return result, &FileError{Idx: 1, Name: overlay_file.Name, Err: err}
+	}
+	defer removeTemp(overlay_path)
+
+	result.Overlaid = base_count
+	if overlay_count < base_count {
+		result.Overlaid = overlay_count
+	}
+	watermarks := make(map[int]*pdfcpu.Watermark, result.Overlaid)
+	for page := 1; page <= result.Overlaid; page++ {
+		watermark, err := pdfcpu.ParsePDFWatermarkDetails(fmt.Sprintf("%s:%d", overlay_path, page), "position:bl, offset:0 0, scalefactor:1 abs, rotation:0", true, pdfcpu.POINTS)
+		if err != nil {
+			return result, err
+		}
+		watermarks[page] = watermark
+	}
+	overlay := func(rs io.ReadSeeker, w io.Writer) error {
+		return api.AddWatermarksMap(rs, w, watermarks, base_file.configuration())
+	}
+	result.PageCount = base_count
+
+	if !append_extra || overlay_count <= base_count {
+		return result, transformFile(base_file, out_path, nil, overlay)
+	}
+
+	// The overlaid base pages and the extra overlay pages go into temp files to be merged
+	overlaid_path, err := tempPath("gopdf-overlaid-*.pdf")
+	if err != nil {
+		return result, err
+	}
+	defer removeTemp(overlaid_path)
+	if err = transformFile(base_file, overlaid_path, nil, overlay); err != nil {
+		return result, err
+	}
+	extra_path, err := tempPath("gopdf-overlay-extra-*.pdf")
+	if err != nil {
+		return result, err
+	}
+	defer removeTemp(extra_path)
+	extra_pages := []string{fmt.Sprintf("%d-", base_count+1)}
+	err = transformFile(overlay_file, extra_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		return api.Collect(rs, w, extra_pages, overlay_file.configuration())
+	})
+	if err != nil {
+		if file_error, ok := err.(*FileError); ok {
+			file_error.Idx = 1
+		}
+		return result, err
+	}
+	if _, err = Merge([]InputFile{PathFile(overlaid_path), PathFile(extra_path)}, out_path, MergeOptions{}); err != nil {
+		return result, err
+	}
+	result.Appended = overlay_count - base_count
+	result.PageCount = overlay_count
+	return result, nil
+}
+
+func pageCount(in_file InputFile) (int, error) {
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return 0, err
+	}
+	if err = ctx.EnsurePageCount(); err != nil {
+		return 0, err
+	}
+	return ctx.PageCount, nil
+}
+
+func tempPath(pattern string) (string, error) {
+	// An empty temp file to write to by path, remove it with removeTemp
+	tmp, err := createTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		removeTemp(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,125 @@
+package gopdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// DeletePages removes the pages selected by pages (pdfcpu's page selection syntax,
+// e.g. "1-3,5") from in_file, writes the result to out_path and returns its page count.
+func DeletePages(in_file InputFile, out_path string, pages string) (int, error) {
+	if pages == "" {
+		return 0, errors.New("no pages to delete")
+	}
+	page_selection, err := api.ParsePageSelection(pages)
+	if err != nil {
+		return 0, err
+	}
+	return pagesTransform(in_file, out_path, func(rs io.ReadSeeker, w io.Writer, page_count int) error {
+		err := api.RemovePages(rs, w, page_selection, in_file.configuration())
+		if err != nil && err.Error() == "pdfcpu: operation invalid" {
+			// That's what pdfcpu says when every page is selected
+			return fmt.Errorf("pages %q selects all %d pages, at least one has to stay", pages, page_count)
+		}
+		return err
+	})
+}
+
+// ReorderPages writes the pages of in_file to out_path in the order given, which
+// has to name every page of in_file (numbered from 1) exactly once. Returns the page count.
+func ReorderPages(in_file InputFile, out_path string, order []int) (int, error) {
+	return pagesTransform(in_file, out_path, func(rs io.ReadSeeker, w io.Writer, page_count int) error {
+		if len(order) != page_count {
+			return fmt.Errorf("order must name all %d pages once, it has %d entries", page_count, len(order))
+		}
+		seen := make([]bool, page_count+1)
+		page_selection := make([]string, len(order))
+		for i, page_nr := range order {
+			if page_nr < 1 || page_nr > page_count {
+				return fmt.Errorf("page %d in order is out of range, the file has %d pages", page_nr, page_count)
+			}
+			if seen[page_nr] {
+				return fmt.Errorf("page %d is in order more than once", page_nr)
+			}
+			seen[page_nr] = true
+			page_selection[i] = strconv.Itoa(page_nr)
+		}
+		// Collect keeps the pages in the order they're selected
+		return api.Collect(rs, w, page_selection, in_file.configuration())
+	})
+}
+
+func pagesTransform(in_file InputFile, out_path string, transform func(rs io.ReadSeeker, w io.Writer, page_count int) error) (int, error) {
+	/*
+		transformFile, with the page count of in_file handed to transform
+		and the page count of the result returned
+	*/
+	result_pages := 0
+	err := transformFile(in_file, out_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		page_count, err := api.PageCount(rs, in_file.configuration())
+		if err != nil {
+			return err
+		}
+		rs.Seek(0, io.SeekStart)
+		var pdf_buf bytes.Buffer
+		err = transform(rs, &pdf_buf, page_count)
+		if err != nil {
+			return err
+		}
+		result_pages, err = api.PageCount(bytes.NewReader(pdf_buf.Bytes()), in_file.configuration())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(pdf_buf.Bytes())
+		return err
+	})
+	return result_pages, err
+}
+
+// TrimPages writes only the pages selected by pages (pdfcpu's page selection syntax)
+// to out_path, in the order they're selected, so "5,1-3" is pages 5, 1, 2 and 3.
+// A page number past the end of in_file is an error. Returns the page count written.
+func TrimPages(in_file InputFile, out_path string, pages string) (int, error) {
+	if pages == "" {
+		return 0, errors.New("no pages to keep")
+	}
+	page_selection, err := api.ParsePageSelection(pages)
+	if err != nil {
+		return 0, err
+	}
+	return pagesTransform(in_file, out_path, func(rs io.ReadSeeker, w io.Writer, page_count int) error {
+		if _, err := collectedPages(page_selection, pages, page_count); err != nil {
+			return err
+		}
+		return api.Collect(rs, w, page_selection, in_file.configuration())
+	})
+}
+
+func collectedPages(page_selection []string, pages string, page_count int) ([]int, error) {
+	/*
+		The page numbers page_selection (parsed from pages) collects out of page_count pages,
+		in the order they're selected. A page past the end or selecting no page at all is an error,
+		pdfcpu quietly leaves out pages that don't exist.
+	*/
+	for _, expression := range page_selection {
+		for _, part := range strings.Split(strings.TrimLeft(expression, "!n"), "-") {
+			if page_nr, err := strconv.Atoi(part); err == nil && page_nr > page_count {
+				return nil, fmt.Errorf("page %d in pages is out of range, the file has %d pages", page_nr, page_count)
+			}
+		}
+	}
+	selected, err := api.PagesForPageCollection(page_count, page_selection)
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("pages %q selects none of the %d pages", pages, page_count)
+	}
+	return selected, nil
+}
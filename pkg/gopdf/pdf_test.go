@@ -0,0 +1,118 @@
+package gopdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+func buildPDF(root int, objs ...string) []byte {
+	/*
+		A PDF of objs, object 1 first, with the catalog at object root and an xref table.
+		pdfcpu won't read files under 512 bytes, a comment after the header pads them.
+	*/
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n%" + strings.Repeat(" ", 512) + "\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, root, xref)
+	return out.Bytes()
+}
+
+func pdfStream(dict string, data string) string {
+	// A stream object of dict without its closing >>, which gets the Length of data
+	return fmt.Sprintf("%s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func onePagePDF(objs ...string) []byte {
+	// A one page PDF, the catalog 1, pages 2 and page 3 come before objs, which start at 4
+	return buildPDF(1, append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}, objs...)...)
+}
+
+func writeTestFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func formPDF(fields int, objs ...string) []byte {
+	/*
+		A one page PDF with an AcroForm of fields fields: objs from 5 on, the first
+		fields of them are the AcroForm's Fields and the rest is what they refer to
+	*/
+	refs := make([]string, fields)
+	for i := range refs {
+		refs[i] = fmt.Sprintf("%d 0 R", i+5)
+	}
+	return buildPDF(1, append([]string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm 4 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Fields [" + strings.Join(refs, " ") + "] /DA (/Helv 0 Tf 0 g) >>",
+	}, objs...)...)
+}
+
+func generateBytes(t *testing.T, content []byte, values map[string]interface{}, options GenerateOptions) (GenerateResult, *pdfcpu.Context) {
+	// Generate on content into memory, the written file read back
+	t.Helper()
+	var out bytes.Buffer
+	result, err := Generate(context.Background(), values, "", []InputFile{BytesFile("form.pdf", content)}, &out, options)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	ctx, err := api.ReadContext(bytes.NewReader(out.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("reading the filled file: %v", err)
+	}
+	return result, ctx
+}
+
+func fieldDict(t *testing.T, ctx *pdfcpu.Context, name string) pdfcpu.Dict {
+	// The terminal field of ctx with the fully qualified name
+	t.Helper()
+	cat, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := ctx.DereferenceArray(adict["Fields"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found pdfcpu.Dict
+	err = walkFields(ctx, fields, func(field_name string, d pdfcpu.Dict) error {
+		if field_name == name {
+			found = d
+		}
+		return nil
+	})
+	if err != nil || found == nil {
+		t.Fatalf("field %s: not found (%v)", name, err)
+	}
+	return found
+}
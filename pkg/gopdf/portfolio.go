@@ -0,0 +1,198 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// PortfolioFields are the columns a portfolio can show its files with
+var PortfolioFields = []string{"filename", "size", "modified"}
+
+// PortfolioViews are how a portfolio can show its files: a list with the fields as columns,
+// tiles, or nothing but the first file
+var PortfolioViews = []string{"details", "tile", "hidden"}
+
+// PortfolioOptions says how Portfolio shows the files
+type PortfolioOptions struct {
+	// Some of PortfolioFields, in the order they're shown. Empty is all of them.
+	Fields []string
+	// One of PortfolioViews, details when it's empty
+	View string
+}
+
+// What the PortfolioFields are in a collection schema: the subtype and the column heading
+var portfolio_fields = map[string][2]string{
+	"filename": {"F", "Name"},
+	"size":     {"Size", "Size"},
+	"modified": {"ModDate", "Modified"},
+}
+
+var portfolio_views = map[string]string{"details": "D", "tile": "T", "hidden": "H"}
+
+// Portfolio writes a PDF portfolio to out_path: one cover page, with input_files embedded
+// under their base names and a Collection that has viewers show them instead of the cover.
+// Viewers without portfolios show the cover and the files as attachments. Names that come up
+// twice get " (2)", " (3)"... before their extension. Returns the files as ListAttachments
+// would. Input files that can't be read are reported as FileErrors.
+func Portfolio(input_files []InputFile, out_path string, options PortfolioOptions) ([]Attachment, error) {
+	if len(input_files) == 0 {
+		return nil, errors.New("a portfolio needs at least one input file")
+	}
+	fields := options.Fields
+	if len(fields) == 0 {
+		fields = PortfolioFields
+	}
+	for _, field := range fields {
+		if !containsString(PortfolioFields, field) {
+			return nil, fmt.Errorf("fields must be some of %v, got %q", PortfolioFields, field)
+		}
+	}
+	view := options.View
+	if view == "" {
+		view = "details"
+	}
+	if !containsString(PortfolioViews, view) {
+		return nil, fmt.Errorf("view must be one of %v, got %q", PortfolioViews, view)
+	}
+
+	contents := make([][]byte, len(input_files))
+	file_errors := make(FileErrors, 0)
+	for idx, in_file := range input_files {
+		content, err := readAttachmentFile(in_file)
+		if err != nil {
+			file_errors = append(file_errors, &FileError{Idx: idx, Name: in_file.Name, Err: err})
+			continue
+		}
+		contents[idx] = content
+	}
+	if len(file_errors) > 0 {
+		return nil, file_errors
+	}
+
+	ctx, err := pdfcpu.CreateContextWithXRefTable(nil, pdfcpu.PaperSize["A4"])
+	if err != nil {
+		return nil, err
+	}
+	if err = portfolioCover(ctx, len(input_files)); err != nil {
+		return nil, err
+	}
+	tree, err := embeddedFilesTree(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]Attachment, len(input_files))
+	used := make(map[string]bool)
+	for idx, in_file := range input_files {
+		name := uniqueName(filepath.Base(in_file.Name), used)
+		mime_type := mime.TypeByExtension(filepath.Ext(name))
+		// A file on disk keeps the time it was changed, an upload is new
+		mod_time := time.Now()
+		if in_file.path != "" {
+			if info, err := os.Stat(in_file.path); err == nil {
+				mod_time = info.ModTime()
+			}
+		}
+		spec, err := embedFile(ctx, name, mime_type, "", contents[idx], mod_time)
+		if err != nil {
+			return nil, err
+		}
+		if err = putName(ctx, tree, name, spec, 0); err != nil {
+			return nil, err
+		}
+		attachments[idx] = Attachment{Name: name, Size: int64(len(contents[idx])), MimeType: mime_type, ModTime: mod_time.UTC().Format(time.RFC3339)}
+	}
+
+	schema := pdfcpu.Dict{"Type": pdfcpu.Name("CollectionSchema")}
+	for i, field := range fields {
+		schema[field] = pdfcpu.Dict{
+			"Type":    pdfcpu.Name("CollectionField"),
+			"Subtype": pdfcpu.Name(portfolio_fields[field][0]),
+			"N":       textObject(portfolio_fields[field][1]),
+			"O":       pdfcpu.Integer(i),
+		}
+	}
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	cat["Collection"] = pdfcpu.Dict{
+		"Type":   pdfcpu.Name("Collection"),
+		"Schema": schema,
+		"D":      textObject(attachments[0].Name),
+		"View":   pdfcpu.Name(portfolio_views[view]),
+		"Sort":   pdfcpu.Dict{"Type": pdfcpu.Name("CollectionSort"), "S": pdfcpu.Name(fields[0]), "A": pdfcpu.Boolean(true)},
+	}
+	cat["PageMode"] = pdfcpu.Name("UseAttachments")
+	if err = writeContextFile(ctx, out_path); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func portfolioCover(ctx *pdfcpu.Context, file_count int) error {
+	// The one page of the portfolio, what viewers without portfolios open it on
+	pages_ref, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	pages_dict, err := ctx.DereferenceDict(*pages_ref)
+	if err != nil {
+		return err
+	}
+	font_ref, err := newCoreFont(ctx, "Helvetica")
+	if err != nil {
+		return err
+	}
+	lines := []string{
+		fmt.Sprintf("This PDF is a portfolio of %d files.", file_count),
+		"Open it in a viewer that shows portfolios, or open its attachments.",
+	}
+	var content strings.Builder
+	content.WriteString("BT /Helv 14 Tf 72 760 Td 20 TL")
+	for _, line := range lines {
+		fmt.Fprintf(&content, " (%s) Tj T*", escapeLiteral(winAnsi(line)))
+	}
+	content.WriteString(" ET")
+	sd, err := ctx.NewStreamDictForBuf([]byte(content.String()))
+	if err != nil {
+		return err
+	}
+	if err = sd.Encode(); err != nil {
+		return err
+	}
+	content_ref, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+	page_ref, err := ctx.IndRefForNewObject(pdfcpu.Dict{
+		"Type":      pdfcpu.Name("Page"),
+		"Parent":    *pages_ref,
+		"Resources": pdfcpu.Dict{"Font": pdfcpu.Dict{"Helv": *font_ref}},
+		"Contents":  *content_ref,
+	})
+	if err != nil {
+		return err
+	}
+	pages_dict["Kids"] = pdfcpu.Array{*page_ref}
+	pages_dict["Count"] = pdfcpu.Integer(1)
+	ctx.PageCount = 1
+	return nil
+}
+
+func uniqueName(name string, used map[string]bool) string {
+	// name, or name with " (2)", " (3)"... before its extension when it's in used, marked used
+	unique := name
+	ext := filepath.Ext(name)
+	for n := 2; used[unique]; n++ {
+		unique = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	used[unique] = true
+	return unique
+}
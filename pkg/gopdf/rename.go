@@ -0,0 +1,261 @@
+package gopdf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// RenameError is everything wrong with the renames given to RenameFields
+type RenameError struct {
+	Problems []string
+}
+
+func (e *RenameError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// The attributes a field inherits from its parents, they're copied onto a field that moves
+var inheritable_keys = []string{"FT", "Ff", "V", "DV", "DA", "Q", "MaxLen"}
+
+// RenameFields renames the fields of in_file, renames maps fully qualified old names to new
+// ones, and writes the result to out_path. A field keeps its parent when only the last part
+// of its name changes, otherwise it's moved under the parent fields the new name has (created
+// when they don't exist yet) and parents left without kids are removed.
+// Old names that aren't fields and new names that would collide are a *RenameError.
+func RenameFields(in_file InputFile, out_path string, renames map[string]string) error {
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return err
+	}
+	file_error := func(err error) error {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return file_error(err)
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil {
+		return file_error(err)
+	}
+	// A file without a form has no fields to rename, checkRenames says so
+	terminal := make(map[string]pdfcpu.Dict)
+	if adict != nil {
+		fields, err := ctx.DereferenceArray(adict["Fields"])
+		if err != nil {
+			return file_error(err)
+		}
+		err = walkFields(ctx, fields, func(field_name string, d pdfcpu.Dict) error {
+			terminal[field_name] = d
+			return nil
+		})
+		if err != nil {
+			return file_error(err)
+		}
+	}
+	if err = checkRenames(terminal, renames); err != nil {
+		return err
+	}
+
+	// Sorted so the fields created for new parents come out in the same order every time
+	old_names := make([]string, 0, len(renames))
+	for old_name := range renames {
+		old_names = append(old_names, old_name)
+	}
+	sort.Strings(old_names)
+	for _, old_name := range old_names {
+		if err = renameField(ctx, adict, terminal[old_name], old_name, renames[old_name]); err != nil {
+			return file_error(fmt.Errorf("renaming %q: %v", old_name, err))
+		}
+	}
+	return writeContextFile(ctx, out_path)
+}
+
+func checkRenames(terminal map[string]pdfcpu.Dict, renames map[string]string) error {
+	/*
+		Every old name has to be a field and the names after renaming have to be unique,
+		with no field named like the parent of another ("address" next to "address.zip")
+	*/
+	problems := make([]string, 0)
+	names := make(map[string]int)
+	for field_name := range terminal {
+		if _, renamed := renames[field_name]; !renamed {
+			names[field_name]++
+		}
+	}
+	for old_name, new_name := range renames {
+		if _, found := terminal[old_name]; !found {
+			problems = append(problems, fmt.Sprintf("there is no field %q", old_name))
+		}
+		if new_name == "" || strings.HasPrefix(new_name, ".") || strings.HasSuffix(new_name, ".") || strings.Contains(new_name, "..") {
+			problems = append(problems, fmt.Sprintf("%q is not a field name, the new name of %q", new_name, old_name))
+			continue
+		}
+		names[new_name]++
+	}
+	for field_name, count := range names {
+		if count > 1 {
+			problems = append(problems, fmt.Sprintf("%d fields would be named %q", count, field_name))
+		}
+		parts := strings.Split(field_name, ".")
+		for i := 1; i < len(parts); i++ {
+			if parent_name := strings.Join(parts[:i], "."); names[parent_name] > 0 {
+				problems = append(problems, fmt.Sprintf("%q would be a field and the parent of %q", parent_name, field_name))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &RenameError{Problems: problems}
+	}
+	return nil
+}
+
+func renameField(ctx *pdfcpu.Context, adict pdfcpu.Dict, d pdfcpu.Dict, old_name string, new_name string) error {
+	old_parent, _ := splitFieldName(old_name)
+	new_parent, partial_name := splitFieldName(new_name)
+	if old_parent == new_parent {
+		d["T"] = textObject(partial_name)
+		return nil
+	}
+
+	// The field takes along what it inherited from where it was
+	for _, key := range inheritable_keys {
+		if _, found := d.Find(key); !found {
+			if o := inheritedAttr(ctx, d, key); o != nil {
+				d[key] = o
+			}
+		}
+	}
+	ref, err := detachField(ctx, adict, d)
+	if err != nil {
+		return err
+	}
+	parent_ref, err := parentField(ctx, adict, new_parent)
+	if err != nil {
+		return err
+	}
+	if parent_ref == nil {
+		delete(d, "Parent")
+		adict["Fields"] = append(fieldKids(ctx, adict, "Fields"), *ref)
+	} else {
+		parent, err := ctx.DereferenceDict(*parent_ref)
+		if err != nil {
+			return err
+		}
+		d["Parent"] = *parent_ref
+		parent["Kids"] = append(fieldKids(ctx, parent, "Kids"), *ref)
+	}
+	d["T"] = textObject(partial_name)
+	return nil
+}
+
+func splitFieldName(field_name string) (string, string) {
+	// "address.zip" is the field zip under the parent address
+	if i := strings.LastIndex(field_name, "."); i >= 0 {
+		return field_name[:i], field_name[i+1:]
+	}
+	return "", field_name
+}
+
+func fieldKids(ctx *pdfcpu.Context, d pdfcpu.Dict, key string) pdfcpu.Array {
+	kids, err := ctx.DereferenceArray(d[key])
+	if err != nil {
+		return nil
+	}
+	return kids
+}
+
+func detachField(ctx *pdfcpu.Context, adict pdfcpu.Dict, d pdfcpu.Dict) (*pdfcpu.IndirectRef, error) {
+	/*
+		Takes d out of the Kids of its parent (or the AcroForm Fields) and returns the reference
+		it was there under. Dicts don't compare, so d is marked to be told apart from its siblings.
+		A parent without kids left is taken out of its own parent the same way.
+	*/
+	container, key := adict, "Fields"
+	parent, err := ctx.DereferenceDict(d["Parent"])
+	if err != nil {
+		return nil, err
+	}
+	if parent != nil {
+		container, key = parent, "Kids"
+	}
+	d["GopdfDetach"] = pdfcpu.Boolean(true)
+	defer delete(d, "GopdfDetach")
+	kids := fieldKids(ctx, container, key)
+	var ref *pdfcpu.IndirectRef
+	left := make(pdfcpu.Array, 0, len(kids))
+	for _, o := range kids {
+		if ir, ok := o.(pdfcpu.IndirectRef); ok && ref == nil {
+			if kid, err := ctx.DereferenceDict(ir); err == nil && kid != nil && kid["GopdfDetach"] != nil {
+				ref = &ir
+				continue
+			}
+		}
+		left = append(left, o)
+	}
+	if ref == nil {
+		return nil, fmt.Errorf("the field isn't among the kids of its parent")
+	}
+	container[key] = left
+	if parent != nil && len(left) == 0 {
+		if _, err := detachField(ctx, adict, parent); err != nil {
+			return nil, err
+		}
+	}
+	return ref, nil
+}
+
+func parentField(ctx *pdfcpu.Context, adict pdfcpu.Dict, parent_name string) (*pdfcpu.IndirectRef, error) {
+	/*
+		The non-terminal field named parent_name, created along with the fields above it when
+		it doesn't exist. nil for "", the top level. Terminal fields are never a parent, one
+		with that name is about to be renamed itself (checkRenames makes sure of that).
+	*/
+	if parent_name == "" {
+		return nil, nil
+	}
+	var parent_ref *pdfcpu.IndirectRef
+	container, key := adict, "Fields"
+	for _, partial_name := range strings.Split(parent_name, ".") {
+		var found *pdfcpu.IndirectRef
+		for _, o := range fieldKids(ctx, container, key) {
+			ir, ok := o.(pdfcpu.IndirectRef)
+			if !ok {
+				continue
+			}
+			kid, err := ctx.DereferenceDict(ir)
+			if err != nil || kid == nil {
+				continue
+			}
+			t, err := ctx.DereferenceText(kid["T"])
+			if err != nil || t != partial_name || !hasFieldKids(ctx, fieldKids(ctx, kid, "Kids")) && kid.NameEntry("FT") != nil {
+				continue
+			}
+			found = &ir
+			break
+		}
+		if found == nil {
+			d := pdfcpu.Dict{"T": textObject(partial_name), "Kids": pdfcpu.Array{}}
+			if parent_ref != nil {
+				d["Parent"] = *parent_ref
+			}
+			ref, err := ctx.IndRefForNewObject(d)
+			if err != nil {
+				return nil, err
+			}
+			container[key] = append(fieldKids(ctx, container, key), *ref)
+			found = ref
+		}
+		parent_ref = found
+		d, err := ctx.DereferenceDict(*found)
+		if err != nil {
+			return nil, err
+		}
+		container, key = d, "Kids"
+	}
+	return parent_ref, nil
+}
@@ -0,0 +1,178 @@
+package gopdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// RepairResult is what Repair found wrong with a file, what it did about it and whether the
+// file it wrote passes validation
+type RepairResult struct {
+	// The validation error of the file as it was, "" when it was valid already
+	Problem string   `json:"problem,omitempty"`
+	Fixes   []string `json:"fixes"`
+	// A file pdfcpu can read but not validate is still written, with Valid false
+	Valid bool `json:"valid"`
+}
+
+// ErrTooDamaged is the error of a file Repair can't get pdfcpu to read at all
+var ErrTooDamaged = errors.New("too damaged to repair")
+
+var (
+	obj_header   = regexp.MustCompile(`(?m)(?:^|[\s>])(\d+)\s+(\d+)\s+obj\b`)
+	trailer_root = regexp.MustCompile(`/Root\s+(\d+)\s+(\d+)\s+R`)
+	trailer_info = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	trailer_enc  = regexp.MustCompile(`/Encrypt\s+(\d+)\s+(\d+)\s+R`)
+	trailer_id   = regexp.MustCompile(`/ID\s*\[\s*<[0-9A-Fa-f]*>\s*<[0-9A-Fa-f]*>\s*\]`)
+	catalog_type = regexp.MustCompile(`/Type\s*/Catalog\b`)
+)
+
+// The biggest object number a PDF may have, anything above is a false match
+const max_object_number = 8388607
+
+// Repair reads in_file as leniently as pdfcpu will, rebuilding its cross-reference table from
+// the objects in the file when pdfcpu can't read it as it is, and writes a clean copy to out_path.
+// pdfcpu's writer puts a fresh xref table and trailer in and leaves out unreachable objects.
+// A file that can't be read even with a rebuilt xref is a FileError wrapping ErrTooDamaged.
+func Repair(in_file InputFile, out_path string) (result RepairResult, err error) {
+	file_error := func(err error) *FileError {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	// pdfcpu's reader panics on some broken files, those are too damaged as well
+	defer func() {
+		if r := recover(); r != nil {
+			err = file_error(fmt.Errorf("%w: %v", ErrTooDamaged, r))
+		}
+	}()
+
+	f, err := in_file.Open()
+	if err != nil {
+		return result, file_error(err)
+	}
+	content, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return result, file_error(err)
+	}
+	result.Fixes = make([]string, 0)
+
+	if err = validateFile(bytes.NewReader(content), in_file); err != nil {
+		result.Problem = err.Error()
+	}
+	ctx, read_err := api.ReadContext(bytes.NewReader(content), in_file.configuration())
+	if read_err != nil {
+		rebuilt, object_count, err := rebuildXRef(content)
+		if err != nil {
+			return result, file_error(fmt.Errorf("%w: %v, %v", ErrTooDamaged, read_err, err))
+		}
+		ctx, err = api.ReadContext(bytes.NewReader(rebuilt), in_file.configuration())
+		if err != nil {
+			return result, file_error(fmt.Errorf("%w: %v, with the xref rebuilt: %v", ErrTooDamaged, read_err, err))
+		}
+		result.Fixes = append(result.Fixes, fmt.Sprintf("rebuilt the cross-reference table from the %d objects found in the file", object_count))
+	}
+
+	result.Valid = api.ValidateContext(ctx) == nil
+	if result.Problem != "" {
+		result.Fixes = append(result.Fixes, "rewrote the file with a new cross-reference table and trailer, leaving out unreachable objects")
+	}
+	if err = writeContextFile(ctx, out_path); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func rebuildXRef(content []byte) ([]byte, int, error) {
+	/*
+		content with a new xref table and trailer appended, pointing at every "N G obj" in it
+		(the last one of a number wins, like in incremental updates). The Root, Info, Encrypt
+		and ID are taken from the last trailer that has them, a file without a Root gets the
+		first object that is a Catalog. Objects inside object streams are only reachable from
+		an xref stream, those are lost when that's what is broken.
+		Returns how many objects the table has.
+	*/
+	type entry struct {
+		offset     int
+		generation int
+	}
+	entries := make(map[int]entry)
+	size := 1
+	for _, match := range obj_header.FindAllSubmatchIndex(content, -1) {
+		number, err := strconv.Atoi(string(content[match[2]:match[3]]))
+		if err != nil || number == 0 || number > max_object_number {
+			continue
+		}
+		generation, err := strconv.Atoi(string(content[match[4]:match[5]]))
+		if err != nil || generation > 65535 {
+			continue
+		}
+		entries[number] = entry{offset: match[2], generation: generation}
+		if number >= size {
+			size = number + 1
+		}
+	}
+	if len(entries) == 0 {
+		return nil, 0, errors.New("no objects found")
+	}
+
+	last := func(re *regexp.Regexp) []byte {
+		matches := re.FindAll(content, -1)
+		if len(matches) == 0 {
+			return nil
+		}
+		return matches[len(matches)-1]
+	}
+	root := last(trailer_root)
+	if match := trailer_root.FindSubmatch(root); match != nil {
+		// A Root that isn't in the file is as good as none
+		number, _ := strconv.Atoi(string(match[1]))
+		if _, found := entries[number]; !found {
+			root = nil
+		}
+	}
+	if root == nil {
+		// The first object that says it's a Catalog
+		first := -1
+		for number, e := range entries {
+			end := bytes.Index(content[e.offset:], []byte("endobj"))
+			if end < 0 {
+				continue
+			}
+			if catalog_type.Match(content[e.offset:e.offset+end]) && (first < 0 || e.offset < entries[first].offset) {
+				first = number
+			}
+		}
+		if first < 0 {
+			return nil, 0, errors.New("no document catalog found")
+		}
+		root = []byte(fmt.Sprintf("/Root %d %d R", first, entries[first].generation))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(content)
+	buf.WriteString("\n")
+	xref_offset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", size)
+	for number := 1; number < size; number++ {
+		if e, found := entries[number]; found {
+			fmt.Fprintf(&buf, "%010d %05d n\r\n", e.offset, e.generation)
+		} else {
+			buf.WriteString("0000000000 00000 f\r\n")
+		}
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d %s", size, root)
+	for _, re := range []*regexp.Regexp{trailer_info, trailer_enc, trailer_id} {
+		if value := last(re); value != nil {
+			buf.WriteString(" ")
+			buf.Write(value)
+		}
+	}
+	fmt.Fprintf(&buf, " >>\nstartxref\n%d\n%%%%EOF\n", xref_offset)
+	return buf.Bytes(), len(entries), nil
+}
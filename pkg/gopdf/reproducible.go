@@ -0,0 +1,190 @@
+package gopdf
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ErrReproducibleEncrypted is what a reproducible write of an encrypted file fails with,
+// its encryption is salted with random bytes so it's never the same twice
+var ErrReproducibleEncrypted = errors.New("encrypted files can't be written reproducibly")
+
+// ReproducibleEpoch is the date reproducible output gets when it isn't given one
+var ReproducibleEpoch = time.Unix(0, 0).UTC()
+
+func writeReproducible(ctx *pdfcpu.Context, w io.Writer, date time.Time) error {
+	/*
+		Writes ctx to w so the same ctx always comes out as the same bytes, which
+		pdfcpu's writer doesn't: it stamps the Info dict with the time, derives the file
+		ID from it and writes objects in the order it finds them walking Go maps.
+		Here CreationDate and ModDate are date and the rest is up to writeWithXRefTable.
+	*/
+	if ctx.Encrypt != nil || ctx.EncKey != nil {
+		return ErrReproducibleEncrypted
+	}
+	if ctx.Info == nil {
+		info, err := ctx.IndRefForNewObject(pdfcpu.Dict{})
+		if err != nil {
+			return err
+		}
+		ctx.Info = info
+	}
+	info, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return errors.New("the Info dict isn't a dict")
+	}
+	date_string := pdfcpu.StringLiteral(pdfcpu.DateString(date.UTC()))
+	info["CreationDate"] = date_string
+	info["ModDate"] = date_string
+	info["Producer"] = pdfcpu.StringLiteral("pdfcpu " + pdfcpu.VersionStr)
+	return writeWithXRefTable(ctx, w, pdfcpu.V17)
+}
+
+func writeWithXRefTable(ctx *pdfcpu.Context, w io.Writer, version pdfcpu.Version) error {
+	/*
+		Writes the objects reachable from the catalog and the Info dict by object number,
+		uncompressed and with a plain xref table, under a version header. Nothing in that
+		layout is newer than PDF 1.0, unlike the object and xref streams pdfcpu writes.
+		The second file ID is the MD5 of everything before the trailer.
+	*/
+	obj_nrs, err := reachableObjects(ctx)
+	if err != nil {
+		return err
+	}
+	size := 1
+	if len(obj_nrs) > 0 {
+		size = obj_nrs[len(obj_nrs)-1] + 1
+	}
+
+	// Offsets are counted here, the MD5 for the file ID sees the same bytes
+	hash := md5.New()
+	bw := bufio.NewWriter(w)
+	out := &countingWriter{w: io.MultiWriter(bw, hash)}
+	io.WriteString(out, "%PDF-"+version.String()+"\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make(map[int]int64, len(obj_nrs))
+	generations := make(map[int]int, len(obj_nrs))
+	for _, obj_nr := range obj_nrs {
+		entry, _ := ctx.FindTableEntryLight(obj_nr)
+		gen := 0
+		if entry.Generation != nil {
+			gen = *entry.Generation
+		}
+		offsets[obj_nr] = out.n
+		generations[obj_nr] = gen
+		fmt.Fprintf(out, "%d %d obj\n", obj_nr, gen)
+		if sd, ok := entry.Object.(pdfcpu.StreamDict); ok {
+			// Length is how long Raw is, an indirect one is left out along with the other unreachable objects
+			d := pdfcpu.Dict{}
+			for key, value := range sd.Dict {
+				d[key] = value
+			}
+			d["Length"] = pdfcpu.Integer(len(sd.Raw))
+			io.WriteString(out, d.PDFString())
+			io.WriteString(out, "\nstream\n")
+			out.Write(sd.Raw)
+			io.WriteString(out, "\nendstream")
+		} else {
+			io.WriteString(out, entry.Object.PDFString())
+		}
+		io.WriteString(out, "\nendobj\n")
+	}
+
+	xref_offset := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n", size)
+	// The free entries are linked up in order, object 0 heads the list
+	free := make([]int, 0)
+	for obj_nr := 1; obj_nr < size; obj_nr++ {
+		if _, used := offsets[obj_nr]; !used {
+			free = append(free, obj_nr)
+		}
+	}
+	next_free := func(i int) int {
+		if i < len(free) {
+			return free[i]
+		}
+		return 0
+	}
+	fmt.Fprintf(out, "%010d 65535 f \n", next_free(0))
+	free_idx := 0
+	for obj_nr := 1; obj_nr < size; obj_nr++ {
+		if offset, used := offsets[obj_nr]; used {
+			fmt.Fprintf(out, "%010d %05d n \n", offset, generations[obj_nr])
+			continue
+		}
+		free_idx++
+		fmt.Fprintf(out, "%010d 00001 f \n", next_free(free_idx))
+	}
+
+	// The first ID is the document's own and stays, the second one identifies this version
+	version_id := "<" + hex.EncodeToString(hash.Sum(nil)) + ">"
+	document_id := version_id
+	if len(ctx.ID) == 2 && ctx.ID[0] != nil {
+		document_id = ctx.ID[0].PDFString()
+	}
+	info_entry := ""
+	if ctx.Info != nil {
+		info_entry = " /Info " + ctx.Info.PDFString()
+	}
+	fmt.Fprintf(bw, "trailer\n<</Size %d /Root %s%s /ID [%s %s]>>\nstartxref\n%d\n%%%%EOF\n",
+		size, ctx.Root.PDFString(), info_entry, document_id, version_id, xref_offset)
+	// bufio keeps the first write error, Flush returns it
+	return bw.Flush()
+}
+
+func reachableObjects(ctx *pdfcpu.Context) ([]int, error) {
+	// The numbers of the objects the catalog and the Info dict lead to, sorted
+	seen := make(map[int]bool)
+	pending := []pdfcpu.Object{*ctx.Root}
+	if ctx.Info != nil {
+		pending = append(pending, *ctx.Info)
+	}
+	for len(pending) > 0 {
+		o := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		switch o := o.(type) {
+		case pdfcpu.IndirectRef:
+			obj_nr := o.ObjectNumber.Value()
+			if seen[obj_nr] {
+				continue
+			}
+			entry, found := ctx.FindTableEntryLight(obj_nr)
+			if !found || entry.Free || entry.Object == nil {
+				// A reference to a missing object reads as null
+				continue
+			}
+			seen[obj_nr] = true
+			pending = append(pending, entry.Object)
+		case pdfcpu.Dict:
+			for _, value := range o {
+				pending = append(pending, value)
+			}
+		case pdfcpu.StreamDict:
+			for key, value := range o.Dict {
+				if key != "Length" {
+					pending = append(pending, value)
+				}
+			}
+		case pdfcpu.Array:
+			pending = append(pending, o...)
+		case pdfcpu.ObjectStreamDict, pdfcpu.XRefStreamDict:
+			return nil, fmt.Errorf("an object stream is referenced like an object")
+		}
+	}
+	obj_nrs := make([]int, 0, len(seen))
+	for obj_nr := range seen {
+		obj_nrs = append(obj_nrs, obj_nr)
+	}
+	sort.Ints(obj_nrs)
+	return obj_nrs, nil
+}
@@ -0,0 +1,84 @@
+package gopdf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// A form with enough fields and dict keys for map order to show if it leaks into the output
+var reproducible_form = formPDF(4,
+	"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] >>",
+	"<< /Type /Annot /Subtype /Widget /FT /Tx /T (street) /Rect [72 560 272 580] /MaxLen 40 >>",
+	"<< /Type /Annot /Subtype /Widget /FT /Tx /T (city) /Rect [72 520 272 540] /DA (/TiRo 10 Tf 0 g) >>",
+	"<< /Type /Annot /Subtype /Widget /FT /Btn /T (agree) /Rect [72 480 84 492] /V /Off /AS /Off /AP << /N << /Yes 9 0 R /Off 9 0 R >> >> >>",
+	pdfStream("<< /Type /XObject /Subtype /Form /BBox [0 0 12 12] /Resources << >>", "0 0 m 12 12 l S"),
+)
+
+var reproducible_values = map[string]interface{}{"name": "O'Brien (Jr.)", "street": "Hauptstraße 1", "city": "名前", "agree": true}
+
+func reproducibleHash(t *testing.T, options GenerateOptions) [sha256.Size]byte {
+	t.Helper()
+	var out bytes.Buffer
+	options.Reproducible = true
+	if _, err := Generate(context.Background(), reproducible_values, "", []InputFile{BytesFile("form.pdf", reproducible_form)}, &out, options); err != nil {
+		t.Fatal(err)
+	}
+	return sha256.Sum256(out.Bytes())
+}
+
+func TestReproducibleSameBytes(t *testing.T) {
+	for _, options := range []GenerateOptions{{}, {Flatten: true}, {ReproducibleDate: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}} {
+		want := reproducibleHash(t, options)
+		for i := 0; i < 20; i++ {
+			if got := reproducibleHash(t, options); got != want {
+				t.Fatalf("%+v: run %d hashes to %x, the first to %x", options, i+2, got, want)
+			}
+		}
+	}
+	if reproducibleHash(t, GenerateOptions{}) == reproducibleHash(t, GenerateOptions{ReproducibleDate: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}) {
+		t.Error("a different ReproducibleDate gave the same bytes")
+	}
+}
+
+func TestReproducibleFiles(t *testing.T) {
+	// Two runs to files, the same as the one into memory and without the time of day in them
+	input_files := []InputFile{BytesFile("form.pdf", reproducible_form)}
+	hashes := make([][sha256.Size]byte, 2)
+	for i := range hashes {
+		out_path := filepath.Join(t.TempDir(), fmt.Sprintf("filled%d.pdf", i))
+		if _, err := Generate(context.Background(), reproducible_values, out_path, input_files, nil, GenerateOptions{Reproducible: true}); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(out_path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[i] = sha256.Sum256(content)
+		if i > 0 {
+			continue
+		}
+		ctx, err := api.ReadContext(bytes.NewReader(content), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if date := textEntry(ctx, info["CreationDate"]); date != pdfcpu.DateString(ReproducibleEpoch) {
+			t.Errorf("CreationDate is %s, want ReproducibleEpoch's %s", date, pdfcpu.DateString(ReproducibleEpoch))
+		}
+	}
+	if hashes[0] != hashes[1] || hashes[0] != reproducibleHash(t, GenerateOptions{}) {
+		t.Errorf("got %x and %x, want the same as into memory", hashes[0], hashes[1])
+	}
+}
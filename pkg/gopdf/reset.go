@@ -0,0 +1,117 @@
+package gopdf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ResetForm clears the fields of in_file, the way a viewer's reset button does, and writes
+// the result to out_path. Every field goes back to its default value (DV), one without a
+// default is emptied and checkboxes and radio buttons are turned Off. Signature fields keep
+// their signatures. With names only the fields of those fully qualified names are reset,
+// names that aren't fields are ErrFieldsNotFound. Returns the names of the fields reset.
+func ResetForm(in_file InputFile, out_path string, names []string) ([]string, error) {
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return nil, err
+	}
+	file_error := func(err error) error {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return nil, file_error(err)
+	}
+	adict, err := ctx.DereferenceDict(cat["AcroForm"])
+	if err != nil {
+		return nil, file_error(err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+	found := make(map[string]bool)
+	reset := make([]string, 0)
+	if adict != nil {
+		fields, err := ctx.DereferenceArray(adict["Fields"])
+		if err != nil {
+			return nil, file_error(err)
+		}
+		err = walkFields(ctx, fields, func(field_name string, d pdfcpu.Dict) error {
+			if len(names) > 0 && !wanted[field_name] {
+				return nil
+			}
+			found[field_name] = true
+			was_reset, err := resetField(ctx, d)
+			if err != nil {
+				return fmt.Errorf("resetting %q: %v", field_name, err)
+			}
+			if was_reset {
+				reset = append(reset, field_name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, file_error(err)
+		}
+		if len(found) == 0 && hasXFA(adict) {
+			return nil, file_error(ErrXFAForm)
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, name := range names {
+		if !found[name] && !containsString(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: %s", ErrFieldsNotFound, strings.Join(missing, ", "))
+	}
+
+	if len(reset) > 0 {
+		// Like after filling, viewers should draw the fields again with their new values
+		adict["NeedAppearances"] = pdfcpu.Boolean(true)
+	}
+	return reset, writeContextFile(ctx, out_path)
+}
+
+func resetField(ctx *pdfcpu.Context, d pdfcpu.Dict) (bool, error) {
+	/*
+		Sets V of the field d back to its DV, or takes it out when there's none.
+		Buttons go through fillButton so their widgets' AS follow, a DV that isn't one
+		of their states turns them Off. Push buttons and signatures aren't reset.
+	*/
+	ft, _ := inheritedAttr(ctx, d, "FT").(pdfcpu.Name)
+	dv := inheritedAttr(ctx, d, "DV")
+	switch ft {
+	case "Sig":
+		return false, nil
+	case "Btn":
+		ff, _ := inheritedAttr(ctx, d, "Ff").(pdfcpu.Integer)
+		if ff&(1<<16) > 0 {
+			return false, nil
+		}
+		state := "Off"
+		if name, ok := dv.(pdfcpu.Name); ok {
+			state = string(name)
+		}
+		if err := fillButton(ctx, d, state); err != nil {
+			return true, fillButton(ctx, d, "Off")
+		}
+		return true, nil
+	}
+	// Selected indices of a list box would select the old options again
+	delete(d, "I")
+	if dv == nil {
+		delete(d, "V")
+	} else {
+		d["V"] = dv
+	}
+	return true, nil
+}
@@ -0,0 +1,37 @@
+package gopdf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// Rotate turns the selected pages of in_file clockwise by rotation degrees
+// (90, 180, 270 or -90) and writes the result to out_path.
+// pages uses pdfcpu's page selection syntax (e.g. "1-3,5"), empty means every page.
+func Rotate(in_file InputFile, out_path string, rotation int, pages string) error {
+	if !ValidRotation(rotation) {
+		return fmt.Errorf("rotation must be 90, 180, 270 or -90, got %d", rotation)
+	}
+	var page_selection []string
+	if pages != "" {
+		var err error
+		page_selection, err = api.ParsePageSelection(pages)
+		if err != nil {
+			return err
+		}
+	}
+	return transformFile(in_file, out_path, nil, func(rs io.ReadSeeker, w io.Writer) error {
+		return api.Rotate(rs, w, rotation, page_selection, in_file.configuration())
+	})
+}
+
+// ValidRotation tells if rotation is one Rotate takes
+func ValidRotation(rotation int) bool {
+	switch rotation {
+	case 90, 180, 270, -90:
+		return true
+	}
+	return false
+}
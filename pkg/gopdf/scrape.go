@@ -0,0 +1,250 @@
+package gopdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// Field is what Scrape reports for every form field
+type Field struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	// Choice fields: their options, and whether more than one can be picked (then Value
+	// is always a list of export values)
+	Options     []ChoiceOption `json:"options,omitempty"`
+	MultiSelect bool           `json:"multi_select,omitempty"`
+	// DefaultValue is what the field resets to (DV), nil when it has none
+	DefaultValue interface{} `json:"default_value"`
+	ReadOnly     bool        `json:"read_only"`
+	// Text fields: the most characters they take, 0 for no limit, and whether they're
+	// comb fields drawing every character in a cell of its own
+	MaxLen int  `json:"max_len,omitempty"`
+	Comb   bool `json:"comb,omitempty"`
+	// Where the field is drawn, one widget per radio button of a group
+	Widgets []Widget `json:"widgets,omitempty"`
+	// Where the field comes when tabbing through the file, from 1, and whether that's up to
+	// the viewer because the page doesn't say (no Tabs) or the field is on no page
+	TabOrder          int  `json:"tab_order,omitempty"`
+	TabOrderUndefined bool `json:"tab_order_undefined,omitempty"`
+}
+
+// ScrapeWorkers is how many files Scrape works on at the same time, 0 means runtime.GOMAXPROCS(0)
+var ScrapeWorkers = 0
+
+// Scrape gets the AcroForm fields of every input file.
+// Files are read concurrently but the fields come back in input order.
+// Files that can't be read don't stop the others, they come back as FileErrors,
+// and so does a file taking longer than FileTimeout (ErrTimeout).
+// Once ctx is done the rest is abandoned and ctx.Err() returned.
+func Scrape(ctx context.Context, input_files []InputFile) ([]Field, error) {
+	/*
+		Gets AcroForm data from files and returns a list of fields
+			[{"name": "foo_bar", ...}, {"name": "bar_mitzvah", ...}]
+	*/
+
+	/*
+		This command checks inFile for compliance with the specification PDF 32000-1:2008 (PDF 1.7).
+		 Any PDF file you would like to process needs to pass validation.
+	*/
+
+	// Every file gets its own slot, they're done in whatever order
+	file_fields := make([][]Field, len(input_files))
+	file_failures := make([]*FileError, len(input_files))
+	err := ScrapeEach(ctx, input_files, func(idx int, fields []Field, file_error *FileError) {
+		file_fields[idx], file_failures[idx] = fields, file_error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// This is how you create an array of variable length
+	acro_fields := make([]Field, 0)
+	file_errors := make(FileErrors, 0)
+	for idx := range input_files {
+		acro_fields = append(acro_fields, file_fields[idx]...)
+		if file_failures[idx] != nil {
+			file_errors = append(file_errors, file_failures[idx])
+		}
+	}
+	if len(file_errors) > 0 {
+		return acro_fields, file_errors
+	}
+	return acro_fields, nil
+}
+
+// ScrapeEach is Scrape calling done with the fields or the FileError of every file as soon as
+// it's read, in the order they finish. done is never called by two files at once.
+// Once ctx is done the files left aren't read and ctx.Err() is returned.
+func ScrapeEach(ctx context.Context, input_files []InputFile, done func(idx int, fields []Field, file_error *FileError)) error {
+	workers := ScrapeWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(input_files) {
+		workers = len(input_files)
+	}
+
+	type scraped struct {
+		idx        int
+		fields     []Field
+		file_error *FileError
+	}
+	jobs := make(chan int)
+	results := make(chan scraped)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if ctx.Err() != nil {
+					// Just drain what's left
+					continue
+				}
+				fields, file_error := scrapeFileWithin(ctx, idx, input_files[idx])
+				results <- scraped{idx, fields, file_error}
+			}
+		}()
+	}
+	go func() {
+		for idx := range input_files {
+			jobs <- idx
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	// done runs here, on the caller's goroutine
+	for result := range results {
+		if ctx.Err() == nil {
+			done(result.idx, result.fields, result.file_error)
+		}
+	}
+	return ctx.Err()
+}
+
+// scrapeFile reads the fields of one input file, the file is closed before it returns
+func scrapeFile(idx int, in_file InputFile) ([]Field, *FileError) {
+	//this uses an io.ReadSeeker, validated already
+	f, err := openValidated(in_file)
+	if err != nil {
+		return nil, &FileError{Idx: idx, Name: in_file.Name, Err: err}
+	}
+	//Close the file this ain't python!
+	defer f.Close()
+
+	// Get AcroForm fields, the password if any has to go to every pdfcpu call that reads the file
+	acro_fields, err := getAcro(f, in_file.configuration())
+	if err != nil {
+		return nil, &FileError{Idx: idx, Name: in_file.Name, Err: err}
+	}
+	return acro_fields, nil
+}
+
+// scrapeFileWithin is scrapeFile given up on after FileTimeout or once ctx is done
+func scrapeFileWithin(ctx context.Context, idx int, in_file InputFile) ([]Field, *FileError) {
+	acro_fields, err := runFile(ctx, func() ([]Field, error) {
+		acro_fields, file_error := scrapeFile(idx, in_file)
+		if file_error != nil {
+			return nil, file_error
+		}
+		return acro_fields, nil
+	})
+	var file_error *FileError
+	if errors.As(err, &file_error) {
+		return nil, file_error
+	}
+	if err != nil {
+		// ErrTimeout, or ctx being done which Scrape reports for the whole call
+		return nil, &FileError{Idx: idx, Name: in_file.Name, Err: err}
+	}
+	return acro_fields, nil
+}
+
+func getAcro(source io.ReadSeeker, conf *pdfcpu.Configuration) ([]Field, error) {
+	/*
+		Reads the AcroForm fields of one file, a file without an AcroForm just has no fields.
+		Anything broken on the way there is returned so the caller can report it.
+		Only reads: nothing in ctx is changed and nothing is written anywhere.
+	*/
+	ctx, err := api.ReadContext(source, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	cat, err := ctx.Catalog()
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %v", err)
+	}
+
+	acroform, ok := cat.Find("AcroForm")
+	if !ok {
+		return []Field{}, nil
+	}
+
+	adict, err := ctx.DereferenceDict(acroform)
+	if err != nil {
+		return nil, fmt.Errorf("AcroForm: %v", err)
+	}
+	if adict == nil {
+		return nil, errors.New("AcroForm: no xref entry for the AcroForm dict")
+	}
+
+	fields, err := ctx.DereferenceArray(adict["Fields"])
+	if err != nil {
+		return nil, fmt.Errorf("AcroForm Fields: %v", err)
+	}
+
+	// A page tree too broken to walk leaves the fields without widgets, they're still fields
+	widgets, _ := fieldWidgets(ctx)
+	tab_orders, tab_orders_undefined, _ := tabOrders(ctx)
+
+	acro_fields := make([]Field, 0)
+	err = walkFields(ctx, fields, func(field_name string, d pdfcpu.Dict) error {
+		if field_name == "" {
+			return errors.New("AcroForm field without a name (T)")
+		}
+		field := Field{Name: field_name, Value: fieldValue(ctx, inheritedAttr(ctx, d, "V")), DefaultValue: fieldValue(ctx, inheritedAttr(ctx, d, "DV"))}
+		// Ff, V and DV are inherited from the parent fields unless the field sets its own, bit 1 of Ff is ReadOnly
+		ff, _ := inheritedAttr(ctx, d, "Ff").(pdfcpu.Integer)
+		field.ReadOnly = ff&1 > 0
+		if ft, ok := inheritedAttr(ctx, d, "FT").(pdfcpu.Name); ok {
+			field.Type = string(ft)
+		}
+		if field.Type == "Ch" {
+			field.Options = choiceOptions(ctx, d["Opt"])
+			// Bit 22 of Ff, list boxes only (combo boxes are bit 18)
+			field.MultiSelect = ff&(1<<21) > 0 && ff&(1<<17) == 0
+			if field.MultiSelect {
+				field.Value = multiSelectValue(field.Value)
+				if field.DefaultValue != nil {
+					field.DefaultValue = multiSelectValue(field.DefaultValue)
+				}
+			}
+		}
+		if field.Type == "Tx" {
+			field.MaxLen, field.Comb = maxLen(ctx, d)
+		}
+		field.Widgets = widgets[field_name]
+		field.TabOrder = tab_orders[field_name]
+		field.TabOrderUndefined = field.TabOrder == 0 || tab_orders_undefined[field_name]
+		acro_fields = append(acro_fields, field)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// An empty AcroForm next to an XFA form isn't a form without fields
+	if len(acro_fields) == 0 && hasXFA(adict) {
+		return nil, ErrXFAForm
+	}
+	return acro_fields, nil
+}
@@ -0,0 +1,98 @@
+package gopdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestScrapeParentTypedGroup(t *testing.T) {
+	// FT, Ff, V and DV are all on the parent, the kid only has its name and widget
+	content := formPDF(1,
+		"<< /T (address) /FT /Tx /Ff 1 /V (12345) /DV (00000) /Kids [6 0 R] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 5 0 R /T (zip) /Rect [72 480 172 500] >>",
+	)
+	fields, err := getAcro(bytes.NewReader(content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 {
+		t.Fatalf("got %d fields, want address.zip only", len(fields))
+	}
+	field := fields[0]
+	if field.Name != "address.zip" || field.Type != "Tx" || !field.ReadOnly {
+		t.Errorf("got %s of type %q, read only %v, want address.zip, Tx and read only", field.Name, field.Type, field.ReadOnly)
+	}
+	if field.Value != "12345" || field.DefaultValue != "00000" {
+		t.Errorf("got value %v and default %v, want the parent's 12345 and 00000", field.Value, field.DefaultValue)
+	}
+}
+
+func TestScrapeOwnValueOverParent(t *testing.T) {
+	content := formPDF(1,
+		"<< /T (address) /FT /Tx /V (12345) /Kids [6 0 R] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 5 0 R /T (zip) /V (67890) /Rect [72 480 172 500] >>",
+	)
+	fields, err := getAcro(bytes.NewReader(content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || fields[0].Value != "67890" {
+		t.Errorf("got %+v, want address.zip with its own value 67890", fields)
+	}
+}
+
+func TestScrapeConcurrent(t *testing.T) {
+	/*
+		Scrapes of one form running at once, next to fills of it with other values, all
+		see the values the file has: nothing on the way writes to what they share, the
+		template cache's bytes included. Meant for go test -race.
+	*/
+	content := formPDF(2,
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] /V (Jane Doe) >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (city) /Rect [72 560 272 580] /V (Berlin) >>",
+	)
+	want := map[string]interface{}{"name": "Jane Doe", "city": "Berlin"}
+	in_path := writeTestFile(t, "form.pdf", content)
+	for _, cache_size := range []int64{0, 1 << 20} {
+		SetTemplateCacheSize(cache_size)
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				fields, err := Scrape(context.Background(), []InputFile{PathFile(in_path), PathFile(in_path), BytesFile("form.pdf", content)})
+				if err != nil {
+					errs <- err
+					return
+				}
+				for _, field := range fields {
+					if field.Value != want[field.Name] {
+						errs <- fmt.Errorf("scraped %s as %v, want %v", field.Name, field.Value, want[field.Name])
+					}
+				}
+			}()
+			go func(i int) {
+				defer wg.Done()
+				values := map[string]interface{}{"name": fmt.Sprintf("filler %d", i), "city": "Paris"}
+				if _, err := Generate(context.Background(), values, "", []InputFile{PathFile(in_path)}, io.Discard, GenerateOptions{}); err != nil {
+					errs <- err
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("cache size %d: %v", cache_size, err)
+		}
+	}
+	SetTemplateCacheSize(0)
+	if on_disk, err := os.ReadFile(in_path); err != nil || !bytes.Equal(on_disk, content) {
+		t.Errorf("the input file changed (%v)", err)
+	}
+}
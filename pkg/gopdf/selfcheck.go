@@ -0,0 +1,85 @@
+package gopdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// SelfCheck writes an empty PDF in memory and reads it back through pdfcpu's
+// validation, for a healthcheck to tell whether pdfcpu works at all
+func SelfCheck() error {
+	conf := pdfcpu.NewDefaultConfiguration()
+	ctx, err := pdfcpu.CreateContextWithXRefTable(conf, pdfcpu.PaperSize["A4"])
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = api.WriteContext(ctx, &buf); err != nil {
+		return err
+	}
+	return api.Validate(bytes.NewReader(buf.Bytes()), conf)
+}
+
+// MergeSelfCheck merges two one-field forms in memory and checks that both fields made it into
+// the result. Merging leans on pdfcpu internals more than anything else (MergeXRefTables and the
+// catalog layout mergeAcroForms expects), this is what tells a pdfcpu upgrade broke it.
+func MergeSelfCheck() error {
+	contexts := make([]*pdfcpu.Context, 2)
+	input_files := make([]InputFile, 2)
+	for i, name := range []string{"first", "second"} {
+		ctx, err := api.ReadContext(bytes.NewReader(selfCheckForm(name)), nil)
+		if err != nil {
+			return err
+		}
+		contexts[i] = ctx
+		input_files[i] = InputFile{Name: name + ".pdf"}
+	}
+	ctx, err := mergedContext(input_files, contexts)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = api.WriteContext(ctx, &buf); err != nil {
+		return err
+	}
+	fields, err := getAcro(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		return err
+	}
+	if len(fields) != 2 || fields[0].Name != "first" || fields[1].Name != "second" {
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = field.Name
+		}
+		return fmt.Errorf("merged form has the fields [%s] instead of [first second]", strings.Join(names, " "))
+	}
+	return nil
+}
+
+func selfCheckForm(field_name string) []byte {
+	// A one page PDF with a single text field field_name
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R] /DA (/Helv 0 Tf 0 g) >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [4 0 R] /Resources << >> >>",
+		fmt.Sprintf("<< /Type /Annot /Subtype /Widget /FT /Tx /T (%s) /Rect [10 10 100 30] /P 3 0 R >>", field_name),
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref_offset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref_offset)
+	return buf.Bytes()
+}
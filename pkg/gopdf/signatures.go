@@ -0,0 +1,108 @@
+package gopdf
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// Signature is what Info reports for every signature field (FT Sig) of a form.
+// Nothing is verified, the entries are what the signature dictionary claims.
+type Signature struct {
+	Field string `json:"field"`
+	// A signature field without a signature dictionary (V) is still to be signed
+	Signed bool `json:"signed"`
+	// The signer, reason, location and contact info as the signing software put them in
+	Name        string `json:"name,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Location    string `json:"location,omitempty"`
+	ContactInfo string `json:"contact_info,omitempty"`
+	// M, as RFC 3339 when it's a proper PDF date and as written otherwise
+	SigningTime string `json:"signing_time,omitempty"`
+	// The signature handler and format, like Adobe.PPKLite and adbe.pkcs7.detached
+	Filter    string `json:"filter,omitempty"`
+	SubFilter string `json:"sub_filter,omitempty"`
+	// Offset and length pairs of the bytes of the file the signature covers
+	ByteRange []int `json:"byte_range,omitempty"`
+	// Whether ByteRange runs to the end of the file, when it doesn't the file was
+	// changed (appended to) after it was signed
+	CoversWholeFile bool `json:"covers_whole_file,omitempty"`
+}
+
+var pdf_date = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(?:(Z)|([+-])(\d{2})'?(?:(\d{2})'?)?)?`)
+
+func signatures(ctx *pdfcpu.Context, fields pdfcpu.Array) []Signature {
+	/*
+		Every signature field in fields, in the form's order. A broken signature dictionary
+		or entry is left out, like the Info dict entries it's not worth failing the file over.
+	*/
+	sigs := make([]Signature, 0)
+	walkFields(ctx, fields, func(field_name string, d pdfcpu.Dict) error {
+		if ft, ok := inheritedAttr(ctx, d, "FT").(pdfcpu.Name); !ok || ft != "Sig" {
+			return nil
+		}
+		sig := Signature{Field: field_name}
+		v, err := ctx.DereferenceDict(inheritedAttr(ctx, d, "V"))
+		if err != nil || v == nil {
+			sigs = append(sigs, sig)
+			return nil
+		}
+		sig.Signed = true
+		sig.Name = infoText(ctx, v, "Name")
+		sig.Reason = infoText(ctx, v, "Reason")
+		sig.Location = infoText(ctx, v, "Location")
+		sig.ContactInfo = infoText(ctx, v, "ContactInfo")
+		sig.SigningTime = pdfDate(infoText(ctx, v, "M"))
+		if filter := v.NameEntry("Filter"); filter != nil {
+			sig.Filter = *filter
+		}
+		if sub_filter := v.NameEntry("SubFilter"); sub_filter != nil {
+			sig.SubFilter = *sub_filter
+		}
+		byte_range, err := ctx.DereferenceArray(v["ByteRange"])
+		if err == nil && len(byte_range) > 0 {
+			sig.ByteRange = make([]int, 0, len(byte_range))
+			for _, o := range byte_range {
+				if i, err := ctx.DereferenceInteger(o); err == nil && i != nil {
+					sig.ByteRange = append(sig.ByteRange, i.Value())
+				}
+			}
+			if n := len(sig.ByteRange); n >= 2 && n%2 == 0 {
+				sig.CoversWholeFile = sig.ByteRange[0] == 0 && int64(sig.ByteRange[n-2]+sig.ByteRange[n-1]) == ctx.Read.FileSize
+			}
+		}
+		sigs = append(sigs, sig)
+		return nil
+	})
+	return sigs
+}
+
+func pdfDate(s string) string {
+	/*
+		A PDF date (D:YYYYMMDDHHmmSSOHH'mm', everything after the year optional) as RFC 3339,
+		s itself when it isn't one. A date without a time zone is taken as UTC.
+	*/
+	match := pdf_date.FindStringSubmatch(s)
+	if match == nil {
+		return s
+	}
+	part := func(i int, fallback int) int {
+		if match[i] == "" {
+			return fallback
+		}
+		n, _ := strconv.Atoi(match[i])
+		return n
+	}
+	location := time.UTC
+	if match[8] != "" {
+		offset := part(9, 0)*3600 + part(10, 0)*60
+		if match[8] == "-" {
+			offset = -offset
+		}
+		location = time.FixedZone("", offset)
+	}
+	t := time.Date(part(1, 0), time.Month(part(2, 1)), part(3, 1), part(4, 0), part(5, 0), part(6, 0), 0, location)
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,82 @@
+package gopdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Readers look for the %PDF- header this far into the file
+const pdf_header_window = 1024
+
+// NotPDFError is what CheckPDF returns for a file without a PDF header,
+// Signature is what the file looks like instead
+type NotPDFError struct {
+	Signature string
+}
+
+func (e *NotPDFError) Error() string {
+	return fmt.Sprintf("not a PDF, there is no %%PDF- header (looks like %s)", e.Signature)
+}
+
+// What files commonly uploaded by mistake start with
+var file_signatures = []struct {
+	magic []byte
+	name  string
+}{
+	{[]byte("PK\x03\x04"), "a ZIP archive, docx or xlsx"},
+	{[]byte("\x89PNG\r\n\x1a\n"), "a PNG image"},
+	{[]byte("\xff\xd8\xff"), "a JPEG image"},
+	{[]byte("GIF8"), "a GIF image"},
+	{[]byte("II*\x00"), "a TIFF image"},
+	{[]byte("MM\x00*"), "a TIFF image"},
+	{[]byte("%FDF-"), "an FDF file"},
+	{[]byte("%!PS"), "a PostScript file"},
+	{[]byte("\x1f\x8b"), "a gzip file"},
+	{[]byte("\xd0\xcf\x11\xe0"), "an old Office document"},
+	{[]byte("{\\rtf"), "an RTF document"},
+	{[]byte("<"), "XML or HTML"},
+	{[]byte("{"), "JSON"},
+}
+
+// CheckPDF reads the start of in_file and returns a NotPDFError when there's no %PDF-
+// header in it. It looks at the first 1024 bytes like readers do, some files have junk before it.
+func CheckPDF(in_file InputFile) error {
+	f, err := in_file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, pdf_header_window)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+	if bytes.Contains(head, []byte("%PDF-")) {
+		return nil
+	}
+	return &NotPDFError{Signature: fileSignature(head)}
+}
+
+func fileSignature(head []byte) string {
+	if len(head) == 0 {
+		return "an empty file"
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	for _, signature := range file_signatures {
+		if bytes.HasPrefix(head, signature.magic) || bytes.HasPrefix(trimmed, signature.magic) {
+			return signature.name
+		}
+	}
+	if utf8.Valid(trimmed) && bytes.IndexFunc(trimmed, func(r rune) bool { return unicode.IsControl(r) && !unicode.IsSpace(r) }) < 0 {
+		return "a text file"
+	}
+	// Unknown, the bytes themselves are the best we can say
+	if len(head) > 8 {
+		head = head[:8]
+	}
+	return fmt.Sprintf("something starting with % x", head)
+}
@@ -0,0 +1,62 @@
+package gopdf
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// Split breaks in_file into files of span pages each, written to out_dir.
+// It returns the paths of the files it wrote, in page order.
+// An input file that can't be read is reported as a FileError.
+func Split(in_file InputFile, out_dir string, span int) ([]string, error) {
+	/*
+		Files are named like pdfcpu names them: <name>_<from>-<thru>.pdf,
+		or <name>_<page>.pdf when a file only has one page.
+	*/
+	if span < 1 {
+		return nil, errors.New("span must be at least 1")
+	}
+
+	f, err := openValidated(in_file)
+	if err != nil {
+		return nil, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	//Close the file this ain't python!
+	defer f.Close()
+
+	page_count, err := api.PageCount(f, in_file.configuration())
+	if err != nil {
+		return nil, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+
+	f.Seek(0, io.SeekStart)
+	file_name := filepath.Base(in_file.Name)
+	err = api.Split(f, out_dir, file_name, span, in_file.configuration())
+	if err != nil {
+		return nil, err
+	}
+
+	out_paths := make([]string, 0, (page_count+span-1)/span)
+	for from := 1; from <= page_count; from += span {
+		thru := from + span - 1
+		if thru > page_count {
+			thru = page_count
+		}
+		out_paths = append(out_paths, filepath.Join(out_dir, splitFileName(file_name, from, thru)))
+	}
+	return out_paths, nil
+}
+
+func splitFileName(file_name string, from, thru int) string {
+	// Same names api.Split gives its files
+	name := strings.TrimSuffix(file_name, ".pdf") + "_" + strconv.Itoa(from)
+	if from == thru {
+		return name + ".pdf"
+	}
+	return name + "-" + strconv.Itoa(thru) + ".pdf"
+}
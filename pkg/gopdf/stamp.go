@@ -0,0 +1,236 @@
+package gopdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// StampOptions is what Stamp puts on the pages and how
+type StampOptions struct {
+	// Type is "text", "image" or "pdf"
+	Type string
+	// Text is the text of a text stamp, \n starts a new line
+	Text string
+	// File is the image or PDF (its first page) of an image or pdf stamp
+	File InputFile
+	// Opacity is between 0 and 1, Rotation in degrees between -180 and 180,
+	// Scale relative to the page between 0 and 1. nil leaves pdfcpu's default
+	Opacity  *float64
+	Rotation *float64
+	Scale    *float64
+	// Watermark puts it behind the page content instead of on top
+	Watermark bool
+	// Pages uses pdfcpu's page selection syntax (e.g. "1-3,5"), empty means every page
+	Pages string
+	// ID tags the stamp so Unstamp can take off just the stamps with that ID later
+	ID string
+}
+
+// StampTypes are the stamp types Stamp knows about
+var StampTypes = []string{"text", "image", "pdf"}
+
+// Stamp puts a text, image or PDF stamp on the selected pages of in_file and writes the
+// result to out_path, or to out when out_path is empty.
+// in_file or the stamp file being no good is a FileError.
+func Stamp(in_file InputFile, out_path string, out io.Writer, options StampOptions) error {
+	/*
+		pdfcpu takes the stamp styling as a description string like "opacity:0.5, rotation:45",
+		it's built from the options here so pdfcpu does all the checking of the values.
+	*/
+	if !containsString(StampTypes, options.Type) {
+		return fmt.Errorf("stamp type must be one of %s, got %q", strings.Join(StampTypes, ", "), options.Type)
+	}
+	if options.Opacity != nil && (*options.Opacity < 0 || *options.Opacity > 1) {
+		return fmt.Errorf("opacity must be between 0 and 1, got %v", *options.Opacity)
+	}
+	var page_selection []string
+	if options.Pages != "" {
+		var err error
+		page_selection, err = api.ParsePageSelection(options.Pages)
+		if err != nil {
+			return err
+		}
+	}
+
+	description := make([]string, 0)
+	if options.Opacity != nil {
+		description = append(description, fmt.Sprintf("opacity:%v", *options.Opacity))
+	}
+	if options.Rotation != nil {
+		description = append(description, fmt.Sprintf("rotation:%v", *options.Rotation))
+	}
+	if options.Scale != nil {
+		description = append(description, fmt.Sprintf("scalefactor:%v", *options.Scale))
+	}
+	on_top := !options.Watermark
+
+	var watermark *pdfcpu.Watermark
+	var err error
+	switch options.Type {
+	case "text":
+		if options.Text == "" {
+			return errors.New("a text stamp needs text")
+		}
+		watermark, err = pdfcpu.ParseTextWatermarkDetails(options.Text, strings.Join(description, ", "), on_top, pdfcpu.POINTS)
+	case "image":
+		if options.File.Open == nil {
+			return errors.New("an image stamp needs an image file")
+		}
+		// With no file name pdfcpu takes the image from watermark.Image
+		watermark, err = pdfcpu.ParseImageWatermarkDetails("", strings.Join(description, ", "), on_top, pdfcpu.POINTS)
+		if err == nil {
+			watermark.Image, err = readStampFile(options.File)
+			if err != nil {
+				return &FileError{Idx: 0, Name: options.File.Name, Err: err}
+			}
+		}
+	case "pdf":
+		if options.File.Open == nil {
+			return errors.New("a pdf stamp needs a PDF file")
+		}
+		// pdfcpu only reads PDF stamps from a path, so uploads get copied to one first
+		var stamp_path string
+		stamp_path, err = stampFilePath(options.File)
+		if err != nil {
+			return &FileError{Idx: 0, Name: options.File.Name, Err: err}
+		}
+		defer removeTemp(stamp_path)
+		watermark, err = pdfcpu.ParsePDFWatermarkDetails(stamp_path+":1", strings.Join(description, ", "), on_top, pdfcpu.POINTS)
+	}
+	if err != nil {
+		return err
+	}
+
+	return transformFile(in_file, out_path, out, func(rs io.ReadSeeker, w io.Writer) error {
+		if options.ID != "" {
+			return addTaggedWatermarks(rs, w, page_selection, watermark, options.ID, in_file.configuration())
+		}
+		return api.AddWatermarks(rs, w, page_selection, watermark, in_file.configuration())
+	})
+}
+
+// The key of the stamp's form XObject holding the StampOptions ID
+const stamp_id_key = "GopdfStampID"
+
+func addTaggedWatermarks(rs io.ReadSeeker, w io.Writer, page_selection []string, watermark *pdfcpu.Watermark, id string, conf *pdfcpu.Configuration) error {
+	/*
+		api.AddWatermarks, done on the context so the form XObjects pdfcpu adds for the
+		stamp (the names new in each page's XObject resources) can be tagged with id
+	*/
+	if conf == nil {
+		conf = pdfcpu.NewDefaultConfiguration()
+	}
+	conf.Cmd = pdfcpu.ADDWATERMARKS
+	ctx, err := api.ReadContext(rs, conf)
+	if err != nil {
+		return err
+	}
+	if conf.ValidationMode != pdfcpu.ValidationNone {
+		if err = api.ValidateContext(ctx); err != nil {
+			return err
+		}
+	}
+	if err = api.OptimizeContext(ctx); err != nil {
+		return err
+	}
+	if err = ctx.EnsurePageCount(); err != nil {
+		return err
+	}
+	pages, err := api.PagesForPageSelection(ctx.PageCount, page_selection, true)
+	if err != nil {
+		return err
+	}
+
+	// pdfcpu adds to the resource dicts in place, so it's the names that are kept
+	before := make(map[int]map[string]bool)
+	for page_nr, selected := range pages {
+		if !selected {
+			continue
+		}
+		xobjects, err := pageXObjects(ctx, page_nr)
+		if err != nil {
+			return err
+		}
+		before[page_nr] = make(map[string]bool)
+		for name := range xobjects {
+			before[page_nr][name] = true
+		}
+	}
+	if err = ctx.AddWatermarks(pages, watermark); err != nil {
+		return err
+	}
+	for page_nr, selected := range pages {
+		if !selected {
+			continue
+		}
+		xobjects, err := pageXObjects(ctx, page_nr)
+		if err != nil {
+			return err
+		}
+		for name, o := range xobjects {
+			if before[page_nr][name] {
+				continue
+			}
+			if sd, _, err := ctx.DereferenceStreamDict(o); err == nil && sd != nil {
+				sd.Insert(stamp_id_key, textObject(id))
+			}
+		}
+	}
+	return api.WriteContext(ctx, w)
+}
+
+func pageXObjects(ctx *pdfcpu.Context, page_nr int) (pdfcpu.Dict, error) {
+	// The XObject resources of page page_nr itself, nil when it has none
+	d, _, _, err := ctx.PageDict(page_nr, false)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	resources, err := ctx.DereferenceDict(d["Resources"])
+	if err != nil || resources == nil {
+		return nil, err
+	}
+	return ctx.DereferenceDict(resources["XObject"])
+}
+
+func readStampFile(stamp_file InputFile) (io.Reader, error) {
+	f, err := stamp_file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+func stampFilePath(stamp_file InputFile) (string, error) {
+	f, err := stamp_file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	tmp, err := createTemp("", "gopdf-stamp-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, f)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		removeTemp(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,169 @@
+package gopdf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// How Generate and Merge leave the tab order of a form
+const (
+	// Every page keeps its Tabs and the order of its Annots
+	TabOrderPreserve = "preserve"
+	// Every page with widgets tabs through them in rows, top to bottom and left to right
+	// (Tabs R), and lists them in that order in its Annots for viewers that go by those
+	TabOrderRows = "rows"
+)
+
+// CheckTabOrder returns an error for a tab order that isn't TabOrderPreserve or TabOrderRows,
+// empty is TabOrderPreserve
+func CheckTabOrder(tab_order string) error {
+	if tab_order != "" && tab_order != TabOrderPreserve && tab_order != TabOrderRows {
+		return fmt.Errorf("tab_order must be %q or %q, got %q", TabOrderPreserve, TabOrderRows, tab_order)
+	}
+	return nil
+}
+
+// A widget of a page with where it is, for putting widgets in tab order
+type tabWidget struct {
+	o pdfcpu.Object
+	d pdfcpu.Dict
+	// llx, lly, urx, ury
+	rect [4]float64
+}
+
+func pageTabWidgets(ctx *pdfcpu.Context, page_dict pdfcpu.Dict) ([]tabWidget, []int, error) {
+	// The widgets in the Annots of page_dict in tab order, and where in Annots they are
+	annots, err := ctx.DereferenceArray(page_dict["Annots"])
+	if err != nil {
+		return nil, nil, err
+	}
+	widgets := make([]tabWidget, 0)
+	positions := make([]int, 0)
+	for i, o := range annots {
+		annot, err := ctx.DereferenceDict(o)
+		if err != nil || annot == nil || !isName(annot, "Subtype", "Widget") {
+			continue
+		}
+		widget := tabWidget{o: o, d: annot}
+		if rect, ok := numbers(ctx, annot["Rect"], 4); ok {
+			widget.rect = [4]float64{math.Min(rect[0], rect[2]), math.Min(rect[1], rect[3]), math.Max(rect[0], rect[2]), math.Max(rect[1], rect[3])}
+		}
+		widgets = append(widgets, widget)
+		positions = append(positions, i)
+	}
+	tabs := page_dict.NameEntry("Tabs")
+	switch {
+	case tabs != nil && *tabs == "R":
+		sortWidgets(widgets, false)
+	case tabs != nil && *tabs == "C":
+		sortWidgets(widgets, true)
+	}
+	// Structure order (S) would need the structure tree, it's taken to be the order of Annots
+	// like it is for the rest, what authoring tools write there is usually the same
+	return widgets, positions, nil
+}
+
+func sortWidgets(widgets []tabWidget, columns bool) {
+	/*
+		Puts widgets in row order: rows from the top of the page down and left to right
+		in every row, or in column order: columns from the left and top to bottom in every
+		column. A widget is in the row of the first widget of that row when its middle is
+		level with that one, fields side by side are seldom exactly as high.
+	*/
+	across, down := func(w tabWidget) (float64, float64) { return w.rect[0], w.rect[2] }, func(w tabWidget) (float64, float64) { return -w.rect[3], -w.rect[1] }
+	if columns {
+		across, down = down, across
+	}
+	sort.SliceStable(widgets, func(i, j int) bool {
+		top_i, _ := down(widgets[i])
+		top_j, _ := down(widgets[j])
+		return top_i < top_j
+	})
+	for start := 0; start < len(widgets); {
+		_, bottom := down(widgets[start])
+		end := start + 1
+		for end < len(widgets) {
+			top, low := down(widgets[end])
+			if (top+low)/2 > bottom {
+				break
+			}
+			end++
+		}
+		row := widgets[start:end]
+		sort.SliceStable(row, func(i, j int) bool {
+			left_i, _ := across(row[i])
+			left_j, _ := across(row[j])
+			return left_i < left_j
+		})
+		start = end
+	}
+}
+
+func tabOrders(ctx *pdfcpu.Context) (map[string]int, map[string]bool, error) {
+	/*
+		Where every field comes in the tab order of the whole file, from 1, by its first
+		widget: pages in order and the widgets of each page in the order its Tabs says.
+		Fields on a page without Tabs are in undefined, viewers tab through those as they
+		see fit. Fields on no page aren't in either map.
+	*/
+	orders := make(map[string]int)
+	undefined := make(map[string]bool)
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, nil, err
+	}
+	for page_nr := 1; page_nr <= ctx.PageCount; page_nr++ {
+		page_dict, _, _, err := ctx.PageDict(page_nr, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		widgets, _, err := pageTabWidgets(ctx, page_dict)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, widget := range widgets {
+			field_name := widgetFieldName(ctx, widget.d)
+			if _, seen := orders[field_name]; field_name == "" || seen {
+				continue
+			}
+			orders[field_name] = len(orders) + 1
+			undefined[field_name] = page_dict["Tabs"] == nil
+		}
+	}
+	return orders, undefined, nil
+}
+
+func arrangeTabOrder(ctx *pdfcpu.Context) error {
+	// TabOrderRows for every page of ctx with widgets, the other annotations keep their places in Annots
+	if err := ctx.EnsurePageCount(); err != nil {
+		return err
+	}
+	for page_nr := 1; page_nr <= ctx.PageCount; page_nr++ {
+		page_dict, _, _, err := ctx.PageDict(page_nr, false)
+		if err != nil {
+			return err
+		}
+		annots, err := ctx.DereferenceArray(page_dict["Annots"])
+		if err != nil {
+			return err
+		}
+		widgets, positions, err := pageTabWidgets(ctx, page_dict)
+		if err != nil {
+			return err
+		}
+		if len(widgets) == 0 {
+			continue
+		}
+		sortWidgets(widgets, false)
+		arranged := make(pdfcpu.Array, len(annots))
+		copy(arranged, annots)
+		for i, widget := range widgets {
+			arranged[positions[i]] = widget.o
+		}
+		page_dict["Annots"] = arranged
+		page_dict["Tabs"] = pdfcpu.Name("R")
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package gopdf
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// TempDir is where temp files not tied to an output go, "" is os.TempDir()
+var TempDir = ""
+
+// The temp files of the calls in flight, so RemoveTempFiles can get rid of them
+var temp_files = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+func createTemp(dir, pattern string) (*os.File, error) {
+	// dir "" is TempDir, like ioutil.TempFile does with os.TempDir()
+	if dir == "" {
+		dir = TempDir
+	}
+	tmp, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	temp_files.Lock()
+	temp_files.paths[tmp.Name()] = true
+	temp_files.Unlock()
+	return tmp, nil
+}
+
+func removeTemp(path string) {
+	temp_files.Lock()
+	delete(temp_files.paths, path)
+	temp_files.Unlock()
+	os.Remove(path)
+}
+
+// RemoveTempFiles removes the temp files of the calls still running, for a server
+// that is shutting down without waiting for them. Those calls will fail.
+func RemoveTempFiles() {
+	temp_files.Lock()
+	defer temp_files.Unlock()
+	for path := range temp_files.paths {
+		os.Remove(path)
+		delete(temp_files.paths, path)
+	}
+}
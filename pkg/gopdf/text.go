@@ -0,0 +1,687 @@
+package gopdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ExtractText gets the text of the selected pages of every input file, keyed by file name.
+// pages uses pdfcpu's page selection syntax (e.g. "1-3,5"), empty means every page.
+// A page without text (a scanned image) comes back as "".
+// Files that can't be read don't stop the others, they come back as FileErrors.
+func ExtractText(input_files []InputFile, pages string) (map[string][]string, error) {
+	/*
+		pdfcpu can hand us the decoded content stream of a page, the text showing
+		operators in it (Tj, TJ, ' and ") are pulled out here, going through the
+		font's ToUnicode CMap when it has one.
+	*/
+	var page_selection []string
+	if pages != "" {
+		var err error
+		page_selection, err = api.ParsePageSelection(pages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	texts := make(map[string][]string)
+	file_errors := make(FileErrors, 0)
+	for idx, in_file := range input_files {
+		ctx, err := readContextFile(in_file)
+		if err == nil {
+			texts[in_file.Name], err = extractContextText(ctx, page_selection)
+		}
+		if err != nil {
+			file_errors = append(file_errors, &FileError{Idx: idx, Name: in_file.Name, Err: err})
+		}
+	}
+	if len(file_errors) > 0 {
+		return texts, file_errors
+	}
+	return texts, nil
+}
+
+func selectedPageNumbers(ctx *pdfcpu.Context, page_selection []string) ([]int, error) {
+	// The page numbers page_selection picks out of ctx in order, nil page_selection is every page
+	err := ctx.EnsurePageCount()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := api.PagesForPageSelection(ctx.PageCount, page_selection, true)
+	if err != nil {
+		return nil, err
+	}
+	page_numbers := make([]int, 0, len(selected))
+	for page_nr, ok := range selected {
+		if ok && page_nr >= 1 && page_nr <= ctx.PageCount {
+			page_numbers = append(page_numbers, page_nr)
+		}
+	}
+	sort.Ints(page_numbers)
+	return page_numbers, nil
+}
+
+func extractContextText(ctx *pdfcpu.Context, page_selection []string) ([]string, error) {
+	page_numbers, err := selectedPageNumbers(ctx, page_selection)
+	if err != nil {
+		return nil, err
+	}
+
+	page_texts := make([]string, len(page_numbers))
+	for i, page_nr := range page_numbers {
+		page_dict, _, inherited, err := ctx.PageDict(page_nr, false)
+		if err != nil {
+			return nil, err
+		}
+		content, err := ctx.PageContent(page_dict)
+		if err != nil {
+			// No content stream at all, nothing to read
+			continue
+		}
+		resources, err := ctx.DereferenceDict(page_dict["Resources"])
+		if err != nil || resources == nil {
+			resources = inherited.Resources
+		}
+		var text textWriter
+		if err = extractStreamText(ctx, content, resources, &text, 0); err != nil {
+			return nil, fmt.Errorf("page %d: %v", page_nr, err)
+		}
+		page_texts[i] = text.String()
+	}
+	return page_texts, nil
+}
+
+// textWriter puts together the text of a page, a line at a time
+type textWriter struct {
+	lines []string
+	line  strings.Builder
+}
+
+func (w *textWriter) write(s string) {
+	w.line.WriteString(s)
+}
+
+func (w *textWriter) newLine() {
+	// Lines of only spaces are positioning, not text
+	if line := strings.TrimRight(w.line.String(), " "); line != "" {
+		w.lines = append(w.lines, line)
+	}
+	w.line.Reset()
+}
+
+func (w *textWriter) String() string {
+	w.newLine()
+	return strings.Join(w.lines, "\n")
+}
+
+func extractStreamText(ctx *pdfcpu.Context, content []byte, resources pdfcpu.Dict, text *textWriter, depth int) error {
+	/*
+		Walks the operators of a content stream keeping the operands on a stack,
+		form XObjects (Do) are walked with their own resources.
+	*/
+	if depth > 8 {
+		return nil
+	}
+	fonts := make(map[string]*textFont)
+	var font *textFont
+	var last_y float64
+	lexer := contentLexer{data: content}
+	operands := make([]interface{}, 0)
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		op, is_op := token.(contentOp)
+		if !is_op {
+			operands = append(operands, token)
+			continue
+		}
+		switch op {
+		case "BT":
+			last_y = 0
+		case "ET":
+			text.newLine()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(contentName); ok {
+					font = resourceFont(ctx, resources, string(name), fonts)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+					text.newLine()
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[len(operands)-1].(float64); ok && y != last_y {
+					text.newLine()
+					last_y = y
+				}
+			}
+		case "T*":
+			text.newLine()
+		case "Tj":
+			if len(operands) >= 1 {
+				text.write(font.decode(operands[len(operands)-1]))
+			}
+		case "'", "\"":
+			text.newLine()
+			if len(operands) >= 1 {
+				text.write(font.decode(operands[len(operands)-1]))
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				parts, _ := operands[len(operands)-1].([]interface{})
+				for _, part := range parts {
+					if kerning, ok := part.(float64); ok {
+						// A big enough negative kerning is how a lot of producers write a space
+						if kerning < -200 {
+							text.write(" ")
+						}
+						continue
+					}
+					text.write(font.decode(part))
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 {
+				if name, ok := operands[len(operands)-1].(contentName); ok {
+					if err := extractXObjectText(ctx, resources, string(name), text, depth); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return lexer.err
+}
+
+func extractXObjectText(ctx *pdfcpu.Context, resources pdfcpu.Dict, name string, text *textWriter, depth int) error {
+	// Only a form XObject too deeply nested to read is an error, the others just have no text
+	xobjects, err := ctx.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return nil
+	}
+	sd, _, err := ctx.DereferenceStreamDict(xobjects[name])
+	if err != nil || sd == nil {
+		return nil
+	}
+	if subtype := sd.Dict.NameEntry("Subtype"); subtype == nil || *subtype != "Form" {
+		// Images have no text, which is what makes scanned pages come back empty
+		return nil
+	}
+	err = sd.Decode()
+	if err != nil {
+		return nil
+	}
+	form_resources, err := ctx.DereferenceDict(sd.Dict["Resources"])
+	if err != nil || form_resources == nil {
+		form_resources = resources
+	}
+	return extractStreamText(ctx, sd.Content, form_resources, text, depth+1)
+}
+
+// textFont turns the bytes of a shown string into text
+type textFont struct {
+	// Code lengths from the CMap codespace, shortest first
+	code_lengths []int
+	to_unicode   map[string]string
+	// Type0 fonts without a ToUnicode CMap use codes we can't map to text
+	unmapped bool
+}
+
+func resourceFont(ctx *pdfcpu.Context, resources pdfcpu.Dict, name string, fonts map[string]*textFont) *textFont {
+	if font, found := fonts[name]; found {
+		return font
+	}
+	font := &textFont{code_lengths: []int{1}}
+	fonts[name] = font
+
+	font_dicts, err := ctx.DereferenceDict(resources["Font"])
+	if err != nil || font_dicts == nil {
+		return font
+	}
+	font_dict, err := ctx.DereferenceDict(font_dicts[name])
+	if err != nil || font_dict == nil {
+		return font
+	}
+	if subtype := font_dict.NameEntry("Subtype"); subtype != nil && *subtype == "Type0" {
+		font.code_lengths = []int{2}
+		font.unmapped = true
+	}
+	sd, _, err := ctx.DereferenceStreamDict(font_dict["ToUnicode"])
+	if err != nil || sd == nil || sd.Decode() != nil {
+		return font
+	}
+	code_lengths, to_unicode, err := parseToUnicode(sd.Content)
+	if err == nil && len(to_unicode) > 0 {
+		font.to_unicode = to_unicode
+		font.unmapped = false
+		if len(code_lengths) > 0 {
+			font.code_lengths = code_lengths
+		}
+	}
+	return font
+}
+
+func (font *textFont) decode(operand interface{}) string {
+	s, ok := operand.(contentString)
+	if !ok {
+		return ""
+	}
+	if font == nil {
+		return latin1(s)
+	}
+	if font.unmapped {
+		return ""
+	}
+	if font.to_unicode == nil {
+		return latin1(s)
+	}
+	var text strings.Builder
+	for pos := 0; pos < len(s); {
+		matched := false
+		for _, n := range font.code_lengths {
+			if pos+n > len(s) {
+				break
+			}
+			if unicode, found := font.to_unicode[string(s[pos:pos+n])]; found {
+				text.WriteString(unicode)
+				pos += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			// Unmapped code, skip it
+			pos += font.code_lengths[0]
+		}
+	}
+	return text.String()
+}
+
+func latin1(s contentString) string {
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func parseToUnicode(cmap []byte) ([]int, map[string]string, error) {
+	/*
+		Only the parts of a ToUnicode CMap that map codes to text are read:
+			begincodespacerange <00> <FF> endcodespacerange
+			beginbfchar <01> <0041> endbfchar
+			beginbfrange <02> <05> <0042> endbfrange, or <02> <05> [<0042> <0043> ...]
+	*/
+	length_set := make(map[int]bool)
+	to_unicode := make(map[string]string)
+	lexer := contentLexer{data: cmap}
+	operands := make([]interface{}, 0)
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		op, is_op := token.(contentOp)
+		if !is_op {
+			operands = append(operands, token)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(contentString); ok && len(lo) > 0 {
+					length_set[len(lo)] = true
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok_src := operands[i].(contentString)
+				dst, ok_dst := operands[i+1].(contentString)
+				if ok_src && ok_dst {
+					to_unicode[string(src)] = utf16Text(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok_lo := operands[i].(contentString)
+				hi, ok_hi := operands[i+1].(contentString)
+				if !ok_lo || !ok_hi || len(lo) != len(hi) || len(lo) == 0 {
+					continue
+				}
+				start, end := codeNumber(lo), codeNumber(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case contentString:
+					for code := start; code <= end; code++ {
+						to_unicode[codeBytes(code, len(lo))] = utf16Text(incrementLast(dst, code-start))
+					}
+				case []interface{}:
+					for j, elem := range dst {
+						if dst_string, ok := elem.(contentString); ok && start+j <= end {
+							to_unicode[codeBytes(start+j, len(lo))] = utf16Text(dst_string)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	code_lengths := make([]int, 0, len(length_set))
+	for n := range length_set {
+		code_lengths = append(code_lengths, n)
+	}
+	sort.Ints(code_lengths)
+	return code_lengths, to_unicode, lexer.err
+}
+
+func codeNumber(code contentString) int {
+	n := 0
+	for _, b := range code {
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+func codeBytes(n, length int) string {
+	code := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		code[i] = byte(n)
+		n >>= 8
+	}
+	return string(code)
+}
+
+func incrementLast(dst contentString, by int) contentString {
+	// bfrange destinations count up from the first one, carrying into the last two bytes
+	out := append(contentString(nil), dst...)
+	if len(out) >= 2 {
+		n := int(out[len(out)-2])<<8 | int(out[len(out)-1])
+		n += by
+		out[len(out)-2], out[len(out)-1] = byte(n>>8), byte(n)
+	} else if len(out) == 1 {
+		out[0] += byte(by)
+	}
+	return out
+}
+
+func utf16Text(dst contentString) string {
+	units := make([]uint16, 0, len(dst)/2)
+	for i := 0; i+1 < len(dst); i += 2 {
+		units = append(units, uint16(dst[i])<<8|uint16(dst[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// Tokens handed out by contentLexer, numbers are float64 and arrays []interface{}
+type contentString []byte
+type contentName string
+type contentOp string
+
+// contentLexer splits a content stream (or a CMap, same syntax) into tokens
+type contentLexer struct {
+	data []byte
+	pos  int
+	// Why next stopped before the end of data, nil when it got there
+	err error
+}
+
+// The deepest arrays and dicts contentLexer and fdfParser read, nothing real comes close
+const max_content_nesting = 32
+
+var errContentNesting = fmt.Errorf("arrays or dicts nested more than %d deep", max_content_nesting)
+
+func (l *contentLexer) next() (interface{}, bool) {
+	/*
+		One loop for everything, with the arrays being read on a stack: a stream is
+		untrusted and a few KB of Flate can inflate to megabytes of "[" or of stray
+		delimiters, recursing on those would run the stack out and take down the
+		process. Arrays nested deeper than max_content_nesting stop the lexer with
+		errContentNesting in err. An array the data ends in comes back as far as it goes.
+	*/
+	arrays := make([][]interface{}, 0)
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			if len(arrays) == 0 {
+				return nil, false
+			}
+			for i := len(arrays) - 1; i > 0; i-- {
+				arrays[i-1] = append(arrays[i-1], arrays[i])
+			}
+			return arrays[0], true
+		}
+		var token interface{}
+		c := l.data[l.pos]
+		switch {
+		case c == '(':
+			token = l.literalString()
+		case c == '<' && l.peek(1) == '<':
+			l.pos += 2
+			if !l.skipDict() {
+				return nil, false
+			}
+			continue
+		case c == '<':
+			token = l.hexString()
+		case c == '[':
+			if len(arrays) >= max_content_nesting {
+				l.err = errContentNesting
+				l.pos = len(l.data)
+				return nil, false
+			}
+			l.pos++
+			arrays = append(arrays, make([]interface{}, 0))
+			continue
+		case c == ']':
+			l.pos++
+			if len(arrays) == 0 {
+				// Stray, like the other delimiters below
+				continue
+			}
+			token = arrays[len(arrays)-1]
+			arrays = arrays[:len(arrays)-1]
+		case c == '>' || c == ')' || c == '{' || c == '}':
+			l.pos++
+			continue
+		case c == '/':
+			l.pos++
+			token = contentName(l.word())
+		default:
+			word := l.word()
+			if word == "" {
+				l.pos++
+				continue
+			}
+			if n, err := strconv.ParseFloat(word, 64); err == nil {
+				token = n
+			} else {
+				if word == "ID" {
+					l.skipInlineImage()
+				}
+				token = contentOp(word)
+			}
+		}
+		if len(arrays) == 0 {
+			return token, true
+		}
+		arrays[len(arrays)-1] = append(arrays[len(arrays)-1], token)
+	}
+}
+
+func (l *contentLexer) peek(offset int) byte {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *contentLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *contentLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *contentLexer) literalString() contentString {
+	// Balanced parentheses don't need escaping, so keep track of the nesting
+	l.pos++
+	s := make(contentString, 0)
+	nesting := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			nesting--
+			if nesting == 0 {
+				return s
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return s
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r':
+				if l.peek(0) == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					s = append(s, byte(n))
+				} else {
+					s = append(s, e)
+				}
+			}
+			continue
+		}
+		s = append(s, c)
+	}
+	return s
+}
+
+func (l *contentLexer) hexString() contentString {
+	l.pos++
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		end = len(l.data) - l.pos
+	}
+	digits := make([]byte, 0, end)
+	for _, c := range l.data[l.pos : l.pos+end] {
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	l.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make(contentString, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			break
+		}
+		s = append(s, byte(n))
+	}
+	return s
+}
+
+func (l *contentLexer) skipDict() bool {
+	// Dicts in content streams are only marked content properties and inline image params,
+	// false with err set when they're nested deeper than max_content_nesting
+	nesting := 1
+	for l.pos < len(l.data) && nesting > 0 {
+		switch {
+		case l.data[l.pos] == '(':
+			l.literalString()
+			continue
+		case l.data[l.pos] == '<' && l.peek(1) == '<':
+			nesting++
+			if nesting > max_content_nesting {
+				l.err = errContentNesting
+				l.pos = len(l.data)
+				return false
+			}
+			l.pos++
+		case l.data[l.pos] == '>' && l.peek(1) == '>':
+			nesting--
+			l.pos++
+		}
+		l.pos++
+	}
+	return true
+}
+
+func (l *contentLexer) skipInlineImage() {
+	// The image data after ID is binary, it runs until an EI surrounded by white space
+	l.pos++
+	for l.pos+2 <= len(l.data) {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' && isSpace(l.data[l.pos-1]) && (l.pos+2 == len(l.data) || isSpace(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
@@ -0,0 +1,93 @@
+package gopdf
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func lexAll(data []byte) ([]interface{}, error) {
+	lexer := contentLexer{data: data}
+	tokens := make([]interface{}, 0)
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			return tokens, lexer.err
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestContentLexerTokens(t *testing.T) {
+	tokens, err := lexAll([]byte("BT /F1 12 Tf [(a) -250 [(b)] (c)] TJ <<inline>> ) } (d) Tj ET"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		contentOp("BT"), contentName("F1"), 12.0, contentOp("Tf"),
+		[]interface{}{contentString("a"), -250.0, []interface{}{contentString("b")}, contentString("c")}, contentOp("TJ"),
+		contentString("d"), contentOp("Tj"), contentOp("ET"),
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got %#v, want %#v", tokens, want)
+	}
+}
+
+func TestContentLexerUnterminatedArray(t *testing.T) {
+	tokens, err := lexAll([]byte("[1 [2 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{[]interface{}{1.0, []interface{}{2.0, 3.0}}}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got %#v, want %#v", tokens, want)
+	}
+}
+
+func TestContentLexerStrayDelimiters(t *testing.T) {
+	// Megabytes of delimiters it skips used to be one recursive call each, enough to overflow the stack
+	for _, delimiter := range []string{")", "]", ">", "{", "}"} {
+		tokens, err := lexAll(bytes.Repeat([]byte(delimiter), 8<<20))
+		if err != nil || len(tokens) != 0 {
+			t.Errorf("%q: got %d tokens and %v, want none", delimiter, len(tokens), err)
+		}
+	}
+	tokens, err := lexAll(bytes.Repeat([]byte("<<>>"), 2<<20))
+	if err != nil || len(tokens) != 0 {
+		t.Errorf("dicts: got %d tokens and %v, want none", len(tokens), err)
+	}
+}
+
+func TestContentLexerDeepNesting(t *testing.T) {
+	for _, data := range [][]byte{bytes.Repeat([]byte("["), 8<<20), bytes.Repeat([]byte("<<"), 8<<20)} {
+		_, err := lexAll(data)
+		if !errors.Is(err, errContentNesting) {
+			t.Errorf("%q...: got %v, want errContentNesting", data[:4], err)
+		}
+	}
+	nested := append(bytes.Repeat([]byte("["), max_content_nesting), bytes.Repeat([]byte("]"), max_content_nesting)...)
+	if _, err := lexAll(nested); err != nil {
+		t.Errorf("%d nested arrays: %v", max_content_nesting, err)
+	}
+}
+
+func TestExtractStreamTextDeepNesting(t *testing.T) {
+	var text textWriter
+	err := extractStreamText(nil, append([]byte("BT (before) Tj ET "), bytes.Repeat([]byte("["), 1<<20)...), nil, &text, 0)
+	if !errors.Is(err, errContentNesting) {
+		t.Errorf("got %v, want errContentNesting", err)
+	}
+	if _, _, err := parseToUnicode(bytes.Repeat([]byte("["), 1<<20)); !errors.Is(err, errContentNesting) {
+		t.Errorf("ToUnicode: got %v, want errContentNesting", err)
+	}
+}
+
+func TestParseFDFDeepNesting(t *testing.T) {
+	for _, open := range []string{"[", "<<"} {
+		data := append([]byte("1 0 obj\n"), bytes.Repeat([]byte(open), 8<<20)...)
+		if _, _, err := parseFDF(data); !errors.Is(err, errContentNesting) {
+			t.Errorf("%q: got %v, want errContentNesting", open, err)
+		}
+	}
+}
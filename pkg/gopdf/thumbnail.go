@@ -0,0 +1,90 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+)
+
+// ErrNoThumbnail is what Thumbnail returns for a page without an embedded thumbnail,
+// pdfcpu doesn't render pages so we have nothing to draw one with
+var ErrNoThumbnail = errors.New("no PDF renderer is available and the page has no embedded thumbnail")
+
+// MaxThumbnailWidth is the widest Thumbnail scales to, in pixels
+const MaxThumbnailWidth = 2000
+
+// Thumbnail writes page of in_file to w as a PNG width pixels wide, 0 keeps the
+// thumbnail's own width. That's the thumbnail embedded in the page (its Thumb) scaled,
+// a page without one is ErrNoThumbnail. The page's MediaBox (llx, lly, urx, ury in points)
+// comes back either way. A file that can't be read, or doesn't have page, is a FileError.
+func Thumbnail(in_file InputFile, page int, width int, w io.Writer) ([]float64, error) {
+	if width < 0 || width > MaxThumbnailWidth {
+		return nil, fmt.Errorf("width must be between 1 and %d pixels, got %d", MaxThumbnailWidth, width)
+	}
+	file_error := func(err error) *FileError {
+		return &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return nil, file_error(err)
+	}
+	if err = ctx.EnsurePageCount(); err != nil {
+		return nil, file_error(err)
+	}
+	if page < 1 || page > ctx.PageCount {
+		return nil, file_error(fmt.Errorf("page %d is out of range, the file has %d pages", page, ctx.PageCount))
+	}
+	page_dict, _, inherited, err := ctx.PageDict(page, false)
+	if err != nil {
+		return nil, file_error(err)
+	}
+	var media_box []float64
+	if inherited != nil && inherited.MediaBox != nil {
+		box := inherited.MediaBox
+		media_box = []float64{box.LL.X, box.LL.Y, box.UR.X, box.UR.Y}
+	}
+
+	thumb_ref, ok := page_dict["Thumb"].(pdfcpu.IndirectRef)
+	if !ok {
+		return media_box, ErrNoThumbnail
+	}
+	sd, _, err := ctx.DereferenceStreamDict(thumb_ref)
+	if err != nil {
+		return media_box, file_error(fmt.Errorf("thumbnail of page %d: %v", page, err))
+	}
+	if sd == nil {
+		return media_box, ErrNoThumbnail
+	}
+	// pdfcpu turns image streams into PNG, JPEG or TIFF (CMYK) files
+	extracted, err := ctx.ExtractImage(sd, true, "Thumb", thumb_ref.ObjectNumber.Value(), false)
+	if err != nil {
+		return media_box, file_error(fmt.Errorf("thumbnail of page %d: %v", page, err))
+	}
+	if extracted == nil {
+		return media_box, file_error(fmt.Errorf("thumbnail of page %d is in a format we can't read", page))
+	}
+	thumb, _, err := image.Decode(extracted)
+	if err != nil {
+		return media_box, file_error(fmt.Errorf("thumbnail of page %d: %v", page, err))
+	}
+
+	bounds := thumb.Bounds()
+	if width > 0 && width != bounds.Dx() {
+		height := (bounds.Dy()*width + bounds.Dx()/2) / bounds.Dx()
+		if height < 1 {
+			height = 1
+		}
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), thumb, bounds, draw.Src, nil)
+		thumb = scaled
+	}
+	return media_box, png.Encode(w, thumb)
+}
@@ -0,0 +1,55 @@
+package gopdf
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FileTimeout is how long reading a single file may take before it's given up on, 0 means no limit
+var FileTimeout = 60 * time.Second
+
+// ErrTimeout is the error of a file that took longer than FileTimeout
+var ErrTimeout = errors.New("timed out")
+
+// callDone tells if err is the context of the whole call being done, rather than one file failing
+func callDone(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func runFile[T any](ctx context.Context, work func() (T, error)) (T, error) {
+	/*
+		Runs work, the pdfcpu side of one file, until it's done, FileTimeout passes (ErrTimeout)
+		or ctx is done (ctx.Err()). pdfcpu doesn't take a context so work can't be stopped,
+		it's left to finish in the background and whatever it returns is thrown away.
+		That makes it important work doesn't write anything, only reads.
+	*/
+	file_ctx := ctx
+	if FileTimeout > 0 {
+		var cancel context.CancelFunc
+		file_ctx, cancel = context.WithTimeout(ctx, FileTimeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		value T
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		value, err := work()
+		done <- outcome{value, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.value, o.err
+	case <-file_ctx.Done():
+		var zero T
+		if ctx.Err() != nil {
+			// The whole call is over, not just this file
+			return zero, ctx.Err()
+		}
+		return zero, ErrTimeout
+	}
+}
@@ -0,0 +1,202 @@
+package gopdf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransformNames are the transforms Generate can apply to the values of the context before
+// filling them in. date:<layout> takes an ISO date (2006-01-02, optionally with a time
+// like RFC 3339) and writes it in layout, an AFDate format like "mm/dd/yyyy" or "d mmm yyyy".
+var TransformNames = []string{"trim", "upper", "lower", "date:<layout>"}
+
+// ErrTransform is what Generate returns, wrapped with the key and what's wrong, for a value
+// a transform can't be applied to
+var ErrTransform = errors.New("transform failed")
+
+// TransformedValue is a value of the context that its transforms changed
+type TransformedValue struct {
+	Original interface{} `json:"original"`
+	Value    interface{} `json:"value"`
+}
+
+// The layouts date:<layout> reads its values in
+var transform_date_layouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// CheckTransforms returns an error for a transform in transforms that isn't one of TransformNames
+func CheckTransforms(transforms map[string][]string) error {
+	for key, names := range transforms {
+		for _, name := range names {
+			switch {
+			case name == "trim" || name == "upper" || name == "lower":
+			case strings.HasPrefix(name, "date:") && strings.TrimSpace(name[len("date:"):]) != "":
+			default:
+				return fmt.Errorf("transform %q of %q must be one of %v", name, key, TransformNames)
+			}
+		}
+	}
+	return nil
+}
+
+func transformContext(context map[string]interface{}, transforms map[string][]string) (map[string]interface{}, map[string]TransformedValue, error) {
+	/*
+		A copy of context with the transforms applied: the ones under "*" to every key
+		first, then the ones under the key itself, in the order they're listed. Strings
+		and the strings of lists are transformed, other values are left as they are.
+		Keys of transforms that aren't in context are skipped. The values a transform
+		changed are returned with what they were.
+	*/
+	transformed := make(map[string]TransformedValue)
+	if len(transforms) == 0 {
+		return context, transformed, nil
+	}
+	if err := CheckTransforms(transforms); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrTransform, err)
+	}
+	out := make(map[string]interface{}, len(context))
+	for key, value := range context {
+		names := append(append([]string{}, transforms["*"]...), transforms[key]...)
+		if len(names) == 0 || key == "*" {
+			out[key] = value
+			continue
+		}
+		new_value, changed, err := transformValue(value, names)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %q: %v", ErrTransform, key, err)
+		}
+		out[key] = new_value
+		if changed {
+			transformed[key] = TransformedValue{Original: value, Value: new_value}
+		}
+	}
+	return out, transformed, nil
+}
+
+func transformValue(value interface{}, names []string) (interface{}, bool, error) {
+	// value with names applied and whether that changed it
+	switch v := value.(type) {
+	case string:
+		s, err := transformString(v, names)
+		return s, s != v, err
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		changed := false
+		for i, item := range v {
+			new_item, item_changed, err := transformValue(item, names)
+			if err != nil {
+				return nil, false, err
+			}
+			list[i] = new_item
+			changed = changed || item_changed
+		}
+		return list, changed, nil
+	case []string:
+		list := make([]string, len(v))
+		changed := false
+		for i, item := range v {
+			s, err := transformString(item, names)
+			if err != nil {
+				return nil, false, err
+			}
+			list[i] = s
+			changed = changed || s != item
+		}
+		return list, changed, nil
+	}
+	return value, false, nil
+}
+
+func transformString(s string, names []string) (string, error) {
+	for _, name := range names {
+		switch {
+		case name == "trim":
+			s = strings.TrimSpace(s)
+		case name == "upper":
+			s = strings.ToUpper(s)
+		case name == "lower":
+			s = strings.ToLower(s)
+		case strings.HasPrefix(name, "date:"):
+			// An empty value stays empty, it clears the field
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			t, err := parseISODate(strings.TrimSpace(s))
+			if err != nil {
+				return "", err
+			}
+			s = formatAFDate(t, strings.TrimSpace(name[len("date:"):]))
+		}
+	}
+	return s, nil
+}
+
+func parseISODate(s string) (time.Time, error) {
+	for _, layout := range transform_date_layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not an ISO date like 2006-01-02", s)
+}
+
+func formatAFDate(t time.Time, layout string) string {
+	/*
+		t written in an AFDate format, with the tokens fieldFormat reads dates in:
+		yyyy/yy, mmmm/mmm (names), mm/m, dddd/ddd (weekdays), dd/d, HH/H, hh/h,
+		MM/M (minutes), ss/s and tt/t (AM/PM), anything else as it is
+	*/
+	var out strings.Builder
+	for _, token := range af_date_tokens.FindAllString(layout, -1) {
+		hour12 := t.Hour() % 12
+		if hour12 == 0 {
+			hour12 = 12
+		}
+		switch token {
+		case "yyyy":
+			fmt.Fprintf(&out, "%04d", t.Year())
+		case "yy":
+			fmt.Fprintf(&out, "%02d", t.Year()%100)
+		case "mmmm":
+			out.WriteString(t.Month().String())
+		case "mmm":
+			out.WriteString(t.Month().String()[:3])
+		case "mm":
+			fmt.Fprintf(&out, "%02d", int(t.Month()))
+		case "m":
+			fmt.Fprintf(&out, "%d", int(t.Month()))
+		case "dddd":
+			out.WriteString(t.Weekday().String())
+		case "ddd":
+			out.WriteString(t.Weekday().String()[:3])
+		case "dd":
+			fmt.Fprintf(&out, "%02d", t.Day())
+		case "d":
+			fmt.Fprintf(&out, "%d", t.Day())
+		case "HH":
+			fmt.Fprintf(&out, "%02d", t.Hour())
+		case "H":
+			fmt.Fprintf(&out, "%d", t.Hour())
+		case "hh":
+			fmt.Fprintf(&out, "%02d", hour12)
+		case "h":
+			fmt.Fprintf(&out, "%d", hour12)
+		case "MM":
+			fmt.Fprintf(&out, "%02d", t.Minute())
+		case "M":
+			fmt.Fprintf(&out, "%d", t.Minute())
+		case "ss":
+			fmt.Fprintf(&out, "%02d", t.Second())
+		case "s":
+			fmt.Fprintf(&out, "%d", t.Second())
+		case "tt":
+			out.WriteString(t.Format("PM"))
+		case "t":
+			out.WriteString(t.Format("PM")[:1])
+		default:
+			out.WriteString(token)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,229 @@
+package gopdf
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// UnstampResult is how many stamps Unstamp took off, in total and per page
+type UnstampResult struct {
+	Removed int `json:"removed"`
+	// Only the pages that had stamps taken off, in page order
+	Pages []PageStamps `json:"pages"`
+}
+
+// PageStamps is how many stamps were taken off page Page
+type PageStamps struct {
+	Page    int `json:"page"`
+	Removed int `json:"removed"`
+}
+
+var (
+	// How pdfcpu marks the content it adds for a stamp or watermark, up to the next EMC
+	stamp_artifact = []byte("/Artifact <</Subtype /Watermark /Type /Pagination >>BDC")
+	stamp_form     = regexp.MustCompile(`/(\w+)\s+Do\b`)
+	stamp_gs       = regexp.MustCompile(`/(\w+)\s+gs\b`)
+)
+
+// Unstamp takes the stamps and watermarks pdfcpu put on the pages selected by pages
+// (pdfcpu's page selection syntax, empty means every page) of in_file off again and writes
+// the result to out_path. With an id only stamps Stamp was given that ID are taken off.
+// pdfcpu puts stamps in the page content, not in annotations, so annotations are left as
+// they are and so is everything else drawn on the pages.
+func Unstamp(in_file InputFile, out_path string, id string, pages string) (UnstampResult, error) {
+	result := UnstampResult{Pages: make([]PageStamps, 0)}
+	var page_selection []string
+	if pages != "" {
+		var err error
+		page_selection, err = api.ParsePageSelection(pages)
+		if err != nil {
+			return result, err
+		}
+	}
+	ctx, err := readContextFile(in_file)
+	if err != nil {
+		return result, err
+	}
+	if err = ctx.EnsurePageCount(); err != nil {
+		return result, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+	}
+	selected, err := api.PagesForPageSelection(ctx.PageCount, page_selection, true)
+	if err != nil {
+		return result, err
+	}
+	for page_nr := 1; page_nr <= ctx.PageCount; page_nr++ {
+		if !selected[page_nr] {
+			continue
+		}
+		removed, err := unstampPage(ctx, page_nr, id)
+		if err != nil {
+			return result, &FileError{Idx: 0, Name: in_file.Name, Err: err}
+		}
+		if removed > 0 {
+			result.Pages = append(result.Pages, PageStamps{Page: page_nr, Removed: removed})
+			result.Removed += removed
+		}
+	}
+	return result, writeContextFile(ctx, out_path)
+}
+
+func unstampPage(ctx *pdfcpu.Context, page_nr int, id string) (int, error) {
+	/*
+		Cuts the stamp artifacts out of the content streams of page page_nr and drops the
+		form XObjects and ExtGStates only they used from the page resources.
+		Returns how many were cut.
+	*/
+	d, _, _, err := ctx.PageDict(page_nr, false)
+	if err != nil || d == nil {
+		return 0, err
+	}
+	xobjects, err := pageXObjects(ctx, page_nr)
+	if err != nil {
+		return 0, err
+	}
+	refs, err := contentStreamRefs(ctx, d["Contents"])
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	unused := make(map[string]bool)
+	contents := make([][]byte, 0, len(refs))
+	for _, ref := range refs {
+		entry, found := ctx.FindTableEntryForIndRef(&ref)
+		if !found {
+			continue
+		}
+		sd, ok := entry.Object.(pdfcpu.StreamDict)
+		if !ok {
+			continue
+		}
+		err = sd.Decode()
+		if errors.Is(err, filter.ErrUnsupportedFilter) {
+			// Can't be looked into, so there's nothing pdfcpu could have added either
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		content, n, names := cutStamps(ctx, sd.Content, xobjects, id)
+		contents = append(contents, content)
+		if n == 0 {
+			continue
+		}
+		removed += n
+		for _, name := range names {
+			unused[name] = true
+		}
+		sd.Content = content
+		if err = sd.Encode(); err != nil {
+			return 0, err
+		}
+		entry.Object = sd
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// Resources another part of the page still draws with stay
+	remaining := bytes.Join(contents, []byte(" "))
+	for name := range unused {
+		if regexp.MustCompile(`/` + regexp.QuoteMeta(name) + `\b`).Match(remaining) {
+			delete(unused, name)
+		}
+	}
+	resources, err := ctx.DereferenceDict(d["Resources"])
+	if err != nil || resources == nil {
+		return removed, err
+	}
+	for _, key := range []string{"XObject", "ExtGState"} {
+		entries, err := ctx.DereferenceDict(resources[key])
+		if err != nil || entries == nil {
+			continue
+		}
+		for name := range unused {
+			entries.Delete(name)
+		}
+		if len(entries) == 0 {
+			resources.Delete(key)
+		}
+	}
+	return removed, nil
+}
+
+func cutStamps(ctx *pdfcpu.Context, content []byte, xobjects pdfcpu.Dict, id string) ([]byte, int, []string) {
+	/*
+		content without its stamp artifacts (only the ones whose form XObject is tagged
+		with id when there is an id), how many were cut and the resource names they used
+	*/
+	out := make([]byte, 0, len(content))
+	removed := 0
+	names := make([]string, 0)
+	for {
+		begin := bytes.Index(content, stamp_artifact)
+		if begin < 0 {
+			break
+		}
+		length := bytes.Index(content[begin:], []byte("EMC"))
+		if length < 0 {
+			break
+		}
+		end := begin + length + len("EMC")
+		artifact := content[begin:end]
+		if id != "" && stampID(ctx, xobjects, artifact) != id {
+			out = append(out, content[:end]...)
+			content = content[end:]
+			continue
+		}
+		out = append(out, content[:begin]...)
+		content = content[end:]
+		removed++
+		for _, re := range []*regexp.Regexp{stamp_form, stamp_gs} {
+			for _, match := range re.FindAllSubmatch(artifact, -1) {
+				names = append(names, string(match[1]))
+			}
+		}
+	}
+	return append(out, content...), removed, names
+}
+
+func stampID(ctx *pdfcpu.Context, xobjects pdfcpu.Dict, artifact []byte) string {
+	// The ID Stamp tagged the form XObject artifact draws with, "" for untagged stamps
+	for _, match := range stamp_form.FindAllSubmatch(artifact, -1) {
+		sd, _, err := ctx.DereferenceStreamDict(xobjects[string(match[1])])
+		if err != nil || sd == nil {
+			continue
+		}
+		if id, err := ctx.DereferenceText(sd.Dict[stamp_id_key]); err == nil {
+			return id
+		}
+	}
+	return ""
+}
+
+func contentStreamRefs(ctx *pdfcpu.Context, contents pdfcpu.Object) ([]pdfcpu.IndirectRef, error) {
+	// The content streams of a page: Contents is a stream or an array of them
+	if ref, ok := contents.(pdfcpu.IndirectRef); ok {
+		o, err := ctx.Dereference(ref)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := o.(pdfcpu.Array); !ok {
+			return []pdfcpu.IndirectRef{ref}, nil
+		}
+		contents = o
+	}
+	a, _ := contents.(pdfcpu.Array)
+	refs := make([]pdfcpu.IndirectRef, 0, len(a))
+	for _, o := range a {
+		if ref, ok := o.(pdfcpu.IndirectRef); ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
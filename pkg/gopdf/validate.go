@@ -0,0 +1,35 @@
+package gopdf
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ValidationResult is what Validate reports for every input file
+type ValidationResult struct {
+	File  string `json:"file"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// Validate runs pdfcpu's validation with conf on every input file.
+// A file failing validation is a result, not an error.
+func Validate(input_files []InputFile, conf *pdfcpu.Configuration) []ValidationResult {
+	/*
+		This command checks inFile for compliance with the specification PDF 32000-1:2008 (PDF 1.7).
+	*/
+	results := make([]ValidationResult, len(input_files))
+	for idx, in_file := range input_files {
+		results[idx] = ValidationResult{File: in_file.Name, Valid: true}
+		f, err := in_file.Open()
+		if err == nil {
+			err = api.Validate(f, conf)
+			f.Close()
+		}
+		if err != nil {
+			results[idx].Valid = false
+			results[idx].Error = err.Error()
+		}
+	}
+	return results
+}
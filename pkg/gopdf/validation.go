@@ -0,0 +1,90 @@
+package gopdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// The validation modes of ValidationOptions
+const (
+	// pdfcpu's default, lets through what real world files commonly get wrong
+	ValidationRelaxed = "relaxed"
+	ValidationStrict  = "strict"
+	// The file is only read, so files missing entries the spec requires can still be used
+	// as far as pdfcpu gets with them
+	ValidationNone = "none"
+)
+
+// ValidationOptions is how strict the checks of an input file are before it's worked on,
+// the zero value is what every file got before there were options: relaxed validation
+type ValidationOptions struct {
+	// ValidationRelaxed (also ""), ValidationStrict or ValidationNone
+	Mode string
+	// RepairXRef rebuilds the cross-reference table of a file that can't be read with its
+	// own, from the objects in the file like Repair does, instead of failing it
+	RepairXRef bool
+}
+
+// CheckValidationMode is an error for a mode ValidationOptions doesn't have
+func CheckValidationMode(mode string) error {
+	switch mode {
+	case "", ValidationRelaxed, ValidationStrict, ValidationNone:
+		return nil
+	}
+	return fmt.Errorf("validation must be %q, %q or %q, got %q", ValidationRelaxed, ValidationStrict, ValidationNone, mode)
+}
+
+func validateFile(rs io.ReadSeeker, in_file InputFile) error {
+	// Validates rs as in_file's options say, with ValidationNone it only has to be readable
+	if in_file.Validation.Mode == ValidationNone {
+		_, err := api.ReadContext(rs, in_file.configuration())
+		return err
+	}
+	err := api.Validate(rs, in_file.configuration())
+	// pdfcpu says so by message only, reading the file failing isn't one
+	if err != nil && strings.HasPrefix(err.Error(), "validation error") {
+		return &ValidationError{Err: err}
+	}
+	return err
+}
+
+func validateOpened(in_file InputFile, f File) (File, error) {
+	/*
+		f, the opened in_file, validated and at the start again, or with RepairXRef
+		the file with its xref rebuilt when that's what makes it pass. f is closed
+		when something else is returned. A file that fails is a passwordError.
+	*/
+	err := validateFile(f, in_file)
+	if err == nil {
+		f.Seek(0, io.SeekStart)
+		return f, nil
+	}
+	defer f.Close()
+	if in_file.Validation.RepairXRef {
+		f.Seek(0, io.SeekStart)
+		if content, read_err := ioutil.ReadAll(f); read_err == nil {
+			if rebuilt, _, rebuild_err := rebuildXRef(content); rebuild_err == nil && validateFile(bytes.NewReader(rebuilt), in_file) == nil {
+				return bytesFile{bytes.NewReader(rebuilt)}, nil
+			}
+		}
+	}
+	return nil, passwordError(in_file, err)
+}
+
+// ValidationError is a file that didn't pass pdfcpu's validation, Err says why
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSendResponseStatus(t *testing.T) {
+	for _, test := range []struct {
+		response Response
+		status   int
+	}{
+		{Response{}, http.StatusOK},
+		{Response{Error: []string{"broken"}}, http.StatusInternalServerError},
+		{Response{Status: http.StatusCreated}, http.StatusCreated},
+		{Response{Status: http.StatusNotFound, Error: []string{"Not Found"}}, http.StatusNotFound},
+		{Response{Status: http.StatusBadRequest, Message: []string{"half done"}, Error: []string{"Bad Request"}}, http.StatusBadRequest},
+		{Response{Status: http.StatusAccepted, Body: gin.H{"results": []string{}}}, http.StatusAccepted},
+		{Response{Status: http.StatusBadGateway, Body: gin.H{"error": "upstream"}}, http.StatusBadGateway},
+	} {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+		sendResponse(c, test.response)
+		if recorder.Code != test.status {
+			t.Errorf("%+v: wrote %d, want %d", test.response, recorder.Code, test.status)
+		}
+	}
+}
+
+func TestSendResponseMessageAndError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/generate", nil)
+	sendResponse(c, Response{Status: http.StatusInternalServerError, Message: []string{"Filled 2 fields"}, Error: []string{"disk full"}})
+	body := responseBody(t, recorder)
+	if body["message"] != "Filled 2 fields" || body["error"] != "disk full" || body["code"] != code_internal {
+		t.Errorf("got %v, want the message, the error and its code", body)
+	}
+}
+
+func TestSendResponseData(t *testing.T) {
+	// Data goes in as it is, zeros and empty lists included, and can't hide the message or error
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/pages", nil)
+	sendResponse(c, Response{Status: http.StatusBadRequest, Message: []string{"half done"}, Error: []string{"Bad Request. selector 0: no such page"}, Data: gin.H{
+		"selector": 0, "page_count": 0, "blank_pages": 0, "original_size": 0, "files": []string{}, "message": "hidden", "code": "HIDDEN",
+	}})
+	body := responseBody(t, recorder)
+	for _, key := range []string{"selector", "page_count", "blank_pages", "original_size"} {
+		if body[key] != 0.0 {
+			t.Errorf("%s: got %v, want 0", key, body[key])
+		}
+	}
+	if files, ok := body["files"].([]interface{}); !ok || len(files) != 0 {
+		t.Errorf("files: got %v, want an empty list", body["files"])
+	}
+	if body["message"] != "half done" || body["code"] != code_invalid_request {
+		t.Errorf("Data replaced the message or code: %v", body)
+	}
+}
+
+func TestEndpointZeroValues(t *testing.T) {
+	r := testRouter()
+	in_path := writeTestPDF(t, test_form)
+	out_path := filepath.Join(t.TempDir(), "out.pdf")
+	// The first selector is the broken one, its index 0 is still reported
+	body := responseBody(t, serveJSON(t, r, "/merge", map[string]interface{}{"input_files": []string{in_path}, "output_file": out_path, "selectors": []map[string]interface{}{{"file": 3}}}))
+	if selector, found := body["selector"]; !found || selector != 0.0 {
+		t.Errorf("merge: got selector %v (found %v), want 0: %v", selector, found, body)
+	}
+	body = responseBody(t, serveJSON(t, r, "/optimize", map[string]interface{}{"input_file": in_path, "output_file": out_path}))
+	if body["original_size"] == nil || body["optimized_size"] == nil {
+		t.Errorf("optimize: got %v, want both sizes", body)
+	}
+}
+
+// The status every error code goes with when an endpoint answers with it
+var code_statuses = map[string]int{
+	code_invalid_json:      http.StatusBadRequest,
+	code_invalid_request:   http.StatusBadRequest,
+	code_invalid_pdf:       http.StatusBadRequest,
+	code_validation_failed: http.StatusBadRequest,
+	code_file_not_found:    http.StatusNotFound,
+	code_output_forbidden:  http.StatusForbidden,
+	code_unsupported_type:  http.StatusUnsupportedMediaType,
+	code_internal:          http.StatusInternalServerError,
+}
+
+func checkStatus(t *testing.T, what string, recorder *httptest.ResponseRecorder) map[string]interface{} {
+	/*
+		Fails t when the status recorder got doesn't go with its body: an error and a
+		code for 4xx and 5xx, with the status the code stands for, neither for the rest
+	*/
+	t.Helper()
+	body := responseBody(t, recorder)
+	code, _ := body["code"].(string)
+	_, has_error := body["error"]
+	if recorder.Code >= 400 {
+		if !has_error || code == "" {
+			t.Errorf("%s: %d without an error and a code: %v", what, recorder.Code, body)
+		}
+		if status, known := code_statuses[code]; known && status != recorder.Code {
+			t.Errorf("%s: %s came with %d, want %d: %v", what, code, recorder.Code, status, body)
+		}
+	} else if has_error || code != "" {
+		t.Errorf("%s: %d with an error: %v", what, recorder.Code, body)
+	}
+	return body
+}
+
+func TestEndpointStatus(t *testing.T) {
+	r := testRouter()
+	missing := filepath.Join(t.TempDir(), "missing.pdf")
+	out_dir := t.TempDir()
+	bad_requests := map[string]interface{}{
+		"broken JSON": "{",
+		"empty":       map[string]interface{}{},
+		"missing input": map[string]interface{}{
+			"input_file": missing, "input_files": []string{missing, missing}, "files": []string{missing},
+			"base_file": missing, "overlay_file": missing, "output_file": filepath.Join(out_dir, "out.pdf"), "output_dir": out_dir,
+			"context_json_file": map[string]interface{}{"name": "x"}, "pages": "1", "operation": "delete",
+		},
+	}
+	for _, route := range r.Routes() {
+		if route.Method != http.MethodPost {
+			continue
+		}
+		for what, body := range bad_requests {
+			recorder := serveJSON(t, r, route.Path, body)
+			checkStatus(t, route.Path+" "+what, recorder)
+			if what != "missing input" && recorder.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: got %d, want 400", route.Path, what, recorder.Code)
+			}
+		}
+	}
+
+	in_path := writeTestPDF(t, test_form)
+	for path, body := range map[string]map[string]interface{}{
+		"/scrape":    {"files": []string{in_path}},
+		"/generate":  {"input_files": []string{in_path}, "context_json_file": map[string]interface{}{"name": "Jane"}, "output_file": filepath.Join(out_dir, "filled.pdf")},
+		"/info":      {"input_files": []string{in_path}},
+		"/validate":  {"input_files": []string{in_path}},
+		"/optimize":  {"input_file": in_path, "output_file": filepath.Join(out_dir, "optimized.pdf")},
+		"/linearize": {"input_file": in_path, "output_file": filepath.Join(out_dir, "linearized.pdf")},
+		"/merge":     {"input_files": []string{in_path, in_path}, "output_file": filepath.Join(out_dir, "merged.pdf")},
+	} {
+		recorder := serveJSON(t, r, path, body)
+		checkStatus(t, path, recorder)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s: got %d, want 200: %s", path, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestMissingInputFileNotFound(t *testing.T) {
+	r := testRouter()
+	missing := filepath.Join(t.TempDir(), "missing.pdf")
+	for path, body := range map[string]map[string]interface{}{
+		"/scrape":   {"files": []string{missing}},
+		"/generate": {"input_files": []string{missing}, "context_json_file": map[string]interface{}{"name": "Jane"}},
+		"/rotate":   {"input_file": missing, "output_file": filepath.Join(t.TempDir(), "out.pdf"), "rotation": 90},
+		"/merge":    {"input_files": []string{missing, missing}, "output_file": filepath.Join(t.TempDir(), "out.pdf")},
+	} {
+		recorder := serveJSON(t, r, path, body)
+		if body := checkStatus(t, path, recorder); recorder.Code != http.StatusNotFound || body["code"] != code_file_not_found {
+			t.Errorf("%s: got %d %v, want a 404 FILE_NOT_FOUND", path, recorder.Code, body)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Retries for reading inputs from places that fail now and then: files on network
+	mounts and context_json_file URLs. Only errors that can go away by themselves are
+	retried, a file that isn't there or can't be read by us fails right away.
+	Retries back off exponentially from retry_base_delay and every one is logged.
+*/
+
+var (
+	// How often an input is tried in all, 1 turns retrying off
+	retry_attempts   = 3
+	retry_base_delay = 100 * time.Millisecond
+)
+
+// The errnos a network filesystem or connection comes back with while it's having a moment
+var transient_errnos = []syscall.Errno{
+	syscall.EAGAIN, syscall.EINTR, syscall.EBUSY, syscall.ESTALE, syscall.ETIMEDOUT,
+	syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED,
+	syscall.ENETDOWN, syscall.ENETUNREACH, syscall.EHOSTUNREACH,
+}
+
+// httpStatusError is a fetch answered with a status other than 200
+type httpStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URL, e.Status)
+}
+
+func transientError(err error) bool {
+	/*
+		Whether err may well be gone when trying again. Missing files, permissions
+		and the request running out of time never are.
+	*/
+	if err == nil || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	for _, errno := range transient_errnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var dns_error *net.DNSError
+	if errors.As(err, &dns_error) {
+		return !dns_error.IsNotFound && (dns_error.IsTemporary || dns_error.IsTimeout)
+	}
+	var status_error *httpStatusError
+	if errors.As(err, &status_error) {
+		switch status_error.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var net_error net.Error
+	if errors.As(err, &net_error) && net_error.Timeout() {
+		return true
+	}
+	// A response body cut off halfway
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func withRetry(c *gin.Context, what string, attempt func() error) error {
+	/*
+		Calls attempt until it succeeds, fails with an error that isn't transient or
+		retry_attempts are used up, waiting twice as long before every next try.
+		Gives up early when the request is done, returning the last error.
+	*/
+	ctx := c.Request.Context()
+	delay := retry_base_delay
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= retry_attempts || !transientError(err) {
+			return err
+		}
+		requestLogger(c).Warn("retrying", "input", what, "attempt", n, "delay_ms", delay.Milliseconds(), "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func retryingFile(c *gin.Context, in_file gopdf.InputFile) gopdf.InputFile {
+	// in_file with opening it retried on transient errors
+	open := in_file.Open
+	in_file.Open = func() (gopdf.File, error) {
+		var f gopdf.File
+		err := withRetry(c, in_file.Name, func() error {
+			var err error
+			f, err = open()
+			return err
+		})
+		return f, err
+	}
+	return in_file
+}
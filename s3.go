@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+/*
+	output_file can also be an s3://bucket/key URL, the PDF is uploaded there instead
+	of written to disk. Credentials and region come from the standard AWS chain (env,
+	shared config, IAM role). GOPDF_S3_ENDPOINT points it at an S3 compatible store
+	like MinIO instead, addressed path style.
+*/
+
+const s3_scheme = "s3://"
+
+// Made on the first upload, loading the AWS config reads files and may ask the instance metadata
+var s3_client struct {
+	once       sync.Once
+	aws_config aws.Config
+	client     *s3.Client
+	err        error
+}
+
+func isS3URL(out_path string) bool {
+	return strings.HasPrefix(out_path, s3_scheme)
+}
+
+func parseS3URL(url string) (string, string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(url, s3_scheme), "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("%q is not an s3://bucket/key URL", url)
+	}
+	return bucket, key, nil
+}
+
+// s3Key is where the filled file_name goes under url when there are several, url is then a prefix
+func s3Key(url string, file_name string) string {
+	return strings.TrimSuffix(url, "/") + "/" + path.Base(file_name)
+}
+
+func uploadS3(ctx context.Context, url string, body io.Reader) error {
+	bucket, key, err := parseS3URL(url)
+	if err != nil {
+		return err
+	}
+	client, err := s3Client()
+	if err != nil {
+		return err
+	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String("application/pdf"),
+	})
+	return err
+}
+
+func s3Client() (*s3.Client, error) {
+	s3_client.once.Do(func() {
+		s3_client.aws_config, s3_client.err = config.LoadDefaultConfig(context.Background())
+		if s3_client.err != nil {
+			return
+		}
+		endpoint := os.Getenv("GOPDF_S3_ENDPOINT")
+		s3_client.client = s3.NewFromConfig(s3_client.aws_config, func(o *s3.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+				o.UsePathStyle = true
+			}
+		})
+	})
+	return s3_client.client, s3_client.err
+}
+
+// s3Configured is whether the environment is set up for S3 output, only then does the healthcheck look at it
+func s3Configured() bool {
+	for _, name := range []string{"GOPDF_S3_ENDPOINT", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID", "AWS_PROFILE", "AWS_ROLE_ARN"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func checkS3Credentials(ctx context.Context) error {
+	// Resolving them is what a first upload would do, without uploading anything
+	if _, err := s3Client(); err != nil {
+		return err
+	}
+	if s3_client.aws_config.Credentials == nil {
+		return errors.New("no AWS credentials configured")
+	}
+	_, err := s3_client.aws_config.Credentials.Retrieve(ctx)
+	return err
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	JSON bodies of /generate and /scrape are checked against a JSON Schema before anything
+	else happens, so a bad request gets told everything that's wrong with it in one 400.
+	Only the part of JSON Schema these use is implemented: type, properties, required,
+	items, minItems and additionalProperties as a schema.
+*/
+
+type jsonSchema struct {
+	// A type name or a list of them
+	Type                 interface{}            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	MinItems             int                    `json:"minItems"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+}
+
+var generate_schema = mustSchema(`{
+	"type": "object",
+	"required": ["input_files"],
+	"properties": {
+		"input_files": {"type": "array", "items": {"type": "string"}, "minItems": 1},
+		"context_json_file": {"type": ["object", "string", "null"]},
+		"form_data_file": {"type": ["string", "null"]},
+		"output_file": {"type": ["string", "null"]},
+		"flatten": {"type": ["boolean", "null"]},
+		"dry_run": {"type": ["boolean", "null"]},
+		"strict_formats": {"type": ["boolean", "null"]},
+		"truncate_to_max_len": {"type": ["boolean", "null"]},
+		"reproducible": {"type": ["boolean", "null"]},
+		"reproducible_date": {"type": ["string", "null"]},
+		"tab_order": {"type": ["string", "null"]},
+		"transforms": {"type": ["object", "null"]},
+		"validation": {"type": ["string", "null"]},
+		"repair_xref": {"type": ["boolean", "null"]}
+	}
+}`)
+
+var scrape_schema = mustSchema(`{
+	"type": "object",
+	"required": ["files"],
+	"properties": {
+		"files": {"type": "array", "items": {"type": "string"}, "minItems": 1},
+		"password": {"type": ["string", "null"]},
+		"passwords": {"type": ["object", "null"], "additionalProperties": {"type": "string"}},
+		"validation": {"type": ["string", "null"]},
+		"repair_xref": {"type": ["boolean", "null"]}
+	}
+}`)
+
+func mustSchema(text string) *jsonSchema {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(text), &schema); err != nil {
+		panic(fmt.Sprintf("bad schema: %v", err))
+	}
+	return &schema
+}
+
+func (schema *jsonSchema) types() []string {
+	switch t := schema.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func schemaTypeOf(v interface{}) string {
+	// The JSON Schema type name of a decoded JSON value, numbers are all "number"
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return ""
+}
+
+func (schema *jsonSchema) validate(v interface{}, path string) []string {
+	/*
+		Every way v breaks schema, as messages naming where in the body it is,
+		like "input_files[2] must be a string, got a number". nil when it's fine.
+	*/
+	var violations []string
+	name := path
+	if name == "" {
+		name = "the body"
+	}
+	if types := schema.types(); len(types) > 0 {
+		type_ok := false
+		for _, t := range types {
+			if t == schemaTypeOf(v) {
+				type_ok = true
+			}
+		}
+		if !type_ok {
+			// Nothing inside a value of the wrong type is worth checking
+			return []string{fmt.Sprintf("%s must be %s, got %s", name, typeList(types), jsonType(v))}
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, key := range schema.Required {
+			if _, found := value[key]; !found {
+				violations = append(violations, fmt.Sprintf("%s is required", joinPath(path, key)))
+			}
+		}
+		// Sorted so the same body always gets the same messages
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, declared := schema.Properties[key]
+			if !declared {
+				property = schema.AdditionalProperties
+			}
+			if property != nil {
+				violations = append(violations, property.validate(value[key], joinPath(path, key))...)
+			}
+		}
+	case []interface{}:
+		if len(value) < schema.MinItems && schema.MinItems == 1 {
+			violations = append(violations, fmt.Sprintf("%s must not be empty", name))
+		} else if len(value) < schema.MinItems {
+			violations = append(violations, fmt.Sprintf("%s must have at least %d items, got %d", name, schema.MinItems, len(value)))
+		}
+		if schema.Items != nil {
+			for i, item := range value {
+				violations = append(violations, schema.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return violations
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func typeList(types []string) string {
+	// "a string", "an object or a string"..., null left out as it's fine where it's allowed
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		switch t {
+		case "null":
+			continue
+		case "array", "object":
+			names = append(names, "an "+t)
+		default:
+			names = append(names, "a "+t)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+func bindSchemaJSON(c *gin.Context, schema *jsonSchema, req interface{}) bool {
+	/*
+		Checks the JSON body against schema and decodes it into req.
+		Sends a 400 listing every violation when it doesn't match.
+	*/
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errorHandler(0, err, c)
+		return false
+	}
+	var v interface{}
+	if err = json.Unmarshal(body, &v); err != nil {
+		errorHandler(0, err, c)
+		return false
+	}
+	if violations := schema.validate(v, ""); len(violations) > 0 {
+		messages := make([]string, len(violations))
+		for i, violation := range violations {
+			messages[i] = "Bad Request. " + violation
+		}
+		sendResponse(c, Response{Status: http.StatusBadRequest, Error: messages})
+		return false
+	}
+	if err = json.Unmarshal(body, req); err != nil {
+		errorHandler(0, err, c)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func openFDs(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("can't count open files: %v", err)
+	}
+	return len(entries)
+}
+
+func TestScrapeClosesFiles(t *testing.T) {
+	r := testRouter()
+	form := writeTestPDF(t, test_form)
+	broken := writeTestPDF(t, []byte("%PDF-1.7\nnot a PDF after all"))
+	files := make([]string, 50)
+	for i := range files {
+		files[i] = form
+	}
+	with_broken := append(append([]string{}, files...), broken)
+
+	// The first request opens what the runtime and pdfcpu keep open for good
+	serveJSON(t, r, "/scrape", map[string]interface{}{"files": files})
+	before := openFDs(t)
+	for i := 0; i < 20; i++ {
+		if recorder := serveJSON(t, r, "/scrape", map[string]interface{}{"files": files}); recorder.Code != http.StatusOK {
+			t.Fatalf("scrape: got %d: %s", recorder.Code, recorder.Body)
+		}
+		// The broken file is opened too and has to be closed on the error path
+		if body := responseBody(t, serveJSON(t, r, "/scrape", map[string]interface{}{"files": with_broken})); body["error_codes"] == nil {
+			t.Fatalf("scrape with a broken file: got no error_codes: %v", body)
+		}
+	}
+	if after := openFDs(t); after > before {
+		t.Errorf("%d files open before 40 scrapes of 50 files, %d after", before, after)
+	}
+}
+
+func TestScrapeConcurrentRequests(t *testing.T) {
+	// Requests scraping one filled form at once all get its values, and the file stays as it was
+	r := testRouter()
+	content := testPDF(
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R] /DA (/Helv 0 Tf 0 g) >> >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [72 600 272 620] /V (Jane Doe) >>",
+	)
+	form := writeTestPDF(t, content)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/scrape?detailed=true", strings.NewReader(`{"files": ["`+form+`", "`+form+`"]}`))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			var body struct {
+				Fields []struct {
+					Name  string      `json:"name"`
+					Value interface{} `json:"value"`
+				} `json:"acro_form_fields"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || recorder.Code != http.StatusOK {
+				t.Errorf("got %d (%v): %s", recorder.Code, err, recorder.Body)
+				return
+			}
+			for _, field := range body.Fields {
+				if field.Name != "name" || field.Value != "Jane Doe" {
+					t.Errorf("scraped %s as %v, want name as Jane Doe", field.Name, field.Value)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if on_disk, err := os.ReadFile(form); err != nil || !bytes.Equal(on_disk, content) {
+		t.Errorf("the input file changed (%v)", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Graceful shutdown: on SIGINT or SIGTERM we stop accepting connections and give
+	the requests in flight shutdown_timeout to finish. Whatever is still running
+	after that gets its temp files (uploads and half written outputs) removed
+	before we exit, the deferred cleanups of those requests won't get to run.
+*/
+
+// Uploads of the requests in flight, cleanupRequest takes them out again
+var open_forms = struct {
+	sync.Mutex
+	forms map[*multipart.Form]bool
+}{forms: make(map[*multipart.Form]bool)}
+
+func serve(handler http.Handler, addr string, shutdown_timeout time.Duration, logger *slog.Logger) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serve_err := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", addr)
+		serve_err <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serve_err:
+		// Never got going, like the address being in use
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal kills us right away
+	stop()
+
+	logger.Info("shutting down", "timeout", shutdown_timeout.String())
+	shutdown_ctx, cancel := context.WithTimeout(context.Background(), shutdown_timeout)
+	defer cancel()
+	err := server.Shutdown(shutdown_ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Warn("shutdown timed out, dropping the requests still running")
+		removeOpenForms()
+		gopdf.RemoveTempFiles()
+		return nil
+	}
+	return err
+}
+
+func multipartForm(c *gin.Context) (*multipart.Form, error) {
+	// c.MultipartForm, with the upload tracked until cleanupRequest
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	open_forms.Lock()
+	open_forms.forms[form] = true
+	open_forms.Unlock()
+	return form, nil
+}
+
+func removeOpenForms() {
+	open_forms.Lock()
+	defer open_forms.Unlock()
+	for form := range open_forms.forms {
+		form.RemoveAll()
+		delete(open_forms.forms, form)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Streamed responses: a /scrape or /generate-batch request with Accept: application/x-ndjson
+	gets one JSON object per line, one per file or job as soon as it's done (so in the order
+	they finish, each names its idx or job), and a last line with the summary. The status
+	is sent with the first line, so it's a 200 even when every file fails and a timeout
+	halfway is an error line at the end instead of a 504.
+*/
+
+const ndjson_type = "application/x-ndjson"
+
+func wantsNDJSON(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), ndjson_type)
+}
+
+// ndjsonWriter writes the lines of a streamed response, from any goroutine
+type ndjsonWriter struct {
+	sync.Mutex
+	c       *gin.Context
+	started bool
+}
+
+func (w *ndjsonWriter) line(v interface{}) {
+	// Writes v as one line and flushes it to the client right away
+	w.Lock()
+	defer w.Unlock()
+	if !w.started {
+		w.c.Header("Content-Type", ndjson_type)
+		// Proxies like nginx buffer responses unless told not to
+		w.c.Header("X-Accel-Buffering", "no")
+		w.c.Status(http.StatusOK)
+		w.started = true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(gin.H{"error": err.Error()})
+	}
+	w.c.Writer.Write(append(data, '\n'))
+	w.c.Writer.Flush()
+}
+
+func streamScrape(c *gin.Context, input_files []gopdf.InputFile) {
+	/*
+		/scrape as NDJSON, a line per file:
+			{"idx": 0, "file": "a.pdf", "acro_form_fields": ["name", ...]}
+			{"idx": 1, "file": "b.pdf", "error": "...", "code": "INVALID_PDF"}
+			{"message": "Scraped 1 of 2 files", "done": true}
+	*/
+	w := &ndjsonWriter{c: c}
+	detailed := c.Query("detailed") == "true"
+	scraped := 0
+	err := gopdf.ScrapeEach(c.Request.Context(), input_files, func(idx int, fields []gopdf.Field, file_error *gopdf.FileError) {
+		line := gin.H{"idx": idx, "file": input_files[idx].Name}
+		if file_error != nil {
+			line["error"] = fmt.Sprintf("%v", file_error.Err)
+			line["code"] = fileErrorCode(file_error)
+			requestLogger(c).Warn("file could not be scraped", "file", input_files[idx].Name, "idx", idx, "error", file_error.Err.Error())
+		} else if detailed {
+			line["acro_form_fields"] = fields
+			scraped++
+		} else {
+			field_names := make([]string, len(fields))
+			for i, field := range fields {
+				field_names[i] = field.Name
+			}
+			line["acro_form_fields"] = field_names
+			scraped++
+		}
+		w.line(line)
+	})
+	if err != nil {
+		message, code := err.Error(), code_internal
+		if errors.Is(err, context.DeadlineExceeded) {
+			message, code = "Gateway Timeout. the files took too long to scrape", code_timeout
+		}
+		w.line(gin.H{"error": message, "code": code, "done": true})
+		return
+	}
+	w.line(gin.H{"message": fmt.Sprintf("Scraped %d of %d files", scraped, len(input_files)), "done": true})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+
+	"pdfserver/pkg/gopdf"
+)
+
+/*
+	Temp files: everything we spill to disk, uploads included, goes into a gopdf-<pid>
+	directory of its own under GOPDF_TMPDIR (os.TempDir() by default). Requests remove
+	theirs in a defer, which runs on a panic too, and the directory goes when we exit.
+	A crashed run can't do either, so on startup we remove the gopdf-<pid> directories
+	of processes that aren't running anymore.
+*/
+
+// This process' temp directory, set up by setupTempDir
+var temp_dir string
+
+var temp_dir_name = regexp.MustCompile(`^gopdf-(\d+)$`)
+
+func setupTempDir(base string, logger *slog.Logger) error {
+	if base == "" {
+		base = os.TempDir()
+	}
+	if err := os.MkdirAll(base, 0o700); err != nil {
+		return err
+	}
+	sweepTempDirs(base, logger)
+
+	temp_dir = filepath.Join(base, fmt.Sprintf("gopdf-%d", os.Getpid()))
+	if err := os.Mkdir(temp_dir, 0o700); err != nil {
+		return err
+	}
+	gopdf.TempDir = temp_dir
+	// The multipart parser spills big uploads to os.TempDir(), which is $TMPDIR
+	return os.Setenv("TMPDIR", temp_dir)
+}
+
+func sweepTempDirs(base string, logger *slog.Logger) {
+	/*
+		Removes what earlier runs left behind, a directory named after our own pid
+		is from an earlier run too (containers where we're always pid 1).
+	*/
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		logger.Warn("reading temp dir", "dir", base, "error", err.Error())
+		return
+	}
+	for _, entry := range entries {
+		match := temp_dir_name.FindStringSubmatch(entry.Name())
+		if match == nil || !entry.IsDir() {
+			continue
+		}
+		pid, err := strconv.Atoi(match[1])
+		if err != nil || (pid != os.Getpid() && processRunning(pid)) {
+			continue
+		}
+		stale_dir := filepath.Join(base, entry.Name())
+		if err := os.RemoveAll(stale_dir); err != nil {
+			logger.Warn("removing stale temp dir", "dir", stale_dir, "error", err.Error())
+			continue
+		}
+		logger.Info("removed stale temp dir", "dir", stale_dir)
+	}
+}
+
+func processRunning(pid int) bool {
+	// Signal 0 checks the process is there without doing anything to it
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
+
+func removeTempDir() {
+	if temp_dir != "" {
+		os.RemoveAll(temp_dir)
+	}
+}
+
+func requestTempDir(c *gin.Context, purpose string) (string, func(), error) {
+	/*
+		A temp directory for this request named after its request ID, so leftovers
+		can be traced back to the request in the logs. Call the cleanup in a defer.
+	*/
+	dir, err := os.MkdirTemp(temp_dir, fmt.Sprintf("%s-%s-*", safeRequestID(requestID(c)), purpose))
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, func() { os.RemoveAll(dir) }, nil
+}
+
+func safeRequestID(request_id string) string {
+	// Clients pick their own X-Request-ID, only some of it is fit for a file name
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, request_id)
+	if len(safe) > 32 {
+		safe = safe[:32]
+	}
+	if safe == "" {
+		safe = "request"
+	}
+	return safe
+}